@@ -8,12 +8,12 @@ import (
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/session"
 	"github.com/256dpi/gomqtt/topic"
+	"github.com/256dpi/gomqtt/transport"
 )
 
 type memorySession struct {
 	*session.MemorySession
 
-	subscriptions  *topic.Tree
 	storedQueue    chan *packet.Message
 	temporaryQueue chan *packet.Message
 	activeClient   *Client
@@ -22,36 +22,11 @@ type memorySession struct {
 func newMemorySession(backlog int) *memorySession {
 	return &memorySession{
 		MemorySession:  session.NewMemorySession(),
-		subscriptions:  topic.NewStandardTree(),
 		storedQueue:    make(chan *packet.Message, backlog),
 		temporaryQueue: make(chan *packet.Message, backlog),
 	}
 }
 
-func (s *memorySession) lookupSubscription(topic string) *packet.Subscription {
-	// find subscription
-	value := s.subscriptions.MatchFirst(topic)
-	if value != nil {
-		return value.(*packet.Subscription)
-	}
-
-	return nil
-}
-
-func (s *memorySession) applyQOS(msg *packet.Message) *packet.Message {
-	// get subscription
-	sub := s.lookupSubscription(msg.Topic)
-	if sub != nil {
-		// respect maximum qos
-		if msg.QOS > sub.QOS {
-			msg = msg.Copy()
-			msg.QOS = sub.QOS
-		}
-	}
-
-	return msg
-}
-
 func (s *memorySession) reuse() {
 	// reset temporary queue
 	s.temporaryQueue = make(chan *packet.Message, cap(s.temporaryQueue))
@@ -83,16 +58,30 @@ type MemoryBackend struct {
 	ClientParallelSubscribes int
 	ClientInflightMessages   int
 	ClientTokenTimeout       time.Duration
+	ClientTopicProfile       *topic.Profile
+
+	// The rate limits applied to clients during Setup. Zero values keep the
+	// limits configured by the engine.
+	ClientSendRate    transport.RateLimit
+	ClientReceiveRate transport.RateLimit
 
 	// A map of username and passwords that grant read and write access.
 	Credentials map[string]string
 
+	// A map of certificate names and usernames that grant read and write
+	// access to clients that present a verified certificate. The common name
+	// and the DNS, email and URI subject alternative names of the certificate
+	// are looked up. If the client supplies a username, it must match the
+	// mapped username.
+	CertificateUsers map[string]string
+
 	// The Logger callback handles incoming log events.
 	Logger func(LogEvent, *Client, packet.Generic, *packet.Message, error)
 
 	activeClients     map[string]*Client
 	storedSessions    map[string]*memorySession
 	temporarySessions map[*Client]*memorySession
+	subscriptions     *topic.Index
 	retainedMessages  *topic.Tree
 	globalMutex       sync.Mutex
 	setupMutex        sync.Mutex
@@ -107,12 +96,13 @@ func NewMemoryBackend() *MemoryBackend {
 		activeClients:     make(map[string]*Client),
 		storedSessions:    make(map[string]*memorySession),
 		temporarySessions: make(map[*Client]*memorySession),
+		subscriptions:     topic.NewStandardIndex(),
 		retainedMessages:  topic.NewStandardTree(),
 	}
 }
 
 // Authenticate will authenticates a clients credentials.
-func (m *MemoryBackend) Authenticate(_ *Client, user, password string) (bool, error) {
+func (m *MemoryBackend) Authenticate(client *Client, user, password string) (bool, error) {
 	// acquire global mutex
 	m.globalMutex.Lock()
 	defer m.globalMutex.Unlock()
@@ -122,8 +112,15 @@ func (m *MemoryBackend) Authenticate(_ *Client, user, password string) (bool, er
 		return false, ErrClosing
 	}
 
+	// check certificate
+	if m.CertificateUsers != nil && client != nil {
+		if mapped, ok := m.certificateUser(client); ok && (user == "" || user == mapped) {
+			return true, nil
+		}
+	}
+
 	// allow all if there are no credentials
-	if m.Credentials == nil {
+	if m.Credentials == nil && m.CertificateUsers == nil {
 		return true, nil
 	}
 
@@ -135,6 +132,35 @@ func (m *MemoryBackend) Authenticate(_ *Client, user, password string) (bool, er
 	return false, nil
 }
 
+// returns the user mapped to the verified client certificate
+func (m *MemoryBackend) certificateUser(client *Client) (string, bool) {
+	// get verified chains
+	chains := client.Metadata().VerifiedChains()
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return "", false
+	}
+
+	// get certificate
+	cert := chains[0][0]
+
+	// collect names
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+
+	// lookup names
+	for _, name := range names {
+		if user, ok := m.CertificateUsers[name]; ok && name != "" {
+			return user, true
+		}
+	}
+
+	return "", false
+}
+
 // Setup will close existing clients and return an appropriate session.
 func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, bool, error) {
 	// acquire setup mutex
@@ -156,6 +182,15 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	client.ParallelSubscribes = m.ClientParallelSubscribes
 	client.InflightMessages = m.ClientInflightMessages
 	client.TokenTimeout = m.ClientTokenTimeout
+	client.TopicProfile = m.ClientTopicProfile
+
+	// apply rate limits
+	if m.ClientSendRate != (transport.RateLimit{}) {
+		client.Conn().SetSendRate(m.ClientSendRate)
+	}
+	if m.ClientReceiveRate != (transport.RateLimit{}) {
+		client.Conn().SetReceiveRate(m.ClientReceiveRate)
+	}
 
 	// return a new temporary session if id is zero
 	if len(id) == 0 {
@@ -183,8 +218,11 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 
 	// kill existing client if session is taken
 	if ok && existingSession.activeClient != nil {
+		// get client as it is cleared on termination
+		existingClient := existingSession.activeClient
+
 		// close client
-		existingSession.activeClient.Close()
+		existingClient.Close()
 
 		// release global mutex to allow publish and termination, but leave the
 		// setup mutex to prevent setups
@@ -193,7 +231,7 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 		// wait for client to close
 		var err error
 		select {
-		case <-existingSession.activeClient.Closed():
+		case <-existingClient.Closed():
 			// continue
 		case <-time.After(m.KillTimeout):
 			err = ErrKillTimeout
@@ -211,8 +249,11 @@ func (m *MemoryBackend) Setup(client *Client, id string, clean bool) (Session, b
 	// delete any stored session and return a temporary session if a clean
 	// session is requested
 	if clean {
-		// delete any stored session
-		delete(m.storedSessions, id)
+		// delete any stored session and its subscriptions
+		if storedSession, ok := m.storedSessions[id]; ok {
+			m.subscriptions.Clear(storedSession)
+			delete(m.storedSessions, id)
+		}
 
 		// create new session
 		sess := newMemorySession(m.SessionQueueSize)
@@ -275,7 +316,7 @@ func (m *MemoryBackend) Subscribe(client *Client, subs []packet.Subscription, ac
 
 	// save subscription
 	for _, sub := range subs {
-		sess.subscriptions.Set(sub.Topic, &sub)
+		m.subscriptions.Add(sub.Topic, sess, byte(sub.QOS))
 	}
 
 	// call ack if provided
@@ -292,7 +333,7 @@ func (m *MemoryBackend) Subscribe(client *Client, subs []packet.Subscription, ac
 		for _, value := range values {
 			// add to temporary queue or return error if queue is full
 			select {
-			case sess.temporaryQueue <- value.(*packet.Message):
+			case sess.temporaryQueue <- limitQOS(value.(*packet.Message), sub.QOS):
 			default:
 				return ErrQueueFull
 			}
@@ -309,7 +350,7 @@ func (m *MemoryBackend) Unsubscribe(client *Client, topics []string, ack Ack) er
 
 	// delete subscriptions
 	for _, t := range topics {
-		sess.subscriptions.Empty(t)
+		m.subscriptions.Remove(t, sess)
 	}
 
 	// call ack if provided
@@ -357,48 +398,32 @@ func (m *MemoryBackend) Publish(client *Client, msg *packet.Message, ack Ack) er
 		}
 	}
 
-	// add message to temporary sessions
-	for _, sess := range m.temporarySessions {
-		if sub := sess.lookupSubscription(msg.Topic); sub != nil {
-			if sess.activeClient == client {
-				// detect deadlock when adding to own queue
-				select {
-				case queue(sess) <- msg:
-				default:
-					return ErrQueueFull
-				}
-			} else {
-				// wait for room since client is online
-				select {
-				case queue(sess) <- msg:
-				case <-sess.activeClient.Closing():
-				}
-			}
-		}
-	}
+	// add message to all subscribed sessions
+	for subscriber, qos := range m.subscriptions.Match(msg.Topic) {
+		// get session
+		sess := subscriber.(*memorySession)
 
-	// add message to stored sessions
-	for _, sess := range m.storedSessions {
-		if sub := sess.lookupSubscription(msg.Topic); sub != nil {
-			if sess.activeClient == client {
-				// detect deadlock when adding to own queue
-				select {
-				case queue(sess) <- msg:
-				default:
-					return ErrQueueFull
-				}
-			} else if sess.activeClient != nil {
-				// wait for room since client is online
-				select {
-				case queue(sess) <- msg:
-				case <-sess.activeClient.Closing():
-				}
-			} else {
-				// ignore message if offline queue is full
-				select {
-				case queue(sess) <- msg:
-				default:
-				}
+		// respect maximum qos
+		sessMsg := limitQOS(msg, packet.QOS(qos))
+
+		if sess.activeClient == client {
+			// detect deadlock when adding to own queue
+			select {
+			case queue(sess) <- sessMsg:
+			default:
+				return ErrQueueFull
+			}
+		} else if sess.activeClient != nil {
+			// wait for room since client is online
+			select {
+			case queue(sess) <- sessMsg:
+			case <-sess.activeClient.Closing():
+			}
+		} else {
+			// ignore message if offline queue is full
+			select {
+			case queue(sess) <- sessMsg:
+			default:
 			}
 		}
 	}
@@ -424,9 +449,9 @@ func (m *MemoryBackend) Dequeue(client *Client) (*packet.Message, Ack, error) {
 	// get next message from queue
 	select {
 	case msg := <-sess.temporaryQueue:
-		return sess.applyQOS(msg), nil, nil
+		return msg, nil, nil
 	case msg := <-sess.storedQueue:
-		return sess.applyQOS(msg), nil, nil
+		return msg, nil, nil
 	case <-client.Closing():
 		return nil, nil, nil
 	}
@@ -446,8 +471,11 @@ func (m *MemoryBackend) Terminate(client *Client) error {
 		sess.activeClient = nil
 	}
 
-	// remove any temporary session
-	delete(m.temporarySessions, client)
+	// remove any temporary session and its subscriptions
+	if _, ok := m.temporarySessions[client]; ok {
+		m.subscriptions.Clear(sess)
+		delete(m.temporarySessions, client)
+	}
 
 	// remove any saved client
 	delete(m.activeClients, client.ID())
@@ -512,3 +540,17 @@ func (m *MemoryBackend) Close(timeout time.Duration) bool {
 
 	return true
 }
+
+// returns a copy of the message if its qos exceeds the maximum qos
+func limitQOS(msg *packet.Message, max packet.QOS) *packet.Message {
+	// check qos
+	if msg.QOS <= max {
+		return msg
+	}
+
+	// downgrade qos
+	msg = msg.Copy()
+	msg.QOS = max
+
+	return msg
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/session"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 
 	"gopkg.in/tomb.v2"
@@ -230,6 +231,12 @@ type Client struct {
 	// Will default to 30 seconds.
 	TokenTimeout time.Duration
 
+	// TopicProfile may be set during Setup to enforce additional rules on the
+	// topics of published messages and the filters of subscriptions. Clients
+	// that publish invalid topics are closed while invalid subscriptions are
+	// rejected with a failure return code.
+	TopicProfile *topic.Profile
+
 	// PacketCallback can be set to inspect packets before processing and
 	// apply rate limits. To guarantee the connection lifecycle, Connect and
 	// Disconnect packets are not provided to the callback.
@@ -288,11 +295,24 @@ func (c *Client) ID() string {
 }
 
 // Conn returns the client's underlying connection. Calls to SetReadLimit,
-// LocalAddr and RemoteAddr are safe.
+// LocalAddr and RemoteAddr are safe. Calls to SetSendRate and SetReceiveRate
+// are safe during Backend.Setup.
 func (c *Client) Conn() transport.Conn {
 	return c.conn
 }
 
+// Metadata returns additional information about the client's underlying
+// connection like the TLS connection state and the verified client
+// certificate chains.
+func (c *Client) Metadata() transport.Metadata {
+	return c.conn.Metadata()
+}
+
+// Stats returns the traffic counters of the client's underlying connection.
+func (c *Client) Stats() transport.Stats {
+	return c.conn.Stats()
+}
+
 // Close will immediately close the client.
 func (c *Client) Close() {
 	_ = c.conn.Close()
@@ -317,7 +337,9 @@ func (c *Client) processor() error {
 
 	// get first packet from connection
 	pkt, err := c.conn.Receive()
-	if err != nil {
+	if _, ok := err.(*packet.ConformanceError); ok {
+		return c.die(ClientError, err)
+	} else if err != nil {
 		return c.die(TransportError, err)
 	}
 
@@ -347,7 +369,9 @@ func (c *Client) processor() error {
 
 		// receive next packet
 		pkt, err := c.conn.Receive()
-		if err != nil {
+		if _, ok := err.(*packet.ConformanceError); ok {
+			return c.die(ClientError, err)
+		} else if err != nil {
 			return c.die(TransportError, err)
 		}
 
@@ -705,9 +729,23 @@ func (c *Client) processSubscribe(pkt *packet.Subscribe) error {
 	suback.ReturnCodes = make([]packet.QOS, len(pkt.Subscriptions))
 	suback.ID = pkt.ID
 
+	// prepare valid subscriptions
+	subs := make([]packet.Subscription, 0, len(pkt.Subscriptions))
+
 	// set granted qos
 	for i, subscription := range pkt.Subscriptions {
+		// validate filter if requested
+		if c.TopicProfile != nil {
+			_, err := c.TopicProfile.Parse(subscription.Topic, true)
+			if err != nil {
+				c.backend.Log(ClientError, c, pkt, nil, err)
+				suback.ReturnCodes[i] = packet.QOSFailure
+				continue
+			}
+		}
+
 		suback.ReturnCodes[i] = subscription.QOS
+		subs = append(subs, subscription)
 	}
 
 	// prepare ack
@@ -721,8 +759,14 @@ func (c *Client) processSubscribe(pkt *packet.Subscribe) error {
 		})
 	}
 
+	// acknowledge directly if all subscriptions have been rejected
+	if len(subs) == 0 {
+		ack()
+		return nil
+	}
+
 	// subscribe client to queue
-	err := c.backend.Subscribe(c, pkt.Subscriptions, ack)
+	err := c.backend.Subscribe(c, subs, ack)
 	if err != nil {
 		return c.die(BackendError, err)
 	}
@@ -773,6 +817,14 @@ func (c *Client) processUnsubscribe(pkt *packet.Unsubscribe) error {
 
 // handle an incoming publish packet
 func (c *Client) processPublish(publish *packet.Publish) error {
+	// validate topic if requested
+	if c.TopicProfile != nil {
+		_, err := c.TopicProfile.Parse(publish.Message.Topic, false)
+		if err != nil {
+			return c.die(ClientError, err)
+		}
+	}
+
 	// handle qos 0 flow
 	if publish.Message.QOS == 0 {
 		// publish message
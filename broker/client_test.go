@@ -1,11 +1,14 @@
 package broker
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/256dpi/gomqtt/client"
 	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 	"github.com/256dpi/gomqtt/transport/flow"
 
@@ -93,6 +96,56 @@ func TestClientPacketCallback(t *testing.T) {
 	assert.Equal(t, packet.PUBLISH, backend.packets[1].Type())
 }
 
+func TestClientTopicProfile(t *testing.T) {
+	backend := NewMemoryBackend()
+	backend.ClientTopicProfile = &topic.Profile{
+		ReservedPrefixes:  []string{"$SYS"},
+		RejectEmptyLevels: true,
+	}
+
+	var errs []error
+	backend.Logger = func(event LogEvent, _ *Client, _ packet.Generic, _ *packet.Message, err error) {
+		if event == ClientError {
+			errs = append(errs, err)
+		}
+	}
+
+	port, quit, done := Run(NewEngine(backend), "tcp")
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	f := flow.New().
+		Send(packet.NewConnect()).
+		Receive(packet.NewConnack()).
+		Send(&packet.Subscribe{ID: 1, Subscriptions: []packet.Subscription{
+			{Topic: "$SYS/#", QOS: 1},
+			{Topic: "foo//bar", QOS: 1},
+		}}).
+		Receive(&packet.Suback{ID: 1, ReturnCodes: []packet.QOS{1, packet.QOSFailure}}).
+		Send(&packet.Subscribe{ID: 2, Subscriptions: []packet.Subscription{
+			{Topic: "foo/#/bar", QOS: 0},
+		}}).
+		Receive(&packet.Suback{ID: 2, ReturnCodes: []packet.QOS{packet.QOSFailure}}).
+		Send(&packet.Publish{Message: packet.Message{Topic: "$SYS/foo"}}).
+		End()
+
+	err = f.Test(conn)
+	assert.NoError(t, err)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+
+	assert.Len(t, errs, 3)
+	assert.True(t, errors.Is(errs[0], topic.ErrEmptyLevel))
+	assert.Equal(t, topic.ErrWildcards, errs[1])
+	assert.True(t, errors.Is(errs[2], topic.ErrReservedPrefix))
+}
+
 func TestClientTokenTimeoutPublish(t *testing.T) {
 	backend := &testMemoryBackend{
 		MemoryBackend: *NewMemoryBackend(),
@@ -176,3 +229,46 @@ func TestClientTokenTimeoutDequeue(t *testing.T) {
 
 	safeReceive(done)
 }
+
+func TestClientPublishStream(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	port, quit, done := Run(NewEngine(backend), "tcp")
+
+	payload := bytes.Repeat([]byte("x"), 1000)
+
+	wait := make(chan struct{})
+
+	client1 := client.New()
+	client1.Callback = func(msg *packet.Message, err error) error {
+		assert.NoError(t, err)
+		assert.Equal(t, "stream", msg.Topic)
+		assert.Equal(t, payload, msg.Payload)
+		close(wait)
+		return nil
+	}
+
+	cf, err := client1.Connect(client.NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	sf, err := client1.Subscribe("stream", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, sf.Wait(10*time.Second))
+
+	pf, err := client1.PublishStream("stream", bytes.NewReader(payload), len(payload), 1, false)
+	assert.NoError(t, err)
+	assert.NoError(t, pf.Wait(10*time.Second))
+
+	safeReceive(wait)
+
+	err = client1.Disconnect()
+	assert.NoError(t, err)
+
+	ret := backend.Close(5 * time.Second)
+	assert.True(t, ret)
+
+	close(quit)
+
+	safeReceive(done)
+}
@@ -6,12 +6,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/transport"
 
 	"gopkg.in/tomb.v2"
 )
 
 // The Engine handles incoming connections and connects them to the backend.
+//
+// Received messages are always fully buffered, as they are handed to the
+// backend, which may retain and forward them after the packet has been read.
+// Streamed and pooled decoding are therefore not enabled on connections.
 type Engine struct {
 	// The Backend that will be passed to accepted clients.
 	Backend Backend
@@ -19,18 +24,29 @@ type Engine struct {
 	// ReadLimit defines the initial read limit.
 	ReadLimit int64
 
+	// Strictness defines the level of protocol conformance enforced on
+	// received packets. Clients that send packets violating the specification
+	// are disconnected.
+	Strictness packet.Strictness
+
 	// MaxWriteDelay defines the initial max write delay.
 	MaxWriteDelay time.Duration
 
 	// ConnectTimeout defines the timeout to receive the first packet.
 	ConnectTimeout time.Duration
 
+	// SendRate and ReceiveRate define the initial rate limits. The limits can
+	// be changed per client in Backend.Setup using the client's connection.
+	SendRate    transport.RateLimit
+	ReceiveRate transport.RateLimit
+
 	// OnError can be used to receive errors from the engine. If an error is
 	// received the server should be restarted.
 	OnError func(error)
 
-	mutex sync.Mutex
-	tomb  tomb.Tomb
+	mutex     sync.Mutex
+	tomb      tomb.Tomb
+	accepting bool
 }
 
 // NewEngine returns a new Engine.
@@ -45,6 +61,13 @@ func NewEngine(backend Backend) *Engine {
 
 // Accept begins accepting connections from the passed server.
 func (e *Engine) Accept(server transport.Server) {
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// mark accepting
+	e.accepting = true
+
 	e.tomb.Go(func() error {
 		for {
 			// return if dying
@@ -92,12 +115,19 @@ func (e *Engine) Handle(conn transport.Conn) bool {
 	// set default read limit
 	conn.SetReadLimit(e.ReadLimit)
 
+	// set strictness
+	conn.SetStrictness(e.Strictness)
+
 	// set initial max write delay
 	conn.SetMaxWriteDelay(e.MaxWriteDelay)
 
 	// set initial read timeout
 	conn.SetReadTimeout(e.ConnectTimeout)
 
+	// set initial rate limits
+	conn.SetSendRate(e.SendRate)
+	conn.SetReceiveRate(e.ReceiveRate)
+
 	// handle client
 	NewClient(e.Backend, conn)
 
@@ -115,12 +145,17 @@ func (e *Engine) Close() {
 
 	// stop acceptors
 	e.tomb.Kill(nil)
-	_ = e.tomb.Wait()
+
+	// wait for acceptors if any have been started
+	if e.accepting {
+		_ = e.tomb.Wait()
+	}
 }
 
 // Run runs the passed engine on a random available port and returns a channel
-// that can be closed to shutdown the engine. This method is intended to be used
-// in testing scenarios.
+// that can be closed to shutdown the engine. The "mem" protocol can be used to
+// run the engine without opening any sockets. This method is intended to be
+// used in testing scenarios.
 func Run(engine *Engine, protocol string) (string, chan struct{}, chan struct{}) {
 	// launch server
 	server, err := transport.Launch(protocol + "://localhost:0")
@@ -51,3 +51,189 @@ func TestDefaultReadLimit(t *testing.T) {
 	close(quit)
 	safeReceive(done)
 }
+
+func TestEngineCloseWithoutAccept(t *testing.T) {
+	engine := NewEngine(NewMemoryBackend())
+
+	done := make(chan struct{})
+	go func() {
+		engine.Close()
+		close(done)
+	}()
+
+	safeReceive(done)
+
+	conn1, conn2 := transport.NewMemoryConnPair("test")
+	assert.False(t, engine.Handle(conn2))
+
+	pkt, err := conn1.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+}
+
+func TestEngineRateLimits(t *testing.T) {
+	backend := NewMemoryBackend()
+	backend.ClientReceiveRate = transport.RateLimit{
+		Packets:      1,
+		PacketsBurst: 2,
+		Close:        true,
+	}
+
+	engine := NewEngine(backend)
+	engine.ReceiveRate = transport.RateLimit{
+		Packets:      1,
+		PacketsBurst: 1,
+		Close:        true,
+	}
+
+	port, quit, done := Run(engine, "tcp")
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	for i := 0; i < 2; i++ {
+		err = conn.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+
+		pkt, err = conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.PINGRESP, pkt.Type())
+	}
+
+	err = conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	pkt, err = conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	close(quit)
+	safeReceive(done)
+}
+
+func TestClientStats(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	clients := make(chan *Client, 1)
+	backend.Logger = func(event LogEvent, client *Client, _ packet.Generic, _ *packet.Message, _ error) {
+		if event == ClientDisconnected {
+			clients <- client
+		}
+	}
+
+	port, quit, done := Run(NewEngine(backend), "tcp")
+
+	c := client.New()
+
+	cf, err := c.Connect(client.NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, cf.Wait(10*time.Second))
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	stats := (<-clients).Stats()
+	assert.Equal(t, uint64(1), stats.PacketsReceived[packet.CONNECT])
+	assert.Equal(t, uint64(1), stats.PacketsReceived[packet.DISCONNECT])
+	assert.Equal(t, uint64(1), stats.PacketsSent[packet.CONNACK])
+	assert.Equal(t, c.Stats().BytesSent, stats.BytesReceived)
+
+	close(quit)
+	safeReceive(done)
+}
+
+func TestEngineWithFaults(t *testing.T) {
+	server, err := transport.Launch("tcp://localhost:0")
+	assert.NoError(t, err)
+
+	faults := transport.NewFaults(transport.FaultConfig{})
+
+	engine := NewEngine(NewMemoryBackend())
+	engine.Accept(transport.NewFaultServer(server, faults))
+
+	online := make(chan bool, 2)
+	offline := make(chan struct{}, 2)
+
+	s := client.NewService()
+	s.OnlineCallback = func(resumed bool) {
+		online <- resumed
+	}
+	s.OfflineCallback = func() {
+		offline <- struct{}{}
+	}
+
+	config := client.NewConfigWithClientID("tcp://"+server.Addr().String(), "test")
+	config.CleanSession = false
+
+	s.Start(config)
+	assert.False(t, <-online)
+
+	err = s.Subscribe("test", 1).Wait(10 * time.Second)
+	assert.NoError(t, err)
+
+	faults.Cut()
+
+	safeReceive(offline)
+	assert.True(t, <-online)
+
+	s.Stop(true)
+	safeReceive(offline)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	engine.Close()
+}
+
+func TestEngineStrictness(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	errs := make(chan error, 1)
+	backend.Logger = func(event LogEvent, _ *Client, _ packet.Generic, _ *packet.Message, err error) {
+		if event == ClientError {
+			errs <- err
+		}
+	}
+
+	engine := NewEngine(backend)
+	engine.Strictness = packet.Strict
+
+	port, quit, done := Run(engine, "tcp")
+
+	conn, err := transport.Dial("tcp://localhost:" + port)
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	subscribe := packet.NewSubscribe()
+	subscribe.ID = 1
+	subscribe.Subscriptions = []packet.Subscription{
+		{Topic: "foo/#/bar"},
+	}
+
+	err = conn.Send(subscribe, false)
+	assert.NoError(t, err)
+
+	pkt, err = conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = <-errs
+	assert.IsType(t, &packet.ConformanceError{}, err)
+	assert.Equal(t, "MQTT-4.7.1-2", err.(*packet.ConformanceError).Statement)
+
+	close(quit)
+	safeReceive(done)
+}
@@ -1,6 +1,16 @@
 package broker
 
-import "time"
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+)
 
 func safeReceive(ch chan struct{}) {
 	select {
@@ -9,3 +19,75 @@ func safeReceive(ch chan struct{}) {
 	case <-ch:
 	}
 }
+
+// returns a certificate signed by the parent or a self signed CA if parent is nil
+func testCertificate(parent *tls.Certificate, template *x509.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer := template
+	var signerKey interface{} = key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer = parent.Leaf
+		signerKey = parent.PrivateKey
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		panic(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+}
+
+// returns server and client TLS configs using a client certificate with the provided subject
+func testTLSConfigs(subject pkix.Name, dnsNames ...string) (*tls.Config, *tls.Config) {
+	ca := testCertificate(nil, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}})
+
+	server := testCertificate(&ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+
+	client := testCertificate(&ca, &x509.Certificate{
+		Subject:  subject,
+		DNSNames: dnsNames,
+	})
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+
+	clientConfig := &tls.Config{
+		Certificates: []tls.Certificate{client},
+		RootCAs:      pool,
+	}
+
+	return serverConfig, clientConfig
+}
@@ -0,0 +1,138 @@
+// Package chunk implements the transfer of large payloads as a sequence of
+// smaller chunk messages that are reassembled on the receiving side.
+package chunk
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrInvalidChunk is returned if a message could not be decoded as a chunk.
+var ErrInvalidChunk = errors.New("invalid chunk")
+
+// ErrInconsistentChunk is returned if a chunk does not match the previously
+// received chunks of the same transfer.
+var ErrInconsistentChunk = errors.New("inconsistent chunk")
+
+// ErrChecksumMismatch is returned if a reassembled payload does not match the
+// checksum sent with the transfer.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrTransferTimeout is returned if a transfer has not been completed in time.
+var ErrTransferTimeout = errors.New("transfer timeout")
+
+// ErrTransferTooLarge is returned if a transfer exceeds the maximum size.
+var ErrTransferTooLarge = errors.New("transfer too large")
+
+// ErrTooManyTransfers is returned if the maximum number of incomplete
+// transfers has been reached.
+var ErrTooManyTransfers = errors.New("too many transfers")
+
+const version byte = 1
+
+// the chunk header consists of the version, the transfer id, the chunk index,
+// the total number of chunks and the total size of the payload
+const headerLen = 1 + 16 + 4 + 4 + 8
+
+// the final chunk additionally carries the checksum of the complete payload
+const checksumLen = sha256.Size
+
+// An ID identifies a single transfer.
+type ID [16]byte
+
+// NewID returns a new random ID.
+func NewID() ID {
+	var id ID
+	_, err := rand.Read(id[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return id
+}
+
+// String returns the hex representation of the ID.
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type chunk struct {
+	id       ID
+	index    uint32
+	count    uint32
+	size     uint64
+	checksum []byte
+	data     []byte
+}
+
+func (c *chunk) final() bool {
+	return c.index == c.count-1
+}
+
+func (c *chunk) encode() []byte {
+	// calculate length
+	length := headerLen + len(c.data)
+	if c.final() {
+		length += checksumLen
+	}
+
+	// allocate buffer
+	buf := make([]byte, length)
+
+	// write header
+	buf[0] = version
+	copy(buf[1:17], c.id[:])
+	binary.BigEndian.PutUint32(buf[17:], c.index)
+	binary.BigEndian.PutUint32(buf[21:], c.count)
+	binary.BigEndian.PutUint64(buf[25:], c.size)
+	total := headerLen
+
+	// write checksum
+	if c.final() {
+		copy(buf[total:], c.checksum)
+		total += checksumLen
+	}
+
+	// write data
+	copy(buf[total:], c.data)
+
+	return buf
+}
+
+func decode(buf []byte) (*chunk, error) {
+	// check length and version
+	if len(buf) < headerLen || buf[0] != version {
+		return nil, ErrInvalidChunk
+	}
+
+	// read header
+	c := &chunk{}
+	copy(c.id[:], buf[1:17])
+	c.index = binary.BigEndian.Uint32(buf[17:])
+	c.count = binary.BigEndian.Uint32(buf[21:])
+	c.size = binary.BigEndian.Uint64(buf[25:])
+	total := headerLen
+
+	// check index
+	if c.count == 0 || c.index >= c.count {
+		return nil, ErrInvalidChunk
+	}
+
+	// read checksum
+	if c.final() {
+		if len(buf) < total+checksumLen {
+			return nil, ErrInvalidChunk
+		}
+
+		c.checksum = buf[total : total+checksumLen]
+		total += checksumLen
+	}
+
+	// read data
+	c.data = buf[total:]
+
+	return c, nil
+}
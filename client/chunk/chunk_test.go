@@ -0,0 +1,54 @@
+package chunk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkEncodeDecode(t *testing.T) {
+	c1 := &chunk{
+		id:    NewID(),
+		index: 1,
+		count: 3,
+		size:  10,
+		data:  []byte("foo"),
+	}
+
+	c2, err := decode(c1.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, c1, c2)
+
+	c1.index = 2
+	c1.checksum = make([]byte, checksumLen)
+
+	c2, err = decode(c1.encode())
+	assert.NoError(t, err)
+	assert.Equal(t, c1, c2)
+}
+
+func TestChunkDecodeError(t *testing.T) {
+	_, err := decode([]byte("foo"))
+	assert.Equal(t, ErrInvalidChunk, err)
+
+	c := &chunk{
+		id:    NewID(),
+		index: 0,
+		count: 1,
+	}
+
+	buf := c.encode()
+
+	_, err = decode(buf[:headerLen])
+	assert.Equal(t, ErrInvalidChunk, err)
+
+	buf[0] = 2
+
+	_, err = decode(buf)
+	assert.Equal(t, ErrInvalidChunk, err)
+
+	c.index = 1
+
+	_, err = decode(c.encode())
+	assert.Equal(t, ErrInvalidChunk, err)
+}
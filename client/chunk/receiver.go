@@ -0,0 +1,290 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// the default time after which incomplete transfers are dropped
+const defaultTimeout = time.Minute
+
+type transfer struct {
+	topic    string
+	count    uint32
+	size     uint64
+	checksum []byte
+	chunks   map[uint32][]byte
+	received uint64
+	qos      packet.QOS
+	deadline time.Time
+	timer    *time.Timer
+}
+
+type transferKey struct {
+	topic string
+	id    ID
+}
+
+// A Receiver reassembles chunks into complete messages. Chunks may arrive out
+// of order and duplicates are ignored. The zero value is ready to use, but
+// does not limit the size and number of transfers.
+type Receiver struct {
+	// The Callback is called with the reassembled message once a transfer has
+	// been completed or with an error if a transfer failed.
+	Callback func(msg *packet.Message, err error)
+
+	// The Progress callback is called after each new chunk with the number of
+	// received bytes and the total size of the transfer.
+	Progress func(id ID, received, total int64)
+
+	// The time after which an incomplete transfer is dropped. The timeout is
+	// reset with every received chunk.
+	//
+	// Note: The value must be changed before calling Handle.
+	//
+	// Will default to one minute.
+	Timeout time.Duration
+
+	// The maximum allowed size of a transfer. Chunks that declare a larger
+	// size are rejected. Zero means no limit.
+	//
+	// Will default to 64 MB.
+	MaxSize int64
+
+	// The maximum number of incomplete transfers. Chunks that would start a
+	// new transfer are rejected. Zero means no limit.
+	//
+	// Will default to 100.
+	MaxTransfers int
+
+	transfers map[transferKey]*transfer
+	completed map[transferKey]*time.Timer
+	mutex     sync.Mutex
+}
+
+// NewReceiver returns a new Receiver that calls the specified callback.
+func NewReceiver(callback func(msg *packet.Message, err error)) *Receiver {
+	return &Receiver{
+		Callback:     callback,
+		Timeout:      defaultTimeout,
+		MaxSize:      64 << 20,
+		MaxTransfers: 100,
+	}
+}
+
+// Handle will process the message as a chunk. It returns an error if the
+// message is not a valid chunk or does not match the other chunks of its
+// transfer. Errors encountered when completing a transfer are passed to the
+// callback instead.
+//
+// The method can be called from the client.Service MessageCallback. The
+// returned error should not be returned from the callback, as this would close
+// the client and cause the message to be redelivered.
+func (r *Receiver) Handle(msg *packet.Message) error {
+	// decode chunk
+	c, err := decode(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	// check size
+	if r.MaxSize > 0 && c.size > uint64(r.MaxSize) {
+		return ErrTransferTooLarge
+	}
+
+	// get timeout
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	// prepare key
+	key := transferKey{topic: msg.Topic, id: c.id}
+
+	// acquire mutex
+	r.mutex.Lock()
+
+	// ensure maps
+	if r.transfers == nil {
+		r.transfers = make(map[transferKey]*transfer)
+		r.completed = make(map[transferKey]*time.Timer)
+	}
+
+	// ignore chunks of already completed transfers
+	if _, ok := r.completed[key]; ok {
+		r.mutex.Unlock()
+		return nil
+	}
+
+	// get or create transfer
+	t, ok := r.transfers[key]
+	if !ok {
+		// check count, as every chunk of a non-empty payload carries at least
+		// one byte, the size also limits the number of chunks
+		if c.count > 1 && uint64(c.count) > c.size {
+			r.mutex.Unlock()
+			return ErrInvalidChunk
+		}
+
+		// check transfers
+		if r.MaxTransfers > 0 && len(r.transfers) >= r.MaxTransfers {
+			r.mutex.Unlock()
+			return ErrTooManyTransfers
+		}
+
+		t = &transfer{
+			topic:  msg.Topic,
+			count:  c.count,
+			size:   c.size,
+			chunks: make(map[uint32][]byte),
+		}
+		t.timer = time.AfterFunc(timeout, func() {
+			r.expire(key, t)
+		})
+		r.transfers[key] = t
+	}
+
+	// check consistency
+	if t.count != c.count || t.size != c.size {
+		r.mutex.Unlock()
+		return ErrInconsistentChunk
+	}
+
+	// ignore duplicate chunks
+	if _, ok := t.chunks[c.index]; ok {
+		r.mutex.Unlock()
+		return nil
+	}
+
+	// check accumulated size
+	if t.received+uint64(len(c.data)) > t.size {
+		r.mutex.Unlock()
+		return ErrInconsistentChunk
+	}
+
+	// add chunk
+	t.chunks[c.index] = append([]byte(nil), c.data...)
+	t.received += uint64(len(c.data))
+	if c.checksum != nil {
+		t.checksum = append([]byte(nil), c.checksum...)
+	}
+
+	// track the highest qos
+	if msg.QOS > t.qos {
+		t.qos = msg.QOS
+	}
+
+	// extend deadline
+	t.deadline = time.Now().Add(timeout)
+
+	// get progress
+	received, total := int64(t.received), int64(t.size)
+
+	// check completion
+	var done bool
+	if uint32(len(t.chunks)) == t.count {
+		// stop timer
+		t.timer.Stop()
+
+		// remove transfer
+		delete(r.transfers, key)
+
+		// remember completed transfer to ignore redelivered chunks
+		r.completed[key] = time.AfterFunc(timeout, func() {
+			r.mutex.Lock()
+			delete(r.completed, key)
+			r.mutex.Unlock()
+		})
+
+		done = true
+	}
+
+	// release mutex
+	r.mutex.Unlock()
+
+	// report progress
+	if r.Progress != nil {
+		r.Progress(c.id, received, total)
+	}
+
+	// finish transfer
+	if done {
+		r.finish(t)
+	}
+
+	return nil
+}
+
+// Pending returns the number of incomplete transfers.
+func (r *Receiver) Pending() int {
+	// acquire mutex
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.transfers)
+}
+
+func (r *Receiver) finish(t *transfer) {
+	// check received size
+	if t.received != t.size {
+		r.callback(nil, ErrChecksumMismatch)
+		return
+	}
+
+	// reassemble payload
+	payload := make([]byte, 0, t.size)
+	for i := uint32(0); i < t.count; i++ {
+		payload = append(payload, t.chunks[i]...)
+	}
+
+	// verify payload
+	sum := sha256.Sum256(payload)
+	if !bytes.Equal(sum[:], t.checksum) {
+		r.callback(nil, ErrChecksumMismatch)
+		return
+	}
+
+	// call callback
+	r.callback(&packet.Message{
+		Topic:   t.topic,
+		Payload: payload,
+		QOS:     t.qos,
+	}, nil)
+}
+
+func (r *Receiver) expire(key transferKey, t *transfer) {
+	// acquire mutex
+	r.mutex.Lock()
+
+	// check if transfer is still pending
+	if r.transfers[key] != t {
+		r.mutex.Unlock()
+		return
+	}
+
+	// reschedule if deadline has been extended
+	if remaining := time.Until(t.deadline); remaining > 0 {
+		t.timer.Reset(remaining)
+		r.mutex.Unlock()
+		return
+	}
+
+	// remove transfer
+	delete(r.transfers, key)
+
+	// release mutex
+	r.mutex.Unlock()
+
+	// report error
+	r.callback(nil, ErrTransferTimeout)
+}
+
+func (r *Receiver) callback(msg *packet.Message, err error) {
+	if r.Callback != nil {
+		r.Callback(msg, err)
+	}
+}
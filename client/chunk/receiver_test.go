@@ -0,0 +1,169 @@
+package chunk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiverTimeout(t *testing.T) {
+	publisher := &testPublisher{}
+
+	sender := NewSender(publisher)
+	sender.ChunkSize = 1
+
+	_, err := sender.Send("foo", []byte("foo"))
+	assert.NoError(t, err)
+
+	done := make(chan error, 1)
+
+	receiver := NewReceiver(func(msg *packet.Message, err error) {
+		assert.Nil(t, msg)
+		done <- err
+	})
+	receiver.Timeout = 10 * time.Millisecond
+
+	assert.NoError(t, receiver.Handle(publisher.messages[0]))
+	assert.Equal(t, 1, receiver.Pending())
+
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrTransferTimeout, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "missing timeout")
+	}
+
+	assert.Equal(t, 0, receiver.Pending())
+}
+
+func TestReceiverChecksumMismatch(t *testing.T) {
+	publisher := &testPublisher{}
+
+	_, err := NewSender(publisher).Send("foo", []byte("foo"))
+	assert.NoError(t, err)
+
+	// corrupt payload
+	payload := publisher.messages[0].Payload
+	payload[len(payload)-1] = 'x'
+
+	var result error
+
+	receiver := NewReceiver(func(msg *packet.Message, err error) {
+		assert.Nil(t, msg)
+		result = err
+	})
+
+	assert.NoError(t, receiver.Handle(publisher.messages[0]))
+	assert.Equal(t, ErrChecksumMismatch, result)
+}
+
+func TestReceiverErrors(t *testing.T) {
+	receiver := NewReceiver(nil)
+	receiver.MaxSize = 2
+
+	err := receiver.Handle(&packet.Message{Topic: "foo", Payload: []byte("foo")})
+	assert.Equal(t, ErrInvalidChunk, err)
+
+	publisher := &testPublisher{}
+
+	_, err = NewSender(publisher).Send("foo", []byte("foo"))
+	assert.NoError(t, err)
+
+	err = receiver.Handle(publisher.messages[0])
+	assert.Equal(t, ErrTransferTooLarge, err)
+
+	c := &chunk{id: NewID(), index: 0, count: 2, size: 2, data: []byte("f")}
+	assert.NoError(t, receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()}))
+
+	c.index = 1
+	c.size = 1
+	c.checksum = make([]byte, checksumLen)
+	err = receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.Equal(t, ErrInconsistentChunk, err)
+}
+
+func TestReceiverDeclaredSize(t *testing.T) {
+	var result error
+
+	receiver := NewReceiver(func(msg *packet.Message, err error) {
+		assert.Nil(t, msg)
+		result = err
+	})
+
+	c := &chunk{id: NewID(), index: 0, count: 1, size: 1 << 62, checksum: make([]byte, checksumLen), data: []byte("f")}
+	err := receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.Equal(t, ErrTransferTooLarge, err)
+	assert.Equal(t, 0, receiver.Pending())
+
+	receiver.MaxSize = 0
+
+	err = receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.NoError(t, err)
+	assert.Equal(t, ErrChecksumMismatch, result)
+}
+
+func TestReceiverMaxTransfers(t *testing.T) {
+	receiver := NewReceiver(nil)
+	receiver.MaxTransfers = 2
+
+	for i := 0; i < 2; i++ {
+		c := &chunk{id: NewID(), index: 0, count: 2, size: 2, data: []byte("f")}
+		assert.NoError(t, receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()}))
+	}
+	assert.Equal(t, 2, receiver.Pending())
+
+	c := &chunk{id: NewID(), index: 0, count: 2, size: 2, data: []byte("f")}
+	err := receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.Equal(t, ErrTooManyTransfers, err)
+	assert.Equal(t, 2, receiver.Pending())
+
+	receiver.MaxTransfers = 0
+
+	err = receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, receiver.Pending())
+}
+
+func TestReceiverChunkCount(t *testing.T) {
+	receiver := NewReceiver(nil)
+	receiver.MaxSize = 10
+
+	c := &chunk{id: NewID(), index: 0, count: 1 << 31, size: 10, data: []byte("f")}
+	err := receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.Equal(t, ErrInvalidChunk, err)
+	assert.Equal(t, 0, receiver.Pending())
+
+	c = &chunk{id: NewID(), index: 0, count: 11, size: 11, data: []byte("f")}
+	err = receiver.Handle(&packet.Message{Topic: "foo", Payload: c.encode()})
+	assert.Equal(t, ErrTransferTooLarge, err)
+	assert.Equal(t, 0, receiver.Pending())
+}
+
+func TestReceiverZeroValue(t *testing.T) {
+	publisher := &testPublisher{}
+
+	sender := NewSender(publisher)
+	sender.ChunkSize = 1
+
+	_, err := sender.Send("foo", []byte("foo"))
+	assert.NoError(t, err)
+
+	var result *packet.Message
+
+	var receiver Receiver
+	receiver.Callback = func(msg *packet.Message, err error) {
+		assert.NoError(t, err)
+		result = msg
+	}
+
+	assert.Equal(t, 0, receiver.Pending())
+
+	for _, msg := range publisher.messages {
+		assert.NoError(t, receiver.Handle(msg))
+	}
+
+	assert.Equal(t, []byte("foo"), result.Payload)
+}
@@ -0,0 +1,184 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"time"
+
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// A Publisher is used by the Sender to publish chunks. It is implemented by
+// client.Service.
+type Publisher interface {
+	PublishMessage(msg *packet.Message) client.GenericFuture
+}
+
+// A Sender splits payloads into chunks and publishes them.
+type Sender struct {
+	// The publisher used to publish chunks.
+	Publisher Publisher
+
+	// The maximum size of the data carried by a single chunk.
+	//
+	// Default: 64KB.
+	ChunkSize int
+
+	// The QOS level used to publish chunks.
+	//
+	// Default: 1.
+	QOS packet.QOS
+
+	// The number of chunks that may be published before waiting on the oldest
+	// chunk to be acknowledged.
+	//
+	// Default: 16.
+	Window int
+
+	// The time to wait for a single chunk to be acknowledged.
+	//
+	// Default: 30 seconds.
+	Timeout time.Duration
+
+	// The Progress callback is called after each acknowledged chunk with the
+	// number of acknowledged bytes and the total size of the payload.
+	Progress func(sent, total int64)
+}
+
+// NewSender returns a new Sender that publishes chunks using the specified
+// publisher.
+func NewSender(publisher Publisher) *Sender {
+	return &Sender{
+		Publisher: publisher,
+		ChunkSize: 64 * 1024, // 64KB
+		QOS:       1,
+		Window:    16,
+		Timeout:   30 * time.Second,
+	}
+}
+
+// Send will split the payload into chunks and publish them on the specified
+// topic. It returns the ID of the transfer once all chunks have been
+// acknowledged.
+func (s *Sender) Send(topic string, payload []byte) (ID, error) {
+	return s.SendReader(topic, bytes.NewReader(payload), int64(len(payload)))
+}
+
+// SendReader will read size bytes from the reader, split them into chunks and
+// publish them on the specified topic. It returns the ID of the transfer once
+// all chunks have been acknowledged. At most Window chunks are kept in memory.
+func (s *Sender) SendReader(topic string, reader io.Reader, size int64) (ID, error) {
+	// get chunk size
+	chunkSize := int64(s.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = 64 * 1024
+	}
+
+	// get window
+	window := s.Window
+	if window <= 0 {
+		window = 1
+	}
+
+	// calculate number of chunks
+	count := (size + chunkSize - 1) / chunkSize
+	if count == 0 {
+		count = 1
+	}
+
+	// prepare transfer
+	id := NewID()
+	hash := sha256.New()
+
+	// prepare pending futures and their sizes
+	var pending []client.GenericFuture
+	var lengths []int64
+	var sent int64
+
+	// prepare await function
+	await := func() error {
+		// wait for oldest future
+		err := pending[0].Wait(s.Timeout)
+		if err != nil {
+			return err
+		}
+
+		// update counter
+		sent += lengths[0]
+
+		// remove future
+		pending = pending[1:]
+		lengths = lengths[1:]
+
+		// report progress
+		if s.Progress != nil {
+			s.Progress(sent, size)
+		}
+
+		return nil
+	}
+
+	// publish all chunks
+	for i := int64(0); i < count; i++ {
+		// get data length
+		length := chunkSize
+		if rest := size - i*chunkSize; rest < length {
+			length = rest
+		}
+
+		// read data
+		data := make([]byte, length)
+		_, err := io.ReadFull(reader, data)
+		if err != nil {
+			return id, err
+		}
+
+		// update hash
+		hash.Write(data)
+
+		// prepare chunk
+		c := &chunk{
+			id:    id,
+			index: uint32(i),
+			count: uint32(count),
+			size:  uint64(size),
+			data:  data,
+		}
+
+		// set checksum on final chunk
+		if c.final() {
+			c.checksum = hash.Sum(nil)
+		}
+
+		// publish chunk
+		f := s.Publisher.PublishMessage(&packet.Message{
+			Topic:   topic,
+			Payload: c.encode(),
+			QOS:     s.QOS,
+		})
+
+		// add future
+		pending = append(pending, f)
+		lengths = append(lengths, length)
+
+		// await oldest future if window is full
+		if len(pending) >= window {
+			err = await()
+			if err != nil {
+				return id, err
+			}
+		}
+	}
+
+	// await remaining futures
+	for len(pending) > 0 {
+		err := await()
+		if err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
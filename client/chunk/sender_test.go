@@ -0,0 +1,129 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/client/future"
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPublisher struct {
+	messages []*packet.Message
+}
+
+func (p *testPublisher) PublishMessage(msg *packet.Message) client.GenericFuture {
+	p.messages = append(p.messages, msg)
+
+	f := future.New()
+	f.Complete(nil)
+
+	return f
+}
+
+func TestSenderReceiver(t *testing.T) {
+	payload := make([]byte, 10000)
+	rand.Read(payload)
+
+	publisher := &testPublisher{}
+
+	var progress []int64
+
+	sender := NewSender(publisher)
+	sender.ChunkSize = 1000
+	sender.Window = 3
+	sender.Progress = func(sent, total int64) {
+		assert.Equal(t, int64(10000), total)
+		progress = append(progress, sent)
+	}
+
+	id, err := sender.Send("foo", payload)
+	assert.NoError(t, err)
+	assert.Len(t, publisher.messages, 10)
+	assert.Equal(t, int64(10000), progress[len(progress)-1])
+
+	var result *packet.Message
+
+	receiver := NewReceiver(func(msg *packet.Message, err error) {
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+		result = msg
+	})
+
+	var received int64
+	receiver.Progress = func(i ID, r, total int64) {
+		assert.Equal(t, id, i)
+		assert.Equal(t, int64(10000), total)
+		received = r
+	}
+
+	// shuffle and duplicate messages
+	messages := append(publisher.messages, publisher.messages[:5]...)
+	rand.Shuffle(len(messages), func(i, j int) {
+		messages[i], messages[j] = messages[j], messages[i]
+	})
+
+	for _, msg := range messages {
+		assert.NoError(t, receiver.Handle(msg))
+	}
+
+	assert.Equal(t, int64(10000), received)
+	assert.Equal(t, 0, receiver.Pending())
+	assert.Equal(t, &packet.Message{
+		Topic:   "foo",
+		Payload: payload,
+		QOS:     1,
+	}, result)
+}
+
+func TestSenderEmptyPayload(t *testing.T) {
+	publisher := &testPublisher{}
+
+	_, err := NewSender(publisher).Send("foo", nil)
+	assert.NoError(t, err)
+	assert.Len(t, publisher.messages, 1)
+
+	var result *packet.Message
+
+	receiver := NewReceiver(func(msg *packet.Message, err error) {
+		assert.NoError(t, err)
+		result = msg
+	})
+
+	assert.NoError(t, receiver.Handle(publisher.messages[0]))
+	assert.NotNil(t, result)
+	assert.Empty(t, result.Payload)
+}
+
+func TestSenderReaderError(t *testing.T) {
+	publisher := &testPublisher{}
+
+	sender := NewSender(publisher)
+	sender.ChunkSize = 2
+
+	_, err := sender.SendReader("foo", bytes.NewReader([]byte("foo")), 4)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Len(t, publisher.messages, 1)
+}
+
+func TestSenderPublishError(t *testing.T) {
+	sender := NewSender(publisherFunc(func(*packet.Message) client.GenericFuture {
+		f := future.New()
+		f.Cancel(nil)
+		return f
+	}))
+
+	_, err := sender.Send("foo", []byte("foo"))
+	assert.Equal(t, future.ErrCanceled, err)
+}
+
+type publisherFunc func(*packet.Message) client.GenericFuture
+
+func (fn publisherFunc) PublishMessage(msg *packet.Message) client.GenericFuture {
+	return fn(msg)
+}
@@ -5,6 +5,7 @@ package client
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"sync"
 	"sync/atomic"
@@ -105,6 +106,15 @@ type Client struct {
 	// inside the callback will deadlock the client.
 	Callback func(msg *packet.Message, err error) error
 
+	// The StreamCallback is called instead of the Callback for received
+	// messages with a QOS of 0 or 1 that exceed the configured stream
+	// threshold. The message does not carry a payload, instead the payload of
+	// the specified length should be read from the provided reader before
+	// returning. Unread data is discarded. Messages are passed to the Callback
+	// with a buffered payload if the StreamCallback is missing. The same
+	// execution semantics as for the Callback apply.
+	StreamCallback func(msg *packet.Message, payload io.Reader, length int) error
+
 	// The logger that is used to log low level information about packets
 	// that have been successfully sent and received and details about the
 	// automatic keep alive handler.
@@ -186,6 +196,9 @@ func (c *Client) Connect(config *Config) (ConnectFuture, error) {
 	// set read limit
 	c.conn.SetReadLimit(c.config.ReadLimit)
 
+	// set stream threshold
+	c.conn.SetStreamThreshold(c.config.StreamThreshold)
+
 	// set max write delay
 	c.conn.SetMaxWriteDelay(c.config.MaxWriteDelay)
 
@@ -302,6 +315,143 @@ func (c *Client) PublishMessage(msg *packet.Message) (GenericFuture, error) {
 	return publishFuture, nil
 }
 
+// PublishStream will send a Publish packet with a payload of the specified
+// length that is read from the provided reader. Only the headers of the packet
+// are buffered. It will return a PublishFuture that gets completed once the
+// quality of service flow has been completed.
+//
+// Note: The packet is not stored in the session as the payload cannot be read
+// again. Therefore, messages with a QOS greater than 0 are not redelivered if
+// the connection is lost before the flow has been completed.
+func (c *Client) PublishStream(topic string, payload io.Reader, length int, qos packet.QOS, retain bool) (GenericFuture, error) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// check if connected
+	if atomic.LoadUint32(&c.state) != clientConnected {
+		return nil, ErrClientNotConnected
+	}
+
+	// allocate publish packet
+	stream := packet.NewPublishStream()
+	stream.Header.Message = packet.Message{
+		Topic:  topic,
+		QOS:    qos,
+		Retain: retain,
+	}
+	stream.Length = length
+	stream.Payload = payload
+
+	// set packet id
+	if qos > 0 {
+		stream.Header.ID = c.Session.NextID()
+	}
+
+	// create future
+	publishFuture := future.New()
+
+	// store future
+	c.futureStore.Put(stream.Header.ID, publishFuture)
+
+	// send packet
+	err := c.send(stream, false)
+	if err != nil {
+		return nil, c.cleanup(err, false, false)
+	}
+
+	// complete and remove qos 0 future
+	if qos == 0 {
+		publishFuture.Complete(nil)
+		c.futureStore.Delete(stream.Header.ID)
+	}
+
+	return publishFuture, nil
+}
+
+// PublishBatch will send a Publish packet for each of the passed messages. All
+// packets are written asynchronously and the underlying buffer is flushed once
+// after the last packet. It will return a GenericFuture that gets completed
+// once the quality of service flows of all messages have been completed.
+func (c *Client) PublishBatch(msgs []*packet.Message) (GenericFuture, error) {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// check if connected
+	if atomic.LoadUint32(&c.state) != clientConnected {
+		return nil, ErrClientNotConnected
+	}
+
+	// prepare packets and futures
+	publishes := make([]*packet.Publish, 0, len(msgs))
+	futures := make([]*future.Future, 0, len(msgs))
+
+	// prepare all packets and register futures before sending
+	for _, msg := range msgs {
+		// allocate publish packet
+		publish := packet.NewPublish()
+		publish.Message = *msg
+
+		// create future
+		publishFuture := future.New()
+
+		// add packet and future
+		publishes = append(publishes, publish)
+		futures = append(futures, publishFuture)
+
+		// handle packets with at least qos 1
+		if msg.QOS > 0 {
+			// set packet id
+			publish.ID = c.Session.NextID()
+
+			// store future
+			c.futureStore.Put(publish.ID, publishFuture)
+
+			// store packet
+			err := c.Session.SavePacket(session.Outgoing, publish)
+			if err != nil {
+				c.discardBatch(publishes, futures)
+				return nil, c.cleanup(err, true, false)
+			}
+		}
+	}
+
+	// send packets and flush after the last one
+	for i, publish := range publishes {
+		err := c.send(publish, i < len(publishes)-1)
+		if err != nil {
+			return nil, c.cleanup(err, false, false)
+		}
+	}
+
+	// complete qos 0 futures
+	for i, publish := range publishes {
+		if publish.Message.QOS == 0 {
+			futures[i].Complete(nil)
+		}
+	}
+
+	return future.Combine(futures...), nil
+}
+
+// will cancel and remove the futures and stored packets of an unsent batch
+func (c *Client) discardBatch(publishes []*packet.Publish, futures []*future.Future) {
+	for i, publish := range publishes {
+		// cancel future
+		futures[i].Cancel(nil)
+
+		// skip qos 0 packets
+		if publish.Message.QOS == 0 {
+			continue
+		}
+
+		// remove future and packet
+		c.futureStore.Delete(publish.ID)
+		_ = c.Session.DeletePacket(session.Outgoing, publish.ID)
+	}
+}
+
 // Subscribe will send a Subscribe packet containing one topic to subscribe. It
 // will return a SubscribeFuture that gets completed once a Suback packet has
 // been received.
@@ -324,6 +474,16 @@ func (c *Client) SubscribeMultiple(subscriptions []packet.Subscription) (Subscri
 		return nil, ErrClientNotConnected
 	}
 
+	// validate filters if requested
+	if c.config.ValidateSubs {
+		for _, subscription := range subscriptions {
+			_, err := c.config.TopicProfile.Parse(subscription.Topic, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// allocate subscribe packet
 	subscribe := packet.NewSubscribe()
 	subscribe.ID = c.Session.NextID()
@@ -416,6 +576,21 @@ func (c *Client) Disconnect(timeout ...time.Duration) error {
 	return c.end(err, true)
 }
 
+// Stats returns the traffic counters of the current or last connection. The
+// counters are zero if the client has not yet been connected.
+func (c *Client) Stats() transport.Stats {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// check connection
+	if c.conn == nil {
+		return transport.Stats{}
+	}
+
+	return c.conn.Stats()
+}
+
 // Close closes the client immediately without sending a Disconnect packet and
 // waiting for outgoing transmissions to finish.
 func (c *Client) Close() error {
@@ -486,7 +661,9 @@ func (c *Client) processor() error {
 		case *packet.Pingresp:
 			c.tracker.Pong()
 		case *packet.Publish:
-			err = c.processPublish(typedPkt)
+			err = c.processPublish(typedPkt, nil)
+		case *packet.PublishStream:
+			err = c.processPublishStream(typedPkt)
 		case *packet.Puback:
 			err = c.processPubackAndPubcomp(typedPkt.ID)
 		case *packet.Pubcomp:
@@ -608,10 +785,34 @@ func (c *Client) processUnsuback(unsuback *packet.Unsuback) error {
 	return nil
 }
 
-// handle an incoming Publish packet
-func (c *Client) processPublish(publish *packet.Publish) error {
+// handle an incoming streamed Publish packet
+func (c *Client) processPublishStream(stream *packet.PublishStream) error {
+	// buffer payload if no stream callback is available or the message must be
+	// stored in the session
+	if c.StreamCallback == nil || stream.Header.Message.QOS == 2 {
+		publish, err := stream.Buffer()
+		if err != nil {
+			return c.die(err, true)
+		}
+
+		return c.processPublish(publish, nil)
+	}
+
+	return c.processPublish(&stream.Header, stream)
+}
+
+// handle an incoming Publish packet with an optional stream
+func (c *Client) processPublish(publish *packet.Publish, stream *packet.PublishStream) error {
+	// call stream callback for streamed messages
+	if stream != nil {
+		err := c.StreamCallback(&publish.Message, stream.Payload, stream.Length)
+		if err != nil {
+			return c.die(err, true)
+		}
+	}
+
 	// call callback for unacknowledged and directly acknowledged messages
-	if publish.Message.QOS <= 1 {
+	if publish.Message.QOS <= 1 && stream == nil {
 		if c.Callback != nil {
 			err := c.Callback(&publish.Message, nil)
 			if err != nil {
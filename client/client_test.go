@@ -1,8 +1,11 @@
 package client
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -11,6 +14,7 @@ import (
 	"github.com/256dpi/gomqtt/client/future"
 	"github.com/256dpi/gomqtt/packet"
 	"github.com/256dpi/gomqtt/session"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 	"github.com/256dpi/gomqtt/transport/flow"
 
@@ -89,6 +93,38 @@ func TestClientConnect(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestClientStats(t *testing.T) {
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	c := New()
+	c.Callback = errorCallback(t)
+
+	assert.Equal(t, transport.Stats{}, c.Stats())
+
+	connectFuture, err := c.Connect(NewConfig("tcp://localhost:" + port))
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.PacketsSent[packet.CONNECT])
+	assert.Equal(t, uint64(1), stats.PacketsReceived[packet.CONNACK])
+	assert.Equal(t, uint64(4), stats.BytesReceived)
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	stats = c.Stats()
+	assert.Equal(t, uint64(1), stats.PacketsSent[packet.DISCONNECT])
+
+	safeReceive(done)
+}
+
 func TestClientConnectCustomDialer(t *testing.T) {
 	broker := flow.New().
 		Receive(connectPacket()).
@@ -188,6 +224,10 @@ func TestClientNotConnected(t *testing.T) {
 	assert.Nil(t, future3)
 	assert.Equal(t, ErrClientNotConnected, err)
 
+	future4, err := c.PublishBatch([]*packet.Message{{Topic: "test"}})
+	assert.Nil(t, future4)
+	assert.Equal(t, ErrClientNotConnected, err)
+
 	err = c.Disconnect()
 	assert.Equal(t, ErrClientNotConnected, err)
 
@@ -573,6 +613,233 @@ func TestClientPublishSubscribeQOS2(t *testing.T) {
 	assert.Equal(t, 0, len(out))
 }
 
+func TestClientPublishBatch(t *testing.T) {
+	publish1 := packet.NewPublish()
+	publish1.Message.Topic = "test1"
+	publish1.Message.Payload = []byte("test1")
+
+	publish2 := packet.NewPublish()
+	publish2.Message.Topic = "test2"
+	publish2.Message.Payload = []byte("test2")
+	publish2.Message.QOS = 1
+	publish2.ID = 1
+
+	publish3 := packet.NewPublish()
+	publish3.Message.Topic = "test3"
+	publish3.Message.Payload = []byte("test3")
+	publish3.Message.QOS = 1
+	publish3.ID = 2
+
+	puback2 := packet.NewPuback()
+	puback2.ID = 1
+
+	puback3 := packet.NewPuback()
+	puback3.ID = 2
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish1, publish2, publish3).
+		Send(puback2, puback3).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	c := New()
+	c.Callback = errorCallback(t)
+
+	config := NewConfig("tcp://localhost:" + port)
+	config.MaxWriteDelay = time.Minute
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	publishFuture, err := c.PublishBatch([]*packet.Message{
+		&publish1.Message,
+		&publish2.Message,
+		&publish3.Message,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, publishFuture.Wait(1*time.Second))
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	out, err := c.Session.AllPackets(session.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(out))
+}
+
+type failingSession struct {
+	*session.MemorySession
+	saves int
+}
+
+func (s *failingSession) SavePacket(dir session.Direction, pkt packet.Generic) error {
+	// fail after the allowed saves
+	if s.saves == 0 {
+		return errors.New("failed")
+	}
+	s.saves--
+
+	return s.MemorySession.SavePacket(dir, pkt)
+}
+
+func TestClientPublishBatchSessionError(t *testing.T) {
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	c := New()
+	c.Session = &failingSession{MemorySession: session.NewMemorySession()}
+	c.futureStore.Protect(true)
+
+	config := NewConfig("tcp://localhost:" + port)
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	c.Session.(*failingSession).saves = 1
+
+	publishFuture, err := c.PublishBatch([]*packet.Message{
+		{Topic: "test1"},
+		{Topic: "test2", QOS: 1},
+		{Topic: "test3", QOS: 1},
+	})
+	assert.Error(t, err)
+	assert.Nil(t, publishFuture)
+
+	safeReceive(done)
+
+	assert.Empty(t, c.futureStore.All())
+
+	out, err := c.Session.AllPackets(session.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(out))
+}
+
+func TestClientPublishStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 1000)
+
+	publish1 := packet.NewPublish()
+	publish1.Message.Topic = "test1"
+	publish1.Message.Payload = payload
+	publish1.Message.QOS = 1
+	publish1.ID = 1
+
+	puback1 := packet.NewPuback()
+	puback1.ID = 1
+
+	publish2 := packet.NewPublish()
+	publish2.Message.Topic = "test2"
+	publish2.Message.Payload = payload
+	publish2.Message.QOS = 1
+	publish2.ID = 2
+
+	puback2 := packet.NewPuback()
+	puback2.ID = 2
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish1).
+		Send(puback1).
+		Send(publish2).
+		Receive(puback2).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	wait := make(chan struct{})
+
+	c := New()
+	c.Callback = errorCallback(t)
+	c.StreamCallback = func(msg *packet.Message, reader io.Reader, length int) error {
+		assert.Equal(t, "test2", msg.Topic)
+		assert.Equal(t, packet.QOS(1), msg.QOS)
+		assert.Nil(t, msg.Payload)
+		assert.Equal(t, len(payload), length)
+
+		data, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, data)
+
+		close(wait)
+
+		return nil
+	}
+
+	config := NewConfig("tcp://localhost:" + port)
+	config.StreamThreshold = 100
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	publishFuture, err := c.PublishStream("test1", bytes.NewReader(payload), len(payload), 1, false)
+	assert.NoError(t, err)
+	assert.NoError(t, publishFuture.Wait(1*time.Second))
+
+	safeReceive(wait)
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	out, err := c.Session.AllPackets(session.Outgoing)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(out))
+}
+
+func TestClientPublishStreamBuffered(t *testing.T) {
+	publish := packet.NewPublish()
+	publish.Message.Topic = "test"
+	publish.Message.Payload = bytes.Repeat([]byte("x"), 1000)
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Send(publish).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	wait := make(chan struct{})
+
+	c := New()
+	c.Callback = func(msg *packet.Message, err error) error {
+		assert.NoError(t, err)
+		assert.Equal(t, publish.Message, *msg)
+		close(wait)
+		return nil
+	}
+
+	config := NewConfig("tcp://localhost:" + port)
+	config.StreamThreshold = 100
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	safeReceive(wait)
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
 func TestClientUnsubscribe(t *testing.T) {
 	unsubscribe := packet.NewUnsubscribe()
 	unsubscribe.Topics = []string{"test"}
@@ -610,6 +877,55 @@ func TestClientUnsubscribe(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestClientSubscribeTopicProfile(t *testing.T) {
+	subscribe := packet.NewSubscribe()
+	subscribe.Subscriptions = []packet.Subscription{{Topic: "foo/+"}}
+	subscribe.ID = 1
+
+	suback := packet.NewSuback()
+	suback.ReturnCodes = []packet.QOS{0}
+	suback.ID = 1
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(subscribe).
+		Send(suback).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	c := New()
+	c.Callback = errorCallback(t)
+
+	config := NewConfig("tcp://localhost:" + port)
+	config.TopicProfile = &topic.Profile{
+		MaxLevels: 2,
+	}
+
+	connectFuture, err := c.Connect(config)
+	assert.NoError(t, err)
+	assert.NoError(t, connectFuture.Wait(1*time.Second))
+
+	subscribeFuture, err := c.Subscribe("foo/bar/#", 0)
+	assert.True(t, errors.Is(err, topic.ErrTooManyLevels))
+	assert.Nil(t, subscribeFuture)
+
+	subscribeFuture, err = c.Subscribe("foo/#/bar", 0)
+	assert.Equal(t, topic.ErrWildcards, err)
+	assert.Nil(t, subscribeFuture)
+
+	subscribeFuture, err = c.Subscribe("foo/+", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, subscribeFuture.Wait(1*time.Second))
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
 func TestClientHardDisconnect(t *testing.T) {
 	connect := connectPacket()
 	connect.ClientID = "test"
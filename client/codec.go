@@ -0,0 +1,278 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/topic"
+)
+
+// ErrUnknownCodec is returned if a codec has not been registered.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// ErrUnsupportedValue is returned by a codec if a value cannot be handled.
+var ErrUnsupportedValue = errors.New("unsupported value")
+
+// A CodecError wraps an error returned by a codec.
+type CodecError struct {
+	// The topic of the message.
+	Topic string
+
+	// The underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CodecError) Error() string {
+	return fmt.Sprintf("codec error on topic %q: %s", e.Topic, e.Err.Error())
+}
+
+// Unwrap returns the underlying error.
+func (e *CodecError) Unwrap() error {
+	return e.Err
+}
+
+// A Codec encodes values to and decodes values from message payloads.
+type Codec interface {
+	// Encode should return the encoded value.
+	Encode(value interface{}) ([]byte, error)
+
+	// Decode should decode the payload into the value.
+	Decode(payload []byte, value interface{}) error
+}
+
+// JSONCodec encodes and decodes values using JSON.
+type JSONCodec struct{}
+
+// Encode implements the Codec interface.
+func (JSONCodec) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Decode implements the Codec interface.
+func (JSONCodec) Decode(payload []byte, value interface{}) error {
+	return json.Unmarshal(payload, value)
+}
+
+// RawCodec passes through byte slices and strings.
+type RawCodec struct{}
+
+// Encode implements the Codec interface.
+func (RawCodec) Encode(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+
+	return nil, ErrUnsupportedValue
+}
+
+// Decode implements the Codec interface.
+func (RawCodec) Decode(payload []byte, value interface{}) error {
+	switch v := value.(type) {
+	case *[]byte:
+		*v = append([]byte(nil), payload...)
+		return nil
+	case *string:
+		*v = string(payload)
+		return nil
+	}
+
+	return ErrUnsupportedValue
+}
+
+type codecFilter struct {
+	filter string
+	name   string
+}
+
+// A CodecRegistry manages named codecs and selects them by topic filter.
+type CodecRegistry struct {
+	codecs  map[string]Codec
+	filters *topic.Tree
+	def     string
+	mutex   sync.RWMutex
+}
+
+// NewCodecRegistry returns a new registry that has the "json" and "raw" codecs
+// registered and uses the "json" codec by default.
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{
+		codecs: map[string]Codec{
+			"json": JSONCodec{},
+			"raw":  RawCodec{},
+		},
+		filters: topic.NewStandardTree(),
+		def:     "json",
+	}
+}
+
+// Register will register the codec using the specified name. An existing codec
+// with the same name is replaced.
+func (r *CodecRegistry) Register(name string, codec Codec) {
+	// acquire mutex
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// add codec
+	r.codecs[name] = codec
+}
+
+// SetDefault will set the codec that is used for topics without a matching
+// filter.
+func (r *CodecRegistry) SetDefault(name string) error {
+	// acquire mutex
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// check codec
+	if _, ok := r.codecs[name]; !ok {
+		return ErrUnknownCodec
+	}
+
+	// set default
+	r.def = name
+
+	return nil
+}
+
+// Use will select the named codec for all topics that match the specified
+// filter. If multiple filters match a topic, the most specific filter is used.
+func (r *CodecRegistry) Use(filter, name string) error {
+	// acquire mutex
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	// check codec
+	if _, ok := r.codecs[name]; !ok {
+		return ErrUnknownCodec
+	}
+
+	// set filter
+	r.filters.Set(filter, codecFilter{filter: filter, name: name})
+
+	return nil
+}
+
+// Lookup returns the codec that is selected for the specified topic.
+func (r *CodecRegistry) Lookup(topic string) Codec {
+	// acquire mutex
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	// get codec name
+	name := r.def
+	if cf, ok := mostSpecific(r.filters.Match(topic)); ok {
+		name = cf.name
+	}
+
+	return r.codecs[name]
+}
+
+// returns the codec filter with the most specific filter
+func mostSpecific(values []interface{}) (codecFilter, bool) {
+	// find most specific filter
+	var best codecFilter
+	for i, value := range values {
+		cf := value.(codecFilter)
+		if i == 0 || topic.MoreSpecific(cf.filter, best.filter) {
+			best = cf
+		}
+	}
+
+	return best, len(values) > 0
+}
+
+// Encode will encode the value using the codec selected for the topic.
+func (r *CodecRegistry) Encode(topic string, value interface{}) ([]byte, error) {
+	// encode value
+	payload, err := r.Lookup(topic).Encode(value)
+	if err != nil {
+		return nil, &CodecError{Topic: topic, Err: err}
+	}
+
+	return payload, nil
+}
+
+// Decode will decode the payload into the value using the codec selected for
+// the topic.
+func (r *CodecRegistry) Decode(topic string, payload []byte, value interface{}) error {
+	// decode value
+	err := r.Lookup(topic).Decode(payload, value)
+	if err != nil {
+		return &CodecError{Topic: topic, Err: err}
+	}
+
+	return nil
+}
+
+var messageType = reflect.TypeOf((*packet.Message)(nil))
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+type valueHandler struct {
+	typ reflect.Type
+	ptr bool
+	fn  reflect.Value
+}
+
+// newValueHandler validates the function and prepares a handler
+func newValueHandler(fn interface{}) *valueHandler {
+	// get function value and type
+	value := reflect.ValueOf(fn)
+	typ := value.Type()
+
+	// check signature
+	if typ.Kind() != reflect.Func || typ.NumIn() != 2 || typ.NumOut() != 1 ||
+		typ.In(0) != messageType || typ.Out(0) != errorType {
+		panic("expected handler of type func(*packet.Message, T) error")
+	}
+
+	// get value type
+	valueType := typ.In(1)
+	ptr := valueType.Kind() == reflect.Ptr
+	if ptr {
+		valueType = valueType.Elem()
+	}
+
+	return &valueHandler{
+		typ: valueType,
+		ptr: ptr,
+		fn:  value,
+	}
+}
+
+// decode allocates a new value and decodes the message into it
+func (h *valueHandler) decode(codecs *CodecRegistry, msg *packet.Message) (reflect.Value, error) {
+	// allocate value
+	value := reflect.New(h.typ)
+
+	// decode payload
+	err := codecs.Decode(msg.Topic, msg.Payload, value.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	// dereference value
+	if !h.ptr {
+		value = value.Elem()
+	}
+
+	return value, nil
+}
+
+// call calls the function with the message and decoded value
+func (h *valueHandler) call(msg *packet.Message, value reflect.Value) error {
+	// call function
+	ret := h.fn.Call([]reflect.Value{reflect.ValueOf(msg), value})[0]
+	if !ret.IsNil() {
+		return ret.Interface().(error)
+	}
+
+	return nil
+}
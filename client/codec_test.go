@@ -0,0 +1,174 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport/flow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testValue struct {
+	Foo string `json:"foo"`
+}
+
+func TestCodecRegistry(t *testing.T) {
+	r := NewCodecRegistry()
+
+	payload, err := r.Encode("foo", testValue{Foo: "bar"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"foo":"bar"}`, string(payload))
+
+	var value testValue
+	err = r.Decode("foo", payload, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, testValue{Foo: "bar"}, value)
+
+	err = r.Decode("foo", []byte("foo"), &value)
+	assert.Error(t, err)
+	assert.IsType(t, &CodecError{}, err)
+
+	assert.Equal(t, ErrUnknownCodec, r.Use("raw/#", "foo"))
+	assert.NoError(t, r.Use("raw/#", "raw"))
+	assert.Equal(t, RawCodec{}, r.Lookup("raw/foo"))
+	assert.Equal(t, JSONCodec{}, r.Lookup("foo"))
+
+	payload, err = r.Encode("raw/foo", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bar"), payload)
+
+	var str string
+	err = r.Decode("raw/foo", []byte("bar"), &str)
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", str)
+
+	_, err = r.Encode("raw/foo", 42)
+	assert.Equal(t, &CodecError{Topic: "raw/foo", Err: ErrUnsupportedValue}, err)
+
+	assert.Equal(t, ErrUnknownCodec, r.SetDefault("foo"))
+	assert.NoError(t, r.SetDefault("raw"))
+	assert.Equal(t, RawCodec{}, r.Lookup("foo"))
+
+	r.Register("raw", JSONCodec{})
+	assert.Equal(t, JSONCodec{}, r.Lookup("foo"))
+}
+
+func TestCodecRegistryPrecedence(t *testing.T) {
+	r := NewCodecRegistry()
+
+	assert.NoError(t, r.Use("sensors/+/temp", "json"))
+	assert.NoError(t, r.Use("#", "raw"))
+	assert.NoError(t, r.Use("sensors/#", "raw"))
+
+	assert.Equal(t, JSONCodec{}, r.Lookup("sensors/a/temp"))
+	assert.Equal(t, RawCodec{}, r.Lookup("sensors/a/humidity"))
+	assert.Equal(t, RawCodec{}, r.Lookup("foo"))
+
+	assert.NoError(t, r.Use("sensors/a/temp", "raw"))
+	assert.Equal(t, RawCodec{}, r.Lookup("sensors/a/temp"))
+	assert.Equal(t, JSONCodec{}, r.Lookup("sensors/b/temp"))
+}
+
+func TestValueHandler(t *testing.T) {
+	r := NewCodecRegistry()
+	msg := &packet.Message{Topic: "foo", Payload: []byte(`{"foo":"bar"}`)}
+
+	var ptr *testValue
+	h := newValueHandler(func(m *packet.Message, v *testValue) error {
+		assert.Equal(t, msg, m)
+		ptr = v
+		return nil
+	})
+
+	value, err := h.decode(r, msg)
+	assert.NoError(t, err)
+	assert.NoError(t, h.call(msg, value))
+	assert.Equal(t, &testValue{Foo: "bar"}, ptr)
+
+	h = newValueHandler(func(m *packet.Message, v testValue) error {
+		return errors.New(v.Foo)
+	})
+
+	value, err = h.decode(r, msg)
+	assert.NoError(t, err)
+	assert.EqualError(t, h.call(msg, value), "bar")
+
+	_, err = h.decode(r, &packet.Message{Topic: "foo", Payload: []byte("foo")})
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		newValueHandler(func(v testValue) error { return nil })
+	})
+}
+
+func TestServiceValues(t *testing.T) {
+	publish := packet.NewPublish()
+	publish.Message.Topic = "test"
+	publish.Message.Payload = []byte(`{"foo":"bar"}`)
+
+	invalid := packet.NewPublish()
+	invalid.Message.Topic = "test"
+	invalid.Message.Payload = []byte("foo")
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Send(invalid).
+		Send(publish).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	online := make(chan struct{})
+	message := make(chan struct{})
+	errs := make(chan error, 2)
+	offline := make(chan struct{})
+
+	s := NewService()
+
+	s.OnlineCallback = func(resumed bool) {
+		close(online)
+	}
+
+	s.OfflineCallback = func() {
+		close(offline)
+	}
+
+	s.MessageCallback = func(msg *packet.Message) error {
+		assert.Fail(t, "unexpected message")
+		return nil
+	}
+
+	s.ErrorCallback = func(err error) {
+		errs <- err
+	}
+
+	s.HandleValue("test", func(msg *packet.Message, value *testValue) error {
+		assert.Equal(t, "bar", value.Foo)
+		close(message)
+		return nil
+	})
+
+	s.Start(NewConfig("tcp://localhost:" + port))
+
+	safeReceive(online)
+
+	assert.Error(t, s.PublishValue("test", make(chan int), 0, false).Wait(1*time.Second))
+	assert.IsType(t, &CodecError{}, <-errs)
+
+	assert.NoError(t, s.PublishValue("test", testValue{Foo: "bar"}, 0, false).Wait(1*time.Second))
+
+	assert.IsType(t, &CodecError{}, <-errs)
+
+	safeReceive(message)
+
+	s.Stop(true)
+
+	safeReceive(offline)
+	safeReceive(done)
+}
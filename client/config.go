@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/topic"
 	"github.com/256dpi/gomqtt/transport"
 )
 
@@ -32,12 +33,22 @@ type Config struct {
 	// Will message is registered on the broker upon connect if set.
 	WillMessage *packet.Message
 
-	// ValidateSubs will cause the client to fail if subscriptions failed.
+	// ValidateSubs will cause the client to fail if subscriptions failed. It
+	// will also cause subscriptions with filters that are invalid according
+	// to the TopicProfile to be rejected before they are sent.
 	ValidateSubs bool
 
+	// TopicProfile defines the rules that filters are validated against if
+	// ValidateSubs is set. If missing, only the rules of topic.Parse apply.
+	TopicProfile *topic.Profile
+
 	// ReadLimit defines the maximum size of a packet that can be received.
 	ReadLimit int64
 
+	// StreamThreshold defines the length above which received messages are
+	// passed to the StreamCallback if available. Zero disables streaming.
+	StreamThreshold int64
+
 	// MaxWriteDelay defines the maximum allowed delay when flushing the
 	// underlying buffered writer.
 	MaxWriteDelay time.Duration
@@ -0,0 +1,516 @@
+// Package envelope implements a client middleware that encrypts and signs
+// message payloads end-to-end.
+package envelope
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/topic"
+)
+
+// ErrMalformedEnvelope is returned if an envelope could not be decoded.
+var ErrMalformedEnvelope = errors.New("malformed envelope")
+
+// ErrUnsignedMessage is returned if an unsigned message has been rejected.
+var ErrUnsignedMessage = errors.New("unsigned message")
+
+// ErrInvalidSignature is returned if the signature of a message is invalid.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrDecryptionFailed is returned if the payload of a message could not be
+// decrypted.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// ErrInvalidKeyID is returned if a key ID is empty or longer than 255 bytes.
+var ErrInvalidKeyID = errors.New("invalid key id")
+
+// ErrInvalidKey is returned if a signing or verification key has an invalid
+// length.
+var ErrInvalidKey = errors.New("invalid key")
+
+// ErrUnexpectedKey is returned if an incoming message uses a key that is not
+// allowed by the rule that matches the message topic or lacks a protection
+// that is required by the rule.
+var ErrUnexpectedKey = errors.New("unexpected key")
+
+// An UnknownKeyError is returned if a key ID is not available.
+type UnknownKeyError struct {
+	ID string
+}
+
+// Error implements the error interface.
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("unknown key %q", e.ID)
+}
+
+// A Policy defines how incoming messages that could not be verified are
+// handled.
+type Policy int
+
+const (
+	// Reject will drop the message and report an error.
+	Reject Policy = iota
+
+	// Drop will silently drop the message.
+	Drop
+
+	// Accept will pass on the message unchanged.
+	Accept
+)
+
+// A Rule selects the keys used to protect messages published on topics that
+// match the rule's filter. An empty key ID disables the respective protection.
+type Rule struct {
+	// The ID of the encryption key.
+	EncryptionKey string
+
+	// The ID of the signing key.
+	SigningKey string
+}
+
+type protection struct {
+	filter string
+	rule   Rule
+}
+
+var magic = []byte{0x00, 'E', 'N', 'V'}
+
+const version byte = 1
+
+const (
+	flagEncrypted byte = 1 << iota
+	flagSigned
+)
+
+// The Envelope is a client.Middleware that encrypts outgoing payloads using
+// AES-GCM and signs them using Ed25519. Incoming payloads are verified and
+// decrypted before they are passed on.
+//
+// The envelope binds the payload to the message topic. Encrypted payloads
+// authenticate the topic as additional data and signatures cover the topic.
+type Envelope struct {
+	// The Unsigned policy is applied to incoming messages that carry no
+	// signature on topics that do not require one.
+	//
+	// Default: Reject.
+	Unsigned Policy
+
+	// The Invalid policy is applied to incoming messages that are malformed,
+	// carry an invalid signature, reference unknown or unexpected keys, lack a
+	// protection required by their rule or cannot be decrypted.
+	//
+	// Default: Reject.
+	Invalid Policy
+
+	encryptionKeys   map[string]cipher.AEAD
+	signingKeys      map[string]ed25519.PrivateKey
+	verificationKeys map[string]ed25519.PublicKey
+	rules            *topic.Tree
+	mutex            sync.RWMutex
+}
+
+var _ client.Middleware = (*Envelope)(nil)
+
+// New returns a new Envelope.
+func New() *Envelope {
+	return &Envelope{
+		encryptionKeys:   make(map[string]cipher.AEAD),
+		signingKeys:      make(map[string]ed25519.PrivateKey),
+		verificationKeys: make(map[string]ed25519.PublicKey),
+		rules:            topic.NewStandardTree(),
+	}
+}
+
+// AddEncryptionKey will add the specified AES key (16, 24 or 32 bytes) using
+// the provided ID.
+func (e *Envelope) AddEncryptionKey(id string, key []byte) error {
+	// check id
+	if !validID(id) {
+		return ErrInvalidKeyID
+	}
+
+	// create cipher
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	// create aead
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// add key
+	e.encryptionKeys[id] = aead
+
+	return nil
+}
+
+// AddSigningKey will add the specified private key using the provided ID. The
+// related public key is added as a verification key.
+func (e *Envelope) AddSigningKey(id string, key ed25519.PrivateKey) error {
+	// check id
+	if !validID(id) {
+		return ErrInvalidKeyID
+	}
+
+	// check key
+	if len(key) != ed25519.PrivateKeySize {
+		return ErrInvalidKey
+	}
+
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// add keys
+	e.signingKeys[id] = key
+	e.verificationKeys[id] = key.Public().(ed25519.PublicKey)
+
+	return nil
+}
+
+// AddVerificationKey will add the specified public key using the provided ID.
+func (e *Envelope) AddVerificationKey(id string, key ed25519.PublicKey) error {
+	// check id
+	if !validID(id) {
+		return ErrInvalidKeyID
+	}
+
+	// check key
+	if len(key) != ed25519.PublicKeySize {
+		return ErrInvalidKey
+	}
+
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// add key
+	e.verificationKeys[id] = key
+
+	return nil
+}
+
+// Protect will set the rule for the specified topic filter. If multiple rules
+// match a topic, the rule with the most specific filter is used. Incoming
+// messages on topics that match a rule must use the keys of the rule.
+func (e *Envelope) Protect(filter string, rule Rule) {
+	e.rules.Set(filter, protection{filter: filter, rule: rule})
+}
+
+// returns the rule with the most specific filter that matches the topic
+func (e *Envelope) lookup(name string) (Rule, bool) {
+	// find most specific protection
+	var best *protection
+	for _, value := range e.rules.Match(name) {
+		p := value.(protection)
+		if best == nil || topic.MoreSpecific(p.filter, best.filter) {
+			best = &p
+		}
+	}
+
+	// check protection
+	if best == nil {
+		return Rule{}, false
+	}
+
+	return best.rule, true
+}
+
+// Outgoing implements the client.Middleware interface.
+func (e *Envelope) Outgoing(msg *packet.Message) (*packet.Message, error) {
+	// get rule
+	rule, ok := e.lookup(msg.Topic)
+	if !ok {
+		return msg, nil
+	}
+
+	// acquire mutex
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	// prepare flags
+	var flags byte
+	if rule.EncryptionKey != "" {
+		flags |= flagEncrypted
+	}
+	if rule.SigningKey != "" {
+		flags |= flagSigned
+	}
+
+	// return message if not protected
+	if flags == 0 {
+		return msg, nil
+	}
+
+	// prepare envelope
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(version)
+	buf.WriteByte(flags)
+
+	// prepare aead
+	var aead cipher.AEAD
+	var nonce []byte
+
+	// write encryption header
+	if rule.EncryptionKey != "" {
+		// get key
+		aead = e.encryptionKeys[rule.EncryptionKey]
+		if aead == nil {
+			return nil, &UnknownKeyError{ID: rule.EncryptionKey}
+		}
+
+		// generate nonce
+		nonce = make([]byte, aead.NonceSize())
+		_, err := rand.Read(nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		// write key and nonce
+		writeString(&buf, rule.EncryptionKey)
+		buf.Write(nonce)
+	}
+
+	// get signing key
+	var signingKey ed25519.PrivateKey
+	if rule.SigningKey != "" {
+		// get key
+		signingKey = e.signingKeys[rule.SigningKey]
+		if signingKey == nil {
+			return nil, &UnknownKeyError{ID: rule.SigningKey}
+		}
+
+		// write key
+		writeString(&buf, rule.SigningKey)
+	}
+
+	// write body
+	if aead != nil {
+		header := buf.Bytes()
+		buf.Write(aead.Seal(nil, nonce, msg.Payload, bind(msg.Topic, header)))
+	} else {
+		buf.Write(msg.Payload)
+	}
+
+	// write signature
+	if signingKey != nil {
+		buf.Write(ed25519.Sign(signingKey, bind(msg.Topic, buf.Bytes())))
+	}
+
+	// copy message
+	msg = msg.Copy()
+	msg.Payload = buf.Bytes()
+
+	return msg, nil
+}
+
+// Incoming implements the client.Middleware interface.
+func (e *Envelope) Incoming(msg *packet.Message) (*packet.Message, error) {
+	// handle messages that are not enveloped
+	if !bytes.HasPrefix(msg.Payload, magic) {
+		// check rule
+		rule, ok := e.lookup(msg.Topic)
+		if ok && (rule.EncryptionKey != "" || rule.SigningKey != "") {
+			return e.apply(e.Invalid, msg, ErrUnexpectedKey)
+		}
+
+		return e.apply(e.Unsigned, msg, ErrUnsignedMessage)
+	}
+
+	// acquire mutex
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	// open envelope
+	payload, signed, err := e.open(msg)
+	if err != nil {
+		return e.apply(e.Invalid, msg, err)
+	}
+
+	// copy message
+	opened := msg.Copy()
+	opened.Payload = payload
+
+	// handle unsigned messages
+	if !signed {
+		// pass on opened message if accepted
+		if e.Unsigned == Accept {
+			return opened, nil
+		}
+
+		return e.apply(e.Unsigned, msg, ErrUnsignedMessage)
+	}
+
+	return opened, nil
+}
+
+func (e *Envelope) open(msg *packet.Message) ([]byte, bool, error) {
+	// get buffer
+	buf := msg.Payload[len(magic):]
+
+	// check version and flags
+	if len(buf) < 2 || buf[0] != version {
+		return nil, false, ErrMalformedEnvelope
+	}
+	flags := buf[1]
+	pos := 2
+
+	// get rule
+	rule, ruled := e.lookup(msg.Topic)
+
+	// check required protections
+	if ruled && rule.EncryptionKey != "" && flags&flagEncrypted == 0 {
+		return nil, false, ErrUnexpectedKey
+	} else if ruled && rule.SigningKey != "" && flags&flagSigned == 0 {
+		return nil, false, ErrUnexpectedKey
+	}
+
+	// read encryption header
+	var aead cipher.AEAD
+	var nonce []byte
+	if flags&flagEncrypted != 0 {
+		// read key id
+		id, n, err := readString(buf[pos:])
+		if err != nil {
+			return nil, false, err
+		}
+		pos += n
+
+		// check key
+		if ruled && rule.EncryptionKey != "" && id != rule.EncryptionKey {
+			return nil, false, ErrUnexpectedKey
+		}
+
+		// get key
+		aead = e.encryptionKeys[id]
+		if aead == nil {
+			return nil, false, &UnknownKeyError{ID: id}
+		}
+
+		// read nonce
+		if len(buf) < pos+aead.NonceSize() {
+			return nil, false, ErrMalformedEnvelope
+		}
+		nonce = buf[pos : pos+aead.NonceSize()]
+		pos += aead.NonceSize()
+	}
+
+	// read signing header
+	var verificationKey ed25519.PublicKey
+	if flags&flagSigned != 0 {
+		// read key id
+		id, n, err := readString(buf[pos:])
+		if err != nil {
+			return nil, false, err
+		}
+		pos += n
+
+		// check key
+		if ruled && rule.SigningKey != "" && id != rule.SigningKey {
+			return nil, false, ErrUnexpectedKey
+		}
+
+		// get key
+		verificationKey = e.verificationKeys[id]
+		if verificationKey == nil {
+			return nil, false, &UnknownKeyError{ID: id}
+		}
+	}
+
+	// get header length and body
+	headerLen := len(magic) + pos
+	body := buf[pos:]
+
+	// verify signature
+	if verificationKey != nil {
+		// check length
+		if len(body) < ed25519.SignatureSize {
+			return nil, false, ErrMalformedEnvelope
+		}
+
+		// get signature
+		signature := body[len(body)-ed25519.SignatureSize:]
+		body = body[:len(body)-ed25519.SignatureSize]
+
+		// verify signature
+		data := bind(msg.Topic, msg.Payload[:len(msg.Payload)-ed25519.SignatureSize])
+		if !ed25519.Verify(verificationKey, data, signature) {
+			return nil, false, ErrInvalidSignature
+		}
+	}
+
+	// decrypt body
+	if aead != nil {
+		plain, err := aead.Open(nil, nonce, body, bind(msg.Topic, msg.Payload[:headerLen]))
+		if err != nil {
+			return nil, false, ErrDecryptionFailed
+		}
+
+		return plain, verificationKey != nil, nil
+	}
+
+	// copy body
+	plain := make([]byte, len(body))
+	copy(plain, body)
+
+	return plain, verificationKey != nil, nil
+}
+
+func (e *Envelope) apply(policy Policy, msg *packet.Message, err error) (*packet.Message, error) {
+	switch policy {
+	case Accept:
+		return msg, nil
+	case Drop:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func validID(id string) bool {
+	return len(id) > 0 && len(id) <= 255
+}
+
+func writeString(buf *bytes.Buffer, str string) {
+	buf.WriteByte(byte(len(str)))
+	buf.WriteString(str)
+}
+
+func readString(buf []byte) (string, int, error) {
+	// check length
+	if len(buf) < 1 || len(buf) < 1+int(buf[0]) {
+		return "", 0, ErrMalformedEnvelope
+	}
+
+	return string(buf[1 : 1+int(buf[0])]), 1 + int(buf[0]), nil
+}
+
+func bind(topic string, data []byte) []byte {
+	// prepare buffer
+	buf := make([]byte, 2+len(topic)+len(data))
+
+	// write topic and data
+	binary.BigEndian.PutUint16(buf, uint16(len(topic)))
+	copy(buf[2:], topic)
+	copy(buf[2+len(topic):], data)
+
+	return buf
+}
@@ -0,0 +1,288 @@
+package envelope
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testEnvelope(t *testing.T) *Envelope {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	e := New()
+	assert.NoError(t, e.AddEncryptionKey("enc", make([]byte, 32)))
+	assert.NoError(t, e.AddSigningKey("sig", priv))
+	assert.NoError(t, e.AddVerificationKey("pub", pub))
+
+	return e
+}
+
+func TestEnvelope(t *testing.T) {
+	table := []Rule{
+		{EncryptionKey: "enc", SigningKey: "sig"},
+		{EncryptionKey: "enc"},
+		{SigningKey: "sig"},
+	}
+
+	for _, rule := range table {
+		e := testEnvelope(t)
+		e.Unsigned = Accept
+		e.Protect("foo/#", rule)
+
+		in := &packet.Message{Topic: "foo/bar", Payload: []byte("hello"), QOS: 1}
+
+		out, err := e.Outgoing(in)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), in.Payload)
+		assert.NotEqual(t, in.Payload, out.Payload)
+		assert.Equal(t, in.Topic, out.Topic)
+		assert.Equal(t, in.QOS, out.QOS)
+
+		if rule.EncryptionKey != "" {
+			assert.NotContains(t, string(out.Payload), "hello")
+		}
+
+		msg, err := e.Incoming(out)
+		assert.NoError(t, err)
+		assert.Equal(t, in, msg)
+	}
+}
+
+func TestEnvelopeUnprotected(t *testing.T) {
+	e := testEnvelope(t)
+	e.Protect("foo", Rule{SigningKey: "sig"})
+
+	in := &packet.Message{Topic: "bar", Payload: []byte("hello")}
+
+	out, err := e.Outgoing(in)
+	assert.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	msg, err := e.Incoming(out)
+	assert.Equal(t, ErrUnsignedMessage, err)
+	assert.Nil(t, msg)
+
+	e.Unsigned = Drop
+
+	msg, err = e.Incoming(out)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	e.Unsigned = Accept
+
+	msg, err = e.Incoming(out)
+	assert.NoError(t, err)
+	assert.Equal(t, in, msg)
+}
+
+func TestEnvelopeEncryptedUnsigned(t *testing.T) {
+	e := testEnvelope(t)
+	e.Protect("foo", Rule{EncryptionKey: "enc"})
+
+	out, err := e.Outgoing(&packet.Message{Topic: "foo", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err := e.Incoming(out)
+	assert.Equal(t, ErrUnsignedMessage, err)
+	assert.Nil(t, msg)
+}
+
+func TestEnvelopeInvalid(t *testing.T) {
+	e := testEnvelope(t)
+	e.Protect("foo", Rule{EncryptionKey: "enc", SigningKey: "sig"})
+
+	out, err := e.Outgoing(&packet.Message{Topic: "foo", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	// different topic
+	msg, err := e.Incoming(&packet.Message{Topic: "bar", Payload: out.Payload})
+	assert.Equal(t, ErrInvalidSignature, err)
+	assert.Nil(t, msg)
+
+	// tampered payload
+	tampered := append([]byte(nil), out.Payload...)
+	tampered[len(tampered)-70] ^= 0xff
+	msg, err = e.Incoming(&packet.Message{Topic: "foo", Payload: tampered})
+	assert.Equal(t, ErrInvalidSignature, err)
+	assert.Nil(t, msg)
+
+	// truncated payload
+	msg, err = e.Incoming(&packet.Message{Topic: "foo", Payload: out.Payload[:8]})
+	assert.Equal(t, ErrMalformedEnvelope, err)
+	assert.Nil(t, msg)
+
+	// unknown key
+	other := New()
+	msg, err = other.Incoming(out)
+	assert.Equal(t, &UnknownKeyError{ID: "enc"}, err)
+	assert.Nil(t, msg)
+
+	other.Invalid = Drop
+	msg, err = other.Incoming(out)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	other.Invalid = Accept
+	msg, err = other.Incoming(out)
+	assert.NoError(t, err)
+	assert.Equal(t, out, msg)
+}
+
+func TestEnvelopeDecryptionFailed(t *testing.T) {
+	e1 := testEnvelope(t)
+	e1.Unsigned = Accept
+	e1.Protect("foo", Rule{EncryptionKey: "enc"})
+
+	out, err := e1.Outgoing(&packet.Message{Topic: "foo", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	e2 := New()
+	e2.Unsigned = Accept
+	assert.NoError(t, e2.AddEncryptionKey("enc", []byte("0123456789abcdef")))
+
+	msg, err := e2.Incoming(out)
+	assert.Equal(t, ErrDecryptionFailed, err)
+	assert.Nil(t, msg)
+}
+
+func TestEnvelopeErrors(t *testing.T) {
+	e := New()
+	assert.Error(t, e.AddEncryptionKey("enc", []byte("foo")))
+	assert.Equal(t, ErrInvalidKeyID, e.AddEncryptionKey("", make([]byte, 16)))
+
+	e.Protect("foo", Rule{SigningKey: "missing"})
+
+	msg, err := e.Outgoing(&packet.Message{Topic: "foo"})
+	assert.Equal(t, &UnknownKeyError{ID: "missing"}, err)
+	assert.Nil(t, msg)
+}
+
+func TestEnvelopeInvalidKeys(t *testing.T) {
+	e := New()
+	assert.Equal(t, ErrInvalidKey, e.AddSigningKey("sig", make([]byte, 5)))
+	assert.Equal(t, ErrInvalidKey, e.AddVerificationKey("pub", make([]byte, 5)))
+	assert.Empty(t, e.signingKeys)
+	assert.Empty(t, e.verificationKeys)
+}
+
+func TestEnvelopeRulePrecedence(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	e := testEnvelope(t)
+	assert.NoError(t, e.AddSigningKey("other", priv))
+	e.Protect("#", Rule{SigningKey: "other"})
+	e.Protect("foo/+/baz", Rule{SigningKey: "sig"})
+	e.Protect("foo/#", Rule{})
+
+	rule, ok := e.lookup("foo/bar/baz")
+	assert.True(t, ok)
+	assert.Equal(t, Rule{SigningKey: "sig"}, rule)
+
+	rule, ok = e.lookup("foo/bar")
+	assert.True(t, ok)
+	assert.Equal(t, Rule{}, rule)
+
+	rule, ok = e.lookup("bar")
+	assert.True(t, ok)
+	assert.Equal(t, Rule{SigningKey: "other"}, rule)
+}
+
+func TestEnvelopeUnexpectedKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	sender := testEnvelope(t)
+	assert.NoError(t, sender.AddSigningKey("other", priv))
+	sender.Protect("foo", Rule{SigningKey: "other"})
+	sender.Protect("bar", Rule{EncryptionKey: "enc", SigningKey: "sig"})
+
+	receiver := testEnvelope(t)
+	assert.NoError(t, receiver.AddVerificationKey("other", priv.Public().(ed25519.PublicKey)))
+	assert.NoError(t, receiver.AddEncryptionKey("enc2", make([]byte, 32)))
+	receiver.Protect("foo", Rule{SigningKey: "sig"})
+	receiver.Protect("bar", Rule{EncryptionKey: "enc2", SigningKey: "sig"})
+
+	// wrong signing key
+	out, err := sender.Outgoing(&packet.Message{Topic: "foo", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err := receiver.Incoming(out)
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// wrong encryption key
+	out, err = sender.Outgoing(&packet.Message{Topic: "bar", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err = receiver.Incoming(out)
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// topics without rules accept any known key
+	sender.Protect("baz", Rule{SigningKey: "other"})
+	out, err = sender.Outgoing(&packet.Message{Topic: "baz", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err = receiver.Incoming(out)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), msg.Payload)
+}
+
+func TestEnvelopeDowngrade(t *testing.T) {
+	sender := testEnvelope(t)
+	sender.Protect("foo/signed", Rule{SigningKey: "sig"})
+	sender.Protect("foo/encrypted", Rule{EncryptionKey: "enc"})
+
+	receiver := testEnvelope(t)
+	receiver.Unsigned = Accept
+	receiver.Protect("foo/#", Rule{EncryptionKey: "enc", SigningKey: "sig"})
+
+	// raw payload
+	msg, err := receiver.Incoming(&packet.Message{Topic: "foo/raw", Payload: []byte("hello")})
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// envelope without protections
+	payload := append(append([]byte{}, magic...), version, 0)
+	payload = append(payload, "hello"...)
+	msg, err = receiver.Incoming(&packet.Message{Topic: "foo/empty", Payload: payload})
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// missing encryption
+	out, err := sender.Outgoing(&packet.Message{Topic: "foo/signed", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err = receiver.Incoming(out)
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// missing signature
+	out, err = sender.Outgoing(&packet.Message{Topic: "foo/encrypted", Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	msg, err = receiver.Incoming(out)
+	assert.Equal(t, ErrUnexpectedKey, err)
+	assert.Nil(t, msg)
+
+	// invalid policy
+	receiver.Invalid = Drop
+
+	msg, err = receiver.Incoming(&packet.Message{Topic: "foo/raw", Payload: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	msg, err = receiver.Incoming(&packet.Message{Topic: "foo/empty", Payload: payload})
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	// unprotected topics
+	msg, err = receiver.Incoming(&packet.Message{Topic: "bar", Payload: []byte("hello")})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), msg.Payload)
+}
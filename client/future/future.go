@@ -141,3 +141,39 @@ func (f *Future) Attach(f2 *Future) {
 	// attach future
 	f.futures = append(f.futures, f2)
 }
+
+// Combine returns a future that is completed once all specified futures have
+// been completed. If one of the futures is cancelled, the returned future is
+// cancelled with that future's result. Completing the returned future will
+// provide a slice with the results of all futures.
+func Combine(futures ...*Future) *Future {
+	// create future
+	f := New()
+
+	// complete immediately if empty
+	if len(futures) == 0 {
+		f.Complete([]interface{}{})
+		return f
+	}
+
+	// await futures in background
+	go func() {
+		// prepare results
+		results := make([]interface{}, 0, len(futures))
+
+		for _, future := range futures {
+			select {
+			case <-future.completed:
+				results = append(results, future.Result())
+			case <-future.cancelled:
+				f.Cancel(future.Result())
+				return
+			}
+		}
+
+		// complete future
+		f.Complete(results)
+	}()
+
+	return f
+}
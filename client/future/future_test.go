@@ -74,3 +74,36 @@ func TestFutureBindAfter(t *testing.T) {
 	assert.Equal(t, ErrCanceled, err)
 	assert.Equal(t, 1, f2.Result())
 }
+
+func TestCombineComplete(t *testing.T) {
+	f1 := New()
+	f2 := New()
+
+	f := Combine(f1, f2)
+	assert.Equal(t, ErrTimeout, f.Wait(10*time.Millisecond))
+
+	f1.Complete(1)
+	assert.Equal(t, ErrTimeout, f.Wait(10*time.Millisecond))
+
+	f2.Complete(2)
+	assert.NoError(t, f.Wait(10*time.Millisecond))
+	assert.Equal(t, []interface{}{1, 2}, f.Result())
+}
+
+func TestCombineCancel(t *testing.T) {
+	f1 := New()
+	f2 := New()
+
+	f := Combine(f1, f2)
+
+	f1.Complete(1)
+	f2.Cancel(2)
+
+	assert.Equal(t, ErrCanceled, f.Wait(10*time.Millisecond))
+	assert.Equal(t, 2, f.Result())
+}
+
+func TestCombineEmpty(t *testing.T) {
+	f := Combine()
+	assert.NoError(t, f.Wait(10*time.Millisecond))
+}
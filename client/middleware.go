@@ -0,0 +1,50 @@
+package client
+
+import "github.com/256dpi/gomqtt/packet"
+
+// A Middleware transforms messages that are published and received by a
+// Service. Middleware is applied in order to outgoing messages and in reverse
+// order to incoming messages.
+type Middleware interface {
+	// Outgoing is called with every message before it is published. The
+	// middleware must not modify the passed message but return a modified
+	// copy instead. If an error is returned, the message is not published.
+	Outgoing(msg *packet.Message) (*packet.Message, error)
+
+	// Incoming is called with every received message before it is passed to
+	// the next middleware or the MessageCallback. The middleware must not
+	// modify the passed message but return a modified copy instead. If no
+	// message is returned, the message is dropped silently. If an error is
+	// returned, the message is dropped and the error is reported.
+	Incoming(msg *packet.Message) (*packet.Message, error)
+}
+
+// applies all middleware to an outgoing message
+func applyOutgoing(middleware []Middleware, msg *packet.Message) (*packet.Message, error) {
+	for _, m := range middleware {
+		// transform message
+		var err error
+		msg, err = m.Outgoing(msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// applies all middleware in reverse order to an incoming message
+func applyIncoming(middleware []Middleware, msg *packet.Message) (*packet.Message, error) {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		// transform message
+		var err error
+		msg, err = middleware[i].Incoming(msg)
+		if err != nil {
+			return nil, err
+		} else if msg == nil {
+			return nil, nil
+		}
+	}
+
+	return msg, nil
+}
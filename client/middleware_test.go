@@ -0,0 +1,118 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport/flow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type prefixMiddleware struct {
+	prefix []byte
+}
+
+func (m *prefixMiddleware) Outgoing(msg *packet.Message) (*packet.Message, error) {
+	msg = msg.Copy()
+	msg.Payload = append(append([]byte(nil), m.prefix...), msg.Payload...)
+	return msg, nil
+}
+
+func (m *prefixMiddleware) Incoming(msg *packet.Message) (*packet.Message, error) {
+	if !bytes.HasPrefix(msg.Payload, m.prefix) {
+		return nil, errors.New("missing prefix")
+	}
+
+	msg = msg.Copy()
+	msg.Payload = msg.Payload[len(m.prefix):]
+	return msg, nil
+}
+
+func TestApplyMiddleware(t *testing.T) {
+	middleware := []Middleware{
+		&prefixMiddleware{prefix: []byte("a")},
+		&prefixMiddleware{prefix: []byte("b")},
+	}
+
+	in := &packet.Message{Topic: "test", Payload: []byte("test")}
+
+	out, err := applyOutgoing(middleware, in)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("batest"), out.Payload)
+	assert.Equal(t, []byte("test"), in.Payload)
+
+	msg, err := applyIncoming(middleware, out)
+	assert.NoError(t, err)
+	assert.Equal(t, in, msg)
+
+	msg, err = applyIncoming(middleware, in)
+	assert.Error(t, err)
+	assert.Nil(t, msg)
+}
+
+func TestServiceMiddleware(t *testing.T) {
+	publish := packet.NewPublish()
+	publish.Message.Topic = "test"
+	publish.Message.Payload = []byte("xtest")
+
+	invalid := packet.NewPublish()
+	invalid.Message.Topic = "test"
+	invalid.Message.Payload = []byte("test")
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish).
+		Send(invalid).
+		Send(publish).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	online := make(chan struct{})
+	message := make(chan struct{})
+	errs := make(chan error, 1)
+	offline := make(chan struct{})
+
+	s := NewService()
+	s.Middleware = []Middleware{&prefixMiddleware{prefix: []byte("x")}}
+
+	s.OnlineCallback = func(resumed bool) {
+		close(online)
+	}
+
+	s.OfflineCallback = func() {
+		close(offline)
+	}
+
+	s.MessageCallback = func(msg *packet.Message) error {
+		assert.Equal(t, "test", msg.Topic)
+		assert.Equal(t, []byte("test"), msg.Payload)
+		close(message)
+		return nil
+	}
+
+	s.ErrorCallback = func(err error) {
+		errs <- err
+	}
+
+	s.Start(NewConfig("tcp://localhost:" + port))
+
+	safeReceive(online)
+
+	assert.NoError(t, s.Publish("test", []byte("test"), 0, false).Wait(1*time.Second))
+
+	assert.EqualError(t, <-errs, "missing prefix")
+
+	safeReceive(message)
+
+	s.Stop(true)
+
+	safeReceive(offline)
+	safeReceive(done)
+}
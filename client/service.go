@@ -17,10 +17,12 @@ import (
 
 type command struct {
 	publish       bool
+	batch         bool
 	subscribe     bool
 	unsubscribe   bool
 	future        *future.Future
 	message       *packet.Message
+	messages      []*packet.Message
 	subscriptions []packet.Subscription
 	topics        []string
 }
@@ -73,6 +75,18 @@ type Service struct {
 	// keep alive handler, reconnection and occurring errors.
 	Logger func(msg string)
 
+	// The Middleware is applied to all published messages before they are
+	// sent and to all received messages before they are passed to the
+	// MessageCallback. Errors returned by the middleware are reported using
+	// the ErrorCallback and cause the message to be dropped.
+	//
+	// Note: The value must be changed before calling Start.
+	Middleware []Middleware
+
+	// The Codecs are used to encode values published with PublishValue and to
+	// decode messages for handlers registered with HandleValue.
+	Codecs *CodecRegistry
+
 	// The minimum delay between reconnects.
 	//
 	// Note: The value must be changed before calling Start.
@@ -102,9 +116,12 @@ type Service struct {
 	QueueTimeout time.Duration
 
 	config        *Config
+	configMutex   sync.Mutex
+	reconnect     chan struct{}
 	started       bool
 	backoff       *backoff.Backoff
 	subscriptions *topic.Tree
+	handlers      *topic.Tree
 	commandQueue  chan *command
 	futureStore   *future.Store
 	mutex         sync.Mutex
@@ -129,8 +146,11 @@ func NewService(queueSize ...int) *Service {
 		ResubscribeTimeout:          5 * time.Second,
 		QueueTimeout:                10 * time.Second,
 		ResubscribeAllSubscriptions: true,
+		Codecs:                      NewCodecRegistry(),
 		subscriptions:               topic.NewStandardTree(),
+		handlers:                    topic.NewStandardTree(),
 		commandQueue:                make(chan *command, qs),
+		reconnect:                   make(chan struct{}, 1),
 		futureStore:                 future.NewStore(),
 	}
 }
@@ -157,7 +177,9 @@ func (s *Service) Start(config *Config) bool {
 	s.started = true
 
 	// save config
+	s.configMutex.Lock()
 	s.config = config
+	s.configMutex.Unlock()
 
 	// initialize backoff
 	s.backoff = &backoff.Backoff{
@@ -178,6 +200,42 @@ func (s *Service) Start(config *Config) bool {
 	return true
 }
 
+// UpdateConfig will replace the configuration used by the service. The new
+// configuration is used for the next connection attempt. If reconnect is true,
+// the current client is gracefully disconnected and a new connection is
+// established immediately. Queued commands and stored subscriptions are kept
+// across the switch. It returns false if the service is not started.
+func (s *Service) UpdateConfig(config *Config, reconnect bool) bool {
+	// check config
+	if config == nil {
+		panic("missing config")
+	}
+
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// return if service not started
+	if !s.started {
+		return false
+	}
+
+	// save config
+	s.configMutex.Lock()
+	s.config = config
+	s.configMutex.Unlock()
+
+	// request reconnect if requested
+	if reconnect {
+		select {
+		case s.reconnect <- struct{}{}:
+		default:
+		}
+	}
+
+	return true
+}
+
 // Publish will send a Publish packet containing the passed parameters. It will
 // return a PublishFuture that gets completed once the quality of service flow
 // has been completed.
@@ -218,6 +276,75 @@ func (s *Service) PublishMessage(msg *packet.Message) GenericFuture {
 	return f
 }
 
+// PublishValue will encode the value using the codec selected for the topic
+// and send a Publish packet containing the encoded value. It will return a
+// PublishFuture that gets completed once the quality of service flow has been
+// completed. If the value cannot be encoded, the error is emitted using the
+// ErrorCallback and the returned future is canceled.
+func (s *Service) PublishValue(topic string, value interface{}, qos packet.QOS, retain bool) GenericFuture {
+	// encode value
+	payload, err := s.Codecs.Encode(topic, value)
+	if err != nil {
+		s.err("Codec", err)
+
+		// return canceled future
+		f := future.New()
+		f.Cancel(err)
+
+		return f
+	}
+
+	return s.PublishMessage(&packet.Message{
+		Topic:   topic,
+		Payload: payload,
+		QOS:     qos,
+		Retain:  retain,
+	})
+}
+
+// HandleValue will register a handler for messages received on topics that
+// match the filter. The handler must be a function with the signature
+// func(*packet.Message, T) error where T is the type the payload is decoded
+// into using the codec selected for the topic. Messages that are passed to a
+// handler are not passed to the MessageCallback. If the payload cannot be
+// decoded, the error is emitted using the ErrorCallback and the message is
+// dropped. An error returned by the handler has the same effect as an error
+// returned by the MessageCallback.
+//
+// Note: The function will panic if the handler has an invalid signature. The
+// handler does not subscribe to the filter.
+func (s *Service) HandleValue(filter string, handler interface{}) {
+	s.handlers.Add(filter, newValueHandler(handler))
+}
+
+// PublishBatch will send a Publish packet for each of the passed messages
+// using a single flush. It will return a GenericFuture that gets completed once
+// the quality of service flows of all messages have been completed.
+func (s *Service) PublishBatch(msgs []*packet.Message) GenericFuture {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// allocate future
+	f := future.New()
+
+	// prepare command
+	cmd := &command{
+		batch:    true,
+		future:   f,
+		messages: msgs,
+	}
+
+	// queue batch
+	select {
+	case s.commandQueue <- cmd:
+	case <-time.After(s.QueueTimeout):
+		f.Cancel(nil)
+	}
+
+	return f
+}
+
 // Subscribe will send a Subscribe packet containing one topic to subscribe. It
 // will return a SubscribeFuture that gets completed once the acknowledgements
 // have been received.
@@ -334,9 +461,10 @@ func (s *Service) supervisor() error {
 			d := s.backoff.Duration()
 			s.log(fmt.Sprintf("Delay Reconnect: %v", d))
 
-			// sleep but return on Stop
+			// sleep but return on Stop and skip on reconnect
 			select {
 			case <-time.After(d):
+			case <-s.reconnect:
 			case <-s.tomb.Dying():
 				return tomb.ErrDying
 			}
@@ -370,7 +498,7 @@ func (s *Service) supervisor() error {
 		}
 
 		// run dispatcher on client
-		dying := s.dispatcher(client, kill)
+		dying, reconnect := s.dispatcher(client, kill)
 
 		// ensure client is closed
 		_ = client.Close()
@@ -384,11 +512,27 @@ func (s *Service) supervisor() error {
 		if dying {
 			return tomb.ErrDying
 		}
+
+		// reconnect immediately if requested
+		if reconnect {
+			first = true
+		}
 	}
 }
 
 // will try to connect one client to the broker
 func (s *Service) connect(kill chan struct{}) (*Client, bool) {
+	// clear pending reconnect request
+	select {
+	case <-s.reconnect:
+	default:
+	}
+
+	// get config
+	s.configMutex.Lock()
+	config := s.config
+	s.configMutex.Unlock()
+
 	// prepare new client
 	client := New()
 	client.Session = s.Session
@@ -403,6 +547,20 @@ func (s *Service) connect(kill chan struct{}) (*Client, bool) {
 			return nil
 		}
 
+		// apply middleware
+		msg, err = applyIncoming(s.Middleware, msg)
+		if err != nil {
+			s.err("Middleware", err)
+			return nil
+		} else if msg == nil {
+			return nil
+		}
+
+		// call value handlers if available
+		if handlers := s.handlers.Match(msg.Topic); len(handlers) > 0 {
+			return s.handle(handlers, msg)
+		}
+
 		// call the handler
 		if s.MessageCallback != nil {
 			return s.MessageCallback(msg)
@@ -412,7 +570,7 @@ func (s *Service) connect(kill chan struct{}) (*Client, bool) {
 	}
 
 	// attempt to connect
-	connectFuture, err := client.Connect(s.config)
+	connectFuture, err := client.Connect(config)
 	if err != nil {
 		_ = client.Close()
 		s.err("Connect", err)
@@ -466,7 +624,7 @@ func (s *Service) resubscribe(client *Client) bool {
 }
 
 // reads from the queues and calls the current client
-func (s *Service) dispatcher(client *Client, kill chan struct{}) bool {
+func (s *Service) dispatcher(client *Client, kill chan struct{}) (bool, bool) {
 	for {
 		select {
 		case cmd := <-s.commandQueue:
@@ -482,7 +640,7 @@ func (s *Service) dispatcher(client *Client, kill chan struct{}) bool {
 				if err != nil {
 					s.err("Subscribe", err)
 					cmd.future.Cancel(nil)
-					return false
+					return false, false
 				}
 
 				// attach future
@@ -501,7 +659,7 @@ func (s *Service) dispatcher(client *Client, kill chan struct{}) bool {
 				if err != nil {
 					s.err("Unsubscribe", err)
 					cmd.future.Cancel(nil)
-					return false
+					return false, false
 				}
 
 				// attach future
@@ -510,12 +668,51 @@ func (s *Service) dispatcher(client *Client, kill chan struct{}) bool {
 
 			// handle publish command
 			if cmd.publish {
+				// apply middleware
+				msg, err := applyOutgoing(s.Middleware, cmd.message)
+				if err != nil {
+					s.err("Middleware", err)
+					cmd.future.Cancel(nil)
+					continue
+				}
+
 				// perform publish
-				f2, err := client.PublishMessage(cmd.message)
+				f2, err := client.PublishMessage(msg)
 				if err != nil {
 					s.err("Publish", err)
 					cmd.future.Cancel(nil)
-					return false
+					return false, false
+				}
+
+				// attach future
+				f2.(*future.Future).Attach(cmd.future)
+			}
+
+			// handle batch command
+			if cmd.batch {
+				// apply middleware
+				msgs := make([]*packet.Message, 0, len(cmd.messages))
+				var err error
+				for _, msg := range cmd.messages {
+					msg, err = applyOutgoing(s.Middleware, msg)
+					if err != nil {
+						break
+					}
+
+					msgs = append(msgs, msg)
+				}
+				if err != nil {
+					s.err("Middleware", err)
+					cmd.future.Cancel(nil)
+					continue
+				}
+
+				// perform batch
+				f2, err := client.PublishBatch(msgs)
+				if err != nil {
+					s.err("PublishBatch", err)
+					cmd.future.Cancel(nil)
+					return false, false
 				}
 
 				// attach future
@@ -528,13 +725,45 @@ func (s *Service) dispatcher(client *Client, kill chan struct{}) bool {
 				s.err("Disconnect", err)
 			}
 
-			return true
+			return true, false
+		case <-s.reconnect:
+			s.log("Reconnect Requested")
+
+			// disconnect client on UpdateConfig
+			err := client.Disconnect(s.DisconnectTimeout)
+			if err != nil {
+				s.err("Disconnect", err)
+			}
+
+			return false, true
 		case <-kill:
-			return false
+			return false, false
 		}
 	}
 }
 
+// calls the value handlers with the decoded message
+func (s *Service) handle(handlers []interface{}, msg *packet.Message) error {
+	for _, value := range handlers {
+		handler := value.(*valueHandler)
+
+		// decode message
+		v, err := handler.decode(s.Codecs, msg)
+		if err != nil {
+			s.err("Codec", err)
+			continue
+		}
+
+		// call handler
+		err = handler.call(msg, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *Service) err(sys string, err error) {
 	s.log(fmt.Sprintf("%s Error: %s", sys, err.Error()))
 
@@ -218,6 +218,65 @@ func TestServiceUnsubscribe(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestServicePublishBatch(t *testing.T) {
+	publish1 := packet.NewPublish()
+	publish1.Message.Topic = "test1"
+	publish1.Message.Payload = []byte("test1")
+	publish1.Message.QOS = 1
+	publish1.ID = 1
+
+	publish2 := packet.NewPublish()
+	publish2.Message.Topic = "test2"
+	publish2.Message.Payload = []byte("test2")
+	publish2.Message.QOS = 1
+	publish2.ID = 2
+
+	puback1 := packet.NewPuback()
+	puback1.ID = 1
+
+	puback2 := packet.NewPuback()
+	puback2.ID = 2
+
+	broker := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(publish1, publish2).
+		Send(puback1, puback2).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, broker)
+
+	online := make(chan struct{})
+	offline := make(chan struct{})
+
+	s := NewService()
+
+	s.OnlineCallback = func(resumed bool) {
+		assert.False(t, resumed)
+
+		close(online)
+	}
+
+	s.OfflineCallback = func() {
+		close(offline)
+	}
+
+	s.Start(NewConfig("tcp://localhost:" + port))
+
+	safeReceive(online)
+
+	assert.NoError(t, s.PublishBatch([]*packet.Message{
+		&publish1.Message,
+		&publish2.Message,
+	}).Wait(1*time.Second))
+
+	s.Stop(true)
+
+	safeReceive(offline)
+	safeReceive(done)
+}
+
 func TestServiceReconnect(t *testing.T) {
 	delay := flow.New().
 		Receive(connectPacket()).
@@ -606,3 +665,75 @@ func BenchmarkServicePublish(b *testing.B) {
 
 	safeReceive(done)
 }
+
+func TestServiceUpdateConfig(t *testing.T) {
+	subscribe := packet.NewSubscribe()
+	subscribe.Subscriptions = []packet.Subscription{{Topic: "test", QOS: 0}}
+	subscribe.ID = 1
+
+	suback := packet.NewSuback()
+	suback.ReturnCodes = []packet.QOS{0}
+	suback.ID = 1
+
+	publish := packet.NewPublish()
+	publish.Message.Topic = "test"
+	publish.Message.Payload = []byte("test")
+
+	connect := connectPacket()
+	connect.ClientID = "updated"
+
+	first := flow.New().
+		Receive(connectPacket()).
+		Send(connackPacket()).
+		Receive(subscribe).
+		Send(suback).
+		Receive(disconnectPacket()).
+		End()
+
+	second := flow.New().
+		Receive(connect).
+		Send(connackPacket()).
+		Receive(subscribe).
+		Send(suback).
+		Receive(publish).
+		Receive(disconnectPacket()).
+		End()
+
+	done, port := fakeBroker(t, first, second)
+
+	online := make(chan struct{}, 2)
+	offline := make(chan struct{}, 2)
+
+	s := NewService()
+
+	assert.False(t, s.UpdateConfig(NewConfig("tcp://localhost:"+port), false))
+
+	s.OnlineCallback = func(resumed bool) {
+		online <- struct{}{}
+	}
+
+	s.OfflineCallback = func() {
+		offline <- struct{}{}
+	}
+
+	s.Start(NewConfig("tcp://localhost:" + port))
+
+	safeReceive(online)
+
+	err := s.Subscribe("test", 0).Wait(1 * time.Second)
+	assert.NoError(t, err)
+
+	config := NewConfigWithClientID("tcp://localhost:"+port, "updated")
+	assert.True(t, s.UpdateConfig(config, true))
+
+	safeReceive(offline)
+	safeReceive(online)
+
+	err = s.Publish("test", []byte("test"), 0, false).Wait(1 * time.Second)
+	assert.NoError(t, err)
+
+	s.Stop(true)
+
+	safeReceive(offline)
+	safeReceive(done)
+}
@@ -24,6 +24,8 @@ var inflight = flag.Int("inflight", 0, "available tokens")
 var duration = flag.Int("duration", 0, "duration in seconds")
 var length = flag.Int("length", 1, "payload length")
 var retained = flag.Bool("retained", false, "retain flag")
+var delay = flag.Duration("delay", 10*time.Millisecond, "max write delay")
+var vectored = flag.Int64("vectored", 4096, "vectored threshold (0 copies all payloads)")
 
 var sent int32
 var received int32
@@ -33,12 +35,15 @@ var total int32
 var done = make(chan struct{})
 var wg sync.WaitGroup
 
-var payload = make([]byte, *length)
+var payload []byte
 
 func main() {
 	// parse flags
 	flag.Parse()
 
+	// prepare payload
+	payload = make([]byte, *length)
+
 	// run pprof interface
 	go func() {
 		panic(http.ListenAndServe("localhost:6061", nil))
@@ -46,6 +51,7 @@ func main() {
 
 	// print info
 	fmt.Printf("Start benchmark of %s using %d pairs for %d seconds...\n", *broker, *pairs, *duration)
+	fmt.Printf("Sending %d byte payloads with a vectored threshold of %d...\n", *length, *vectored)
 
 	// prepare finish signal
 	finish := make(chan os.Signal, 1)
@@ -99,8 +105,11 @@ func connect(id string) transport.Conn {
 		panic(err)
 	}
 
-	// set may delay
-	conn.SetMaxWriteDelay(10 * time.Millisecond)
+	// set max write delay
+	conn.SetMaxWriteDelay(*delay)
+
+	// set vectored threshold
+	conn.SetVectoredThreshold(*vectored)
 
 	// parse url
 	mqttURL, err := url.Parse(*broker)
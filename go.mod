@@ -1,7 +1,6 @@
 module github.com/256dpi/gomqtt
 
 require (
-	github.com/256dpi/mercury v0.2.0
 	github.com/Pallinder/go-randomdata v1.2.0
 	github.com/abiosoft/ishell v2.0.0+incompatible
 	github.com/abiosoft/readline v0.0.0-20180607040430-155bce2042db // indirect
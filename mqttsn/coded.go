@@ -0,0 +1,193 @@
+package mqttsn
+
+import "fmt"
+
+// decodes the body of a coded packet
+func codedDecode(src []byte, t Type) (ReturnCode, error) {
+	// check length
+	err := checkLen(t, src, 1, true)
+	if err != nil {
+		return 0, err
+	}
+
+	// read return code
+	rc := ReturnCode(src[0])
+
+	// check return code
+	if !rc.Valid() {
+		return 0, makeError(t, "invalid return code (%d)", rc)
+	}
+
+	return rc, nil
+}
+
+// encodes the body of a coded packet
+func codedEncode(dst []byte, rc ReturnCode, t Type) error {
+	// check return code
+	if !rc.Valid() {
+		return makeError(t, "invalid return code (%d)", rc)
+	}
+
+	// write return code
+	dst[0] = byte(rc)
+
+	return nil
+}
+
+// A Connack packet is sent by the gateway in response to a Connect packet.
+type Connack struct {
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewConnack creates a new Connack packet.
+func NewConnack() *Connack {
+	return &Connack{}
+}
+
+// Type returns the packets type.
+func (cp *Connack) Type() Type {
+	return CONNACK
+}
+
+// String returns a string representation of the packet.
+func (cp *Connack) String() string {
+	return fmt.Sprintf("<Connack ReturnCode=%d>", cp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (cp *Connack) Len() int {
+	return packetLen(cp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (cp *Connack) Decode(src []byte) (int, error) {
+	return decode(src, cp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (cp *Connack) Encode(dst []byte) (int, error) {
+	return encode(dst, cp)
+}
+
+func (cp *Connack) bodyLen() int {
+	return 1
+}
+
+func (cp *Connack) encodeBody(dst []byte) error {
+	return codedEncode(dst, cp.ReturnCode, CONNACK)
+}
+
+func (cp *Connack) decodeBody(src []byte) (err error) {
+	cp.ReturnCode, err = codedDecode(src, CONNACK)
+	return err
+}
+
+// A Willtopicresp packet is sent by the gateway in response to a Willtopicupd
+// packet.
+type Willtopicresp struct {
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewWilltopicresp creates a new Willtopicresp packet.
+func NewWilltopicresp() *Willtopicresp {
+	return &Willtopicresp{}
+}
+
+// Type returns the packets type.
+func (wp *Willtopicresp) Type() Type {
+	return WILLTOPICRESP
+}
+
+// String returns a string representation of the packet.
+func (wp *Willtopicresp) String() string {
+	return fmt.Sprintf("<Willtopicresp ReturnCode=%d>", wp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willtopicresp) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willtopicresp) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willtopicresp) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willtopicresp) bodyLen() int {
+	return 1
+}
+
+func (wp *Willtopicresp) encodeBody(dst []byte) error {
+	return codedEncode(dst, wp.ReturnCode, WILLTOPICRESP)
+}
+
+func (wp *Willtopicresp) decodeBody(src []byte) (err error) {
+	wp.ReturnCode, err = codedDecode(src, WILLTOPICRESP)
+	return err
+}
+
+// A Willmsgresp packet is sent by the gateway in response to a Willmsgupd
+// packet.
+type Willmsgresp struct {
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewWillmsgresp creates a new Willmsgresp packet.
+func NewWillmsgresp() *Willmsgresp {
+	return &Willmsgresp{}
+}
+
+// Type returns the packets type.
+func (wp *Willmsgresp) Type() Type {
+	return WILLMSGRESP
+}
+
+// String returns a string representation of the packet.
+func (wp *Willmsgresp) String() string {
+	return fmt.Sprintf("<Willmsgresp ReturnCode=%d>", wp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willmsgresp) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willmsgresp) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willmsgresp) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willmsgresp) bodyLen() int {
+	return 1
+}
+
+func (wp *Willmsgresp) encodeBody(dst []byte) error {
+	return codedEncode(dst, wp.ReturnCode, WILLMSGRESP)
+}
+
+func (wp *Willmsgresp) decodeBody(src []byte) (err error) {
+	wp.ReturnCode, err = codedDecode(src, WILLMSGRESP)
+	return err
+}
@@ -0,0 +1,28 @@
+package mqttsn
+
+import "testing"
+
+func TestCodedPackets(t *testing.T) {
+	for _, pkt := range []Generic{
+		&Connack{ReturnCode: RejectedCongestion},
+		&Willtopicresp{ReturnCode: RejectedNotSupported},
+		&Willmsgresp{ReturnCode: Accepted},
+	} {
+		var rc ReturnCode
+		switch p := pkt.(type) {
+		case *Connack:
+			rc = p.ReturnCode
+		case *Willtopicresp:
+			rc = p.ReturnCode
+		case *Willmsgresp:
+			rc = p.ReturnCode
+		}
+
+		assertCodec(t, pkt, []byte{0x03, byte(pkt.Type()), byte(rc)})
+
+		assertDecodeError(t, pkt, []byte{0x03, byte(pkt.Type()), 0x04})
+		assertDecodeError(t, pkt, []byte{0x04, byte(pkt.Type()), 0x00, 0x00})
+	}
+
+	assertEncodeError(t, &Connack{ReturnCode: 9})
+}
@@ -0,0 +1,99 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// the protocol id used by MQTT-SN 1.2
+const protocolID = 0x01
+
+// A Connect packet is sent by a client to setup a connection.
+type Connect struct {
+	// Whether the gateway should request the will topic and message.
+	Will bool
+
+	// Whether the session should be cleaned.
+	CleanSession bool
+
+	// The keep alive duration in seconds.
+	Duration uint16
+
+	// The client id.
+	ClientID string
+}
+
+// NewConnect creates a new Connect packet.
+func NewConnect() *Connect {
+	return &Connect{CleanSession: true}
+}
+
+// Type returns the packets type.
+func (cp *Connect) Type() Type {
+	return CONNECT
+}
+
+// String returns a string representation of the packet.
+func (cp *Connect) String() string {
+	return fmt.Sprintf("<Connect ClientID=%q Duration=%d Will=%t CleanSession=%t>",
+		cp.ClientID, cp.Duration, cp.Will, cp.CleanSession)
+}
+
+// Len returns the byte length of the encoded packet.
+func (cp *Connect) Len() int {
+	return packetLen(cp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (cp *Connect) Decode(src []byte) (int, error) {
+	return decode(src, cp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (cp *Connect) Encode(dst []byte) (int, error) {
+	return encode(dst, cp)
+}
+
+func (cp *Connect) bodyLen() int {
+	return 4 + len(cp.ClientID)
+}
+
+func (cp *Connect) encodeBody(dst []byte) error {
+	// check client id
+	if len(cp.ClientID) == 0 {
+		return makeError(cp.Type(), "missing client id")
+	}
+
+	// write fields
+	dst[0] = encodeFlags(false, 0, false, cp.Will, cp.CleanSession, 0)
+	dst[1] = protocolID
+	binary.BigEndian.PutUint16(dst[2:], cp.Duration)
+	copy(dst[4:], cp.ClientID)
+
+	return nil
+}
+
+func (cp *Connect) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(cp.Type(), src, 5, false)
+	if err != nil {
+		return err
+	}
+
+	// read flags
+	_, _, _, cp.Will, cp.CleanSession, _ = decodeFlags(src[0])
+
+	// check protocol id
+	if src[1] != protocolID {
+		return makeError(cp.Type(), "invalid protocol id (%d)", src[1])
+	}
+
+	// read fields
+	cp.Duration = binary.BigEndian.Uint16(src[2:])
+	cp.ClientID = string(src[4:])
+
+	return nil
+}
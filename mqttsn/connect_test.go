@@ -0,0 +1,19 @@
+package mqttsn
+
+import "testing"
+
+func TestConnect(t *testing.T) {
+	pkt := NewConnect()
+	pkt.Will = true
+	pkt.Duration = 30
+	pkt.ClientID = "c1"
+
+	assertCodec(t, pkt, []byte{0x08, byte(CONNECT), 0x0C, 0x01, 0x00, 0x1E, 'c', '1'})
+}
+
+func TestConnectErrors(t *testing.T) {
+	assertEncodeError(t, NewConnect())
+
+	assertDecodeError(t, NewConnect(), []byte{0x06, byte(CONNECT), 0x04, 0x01, 0x00, 0x1E})
+	assertDecodeError(t, NewConnect(), []byte{0x07, byte(CONNECT), 0x04, 0x02, 0x00, 0x1E, 'c'})
+}
@@ -0,0 +1,207 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// An Advertise packet is broadcast periodically by a gateway to advertise its
+// presence.
+type Advertise struct {
+	// The id of the gateway.
+	GatewayID byte
+
+	// The duration in seconds until the next advertisement is sent.
+	Duration uint16
+}
+
+// NewAdvertise creates a new Advertise packet.
+func NewAdvertise() *Advertise {
+	return &Advertise{}
+}
+
+// Type returns the packets type.
+func (ap *Advertise) Type() Type {
+	return ADVERTISE
+}
+
+// String returns a string representation of the packet.
+func (ap *Advertise) String() string {
+	return fmt.Sprintf("<Advertise GatewayID=%d Duration=%d>", ap.GatewayID, ap.Duration)
+}
+
+// Len returns the byte length of the encoded packet.
+func (ap *Advertise) Len() int {
+	return packetLen(ap.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (ap *Advertise) Decode(src []byte) (int, error) {
+	return decode(src, ap)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (ap *Advertise) Encode(dst []byte) (int, error) {
+	return encode(dst, ap)
+}
+
+func (ap *Advertise) bodyLen() int {
+	return 3
+}
+
+func (ap *Advertise) encodeBody(dst []byte) error {
+	dst[0] = ap.GatewayID
+	binary.BigEndian.PutUint16(dst[1:], ap.Duration)
+	return nil
+}
+
+func (ap *Advertise) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(ap.Type(), src, 3, true)
+	if err != nil {
+		return err
+	}
+
+	// read fields
+	ap.GatewayID = src[0]
+	ap.Duration = binary.BigEndian.Uint16(src[1:])
+
+	return nil
+}
+
+// A Searchgw packet is broadcast by a client to search for a gateway.
+type Searchgw struct {
+	// The broadcast radius of the packet.
+	Radius byte
+}
+
+// NewSearchgw creates a new Searchgw packet.
+func NewSearchgw() *Searchgw {
+	return &Searchgw{}
+}
+
+// Type returns the packets type.
+func (sp *Searchgw) Type() Type {
+	return SEARCHGW
+}
+
+// String returns a string representation of the packet.
+func (sp *Searchgw) String() string {
+	return fmt.Sprintf("<Searchgw Radius=%d>", sp.Radius)
+}
+
+// Len returns the byte length of the encoded packet.
+func (sp *Searchgw) Len() int {
+	return packetLen(sp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (sp *Searchgw) Decode(src []byte) (int, error) {
+	return decode(src, sp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (sp *Searchgw) Encode(dst []byte) (int, error) {
+	return encode(dst, sp)
+}
+
+func (sp *Searchgw) bodyLen() int {
+	return 1
+}
+
+func (sp *Searchgw) encodeBody(dst []byte) error {
+	dst[0] = sp.Radius
+	return nil
+}
+
+func (sp *Searchgw) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(sp.Type(), src, 1, true)
+	if err != nil {
+		return err
+	}
+
+	// read radius
+	sp.Radius = src[0]
+
+	return nil
+}
+
+// A Gwinfo packet is sent by a gateway or a client in response to a Searchgw
+// packet.
+type Gwinfo struct {
+	// The id of the gateway.
+	GatewayID byte
+
+	// The address of the gateway. It is only present if the packet is sent by
+	// a client.
+	GatewayAddress []byte
+}
+
+// NewGwinfo creates a new Gwinfo packet.
+func NewGwinfo() *Gwinfo {
+	return &Gwinfo{}
+}
+
+// Type returns the packets type.
+func (gp *Gwinfo) Type() Type {
+	return GWINFO
+}
+
+// String returns a string representation of the packet.
+func (gp *Gwinfo) String() string {
+	return fmt.Sprintf("<Gwinfo GatewayID=%d GatewayAddress=%x>", gp.GatewayID, gp.GatewayAddress)
+}
+
+// Len returns the byte length of the encoded packet.
+func (gp *Gwinfo) Len() int {
+	return packetLen(gp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (gp *Gwinfo) Decode(src []byte) (int, error) {
+	return decode(src, gp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (gp *Gwinfo) Encode(dst []byte) (int, error) {
+	return encode(dst, gp)
+}
+
+func (gp *Gwinfo) bodyLen() int {
+	return 1 + len(gp.GatewayAddress)
+}
+
+func (gp *Gwinfo) encodeBody(dst []byte) error {
+	dst[0] = gp.GatewayID
+	copy(dst[1:], gp.GatewayAddress)
+	return nil
+}
+
+func (gp *Gwinfo) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(gp.Type(), src, 1, false)
+	if err != nil {
+		return err
+	}
+
+	// read gateway id
+	gp.GatewayID = src[0]
+
+	// read gateway address
+	gp.GatewayAddress = nil
+	if len(src) > 1 {
+		gp.GatewayAddress = append([]byte(nil), src[1:]...)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package mqttsn
+
+import "testing"
+
+func TestAdvertise(t *testing.T) {
+	pkt := NewAdvertise()
+	pkt.GatewayID = 7
+	pkt.Duration = 900
+
+	assertCodec(t, pkt, []byte{0x05, byte(ADVERTISE), 0x07, 0x03, 0x84})
+
+	assertDecodeError(t, NewAdvertise(), []byte{0x04, byte(ADVERTISE), 0x07, 0x03})
+}
+
+func TestSearchgw(t *testing.T) {
+	pkt := NewSearchgw()
+	pkt.Radius = 1
+
+	assertCodec(t, pkt, []byte{0x03, byte(SEARCHGW), 0x01})
+
+	assertDecodeError(t, NewSearchgw(), []byte{0x02, byte(SEARCHGW)})
+}
+
+func TestGwinfo(t *testing.T) {
+	pkt := NewGwinfo()
+	pkt.GatewayID = 7
+
+	assertCodec(t, pkt, []byte{0x03, byte(GWINFO), 0x07})
+
+	pkt.GatewayAddress = []byte{127, 0, 0, 1}
+
+	assertCodec(t, pkt, []byte{0x07, byte(GWINFO), 0x07, 127, 0, 0, 1})
+
+	assertDecodeError(t, NewGwinfo(), []byte{0x02, byte(GWINFO)})
+}
@@ -0,0 +1,412 @@
+// Package gateway implements a transparent MQTT-SN gateway that connects
+// MQTT-SN clients over UDP to an MQTT broker.
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/broker"
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/mqttsn"
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+
+	"gopkg.in/tomb.v2"
+)
+
+// ErrClosing is returned if the gateway is closing.
+var ErrClosing = errors.New("closing")
+
+// the maximum size of a received datagram
+const maxDatagramSize = 65535
+
+// A Config holds information about the gateway and the upstream broker.
+type Config struct {
+	// Backend can be set to connect clients to an embedded broker engine that
+	// uses the backend instead of connecting to an upstream broker.
+	Backend broker.Backend
+
+	// BrokerURL is the url of the upstream broker. It is not required if a
+	// backend is configured.
+	BrokerURL string
+
+	// Dialer can be set to use a custom dialer for upstream connections.
+	Dialer client.Dialer
+
+	// GatewayID is the id that is announced to clients.
+	GatewayID byte
+
+	// PredefinedTopics maps predefined topic ids to topic names.
+	PredefinedTopics map[uint16]string
+
+	// KeepAlive is the keep alive used for upstream connections e.g. "30s".
+	KeepAlive string
+
+	// QueueSize defines the maximum amount of messages that are queued for a
+	// client. Most messages are queued while a client is sleeping. The oldest
+	// message is dropped if the queue is full. It also limits the number of
+	// pending connection-less publishes, of which the newest are dropped.
+	QueueSize int
+
+	// RetryTimeout defines the time after which unacknowledged packets are
+	// sent again to a client.
+	RetryTimeout time.Duration
+
+	// MaxRetries defines the amount of retransmissions after which a client
+	// is considered lost.
+	MaxRetries int
+
+	// Timeout defines the time to wait for upstream operations to complete.
+	Timeout time.Duration
+
+	// OnError can be used to receive errors from the gateway. If an error is
+	// received the gateway should be restarted.
+	OnError func(error)
+}
+
+// NewConfig creates a new Config using the specified URL.
+func NewConfig(url string) *Config {
+	return &Config{
+		BrokerURL:    url,
+		KeepAlive:    "30s",
+		QueueSize:    100,
+		RetryTimeout: 5 * time.Second,
+		MaxRetries:   3,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// NewConfigWithBackend creates a new Config using the specified backend.
+func NewConfigWithBackend(backend broker.Backend) *Config {
+	config := NewConfig("mem://gateway")
+	config.Backend = backend
+	return config
+}
+
+// The Gateway translates between MQTT-SN clients and an MQTT broker. Every
+// connected client is mapped to a separate upstream client connection. Clients
+// that publish with QOS level -1 share an anonymous upstream connection.
+type Gateway struct {
+	config *Config
+	dialer client.Dialer
+	engine *broker.Engine
+	conn   net.PacketConn
+
+	predefined map[string]uint16
+	sessions   map[string]*session
+	anonQueue  chan *mqttsn.Publish
+	anonymous  *client.Client
+
+	mutex sync.Mutex
+	tomb  tomb.Tomb
+}
+
+// New creates a new gateway using the provided config.
+func New(config *Config) *Gateway {
+	// check config
+	if config == nil {
+		panic("missing config")
+	}
+
+	// prepare gateway
+	g := &Gateway{
+		config:     config,
+		dialer:     config.Dialer,
+		predefined: make(map[string]uint16),
+		sessions:   make(map[string]*session),
+		anonQueue:  make(chan *mqttsn.Publish, config.QueueSize),
+	}
+
+	// prepare embedded engine
+	if config.Backend != nil {
+		g.engine = broker.NewEngine(config.Backend)
+		g.dialer = &backendDialer{engine: g.engine}
+	}
+
+	// index predefined topics
+	for id, topic := range config.PredefinedTopics {
+		g.predefined[topic] = id
+	}
+
+	return g
+}
+
+// Serve begins serving clients using the provided packet connection.
+func (g *Gateway) Serve(conn net.PacketConn) {
+	// save conn
+	g.conn = conn
+
+	// run reader and publisher
+	g.tomb.Go(g.reader)
+	g.tomb.Go(g.publisher)
+}
+
+// Listen opens a UDP socket on the provided address and begins serving clients.
+func (g *Gateway) Listen(address string) error {
+	// open socket
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+
+	// serve conn
+	g.Serve(conn)
+
+	return nil
+}
+
+// Addr returns the local address of the gateway or nil if the gateway is not
+// serving.
+func (g *Gateway) Addr() net.Addr {
+	// check conn
+	if g.conn == nil {
+		return nil
+	}
+
+	return g.conn.LocalAddr()
+}
+
+// Close will close the socket, disconnect all upstream connections and close
+// the embedded engine if a backend is configured. The call will block until
+// all clients have been closed.
+func (g *Gateway) Close() error {
+	// check conn
+	if g.conn == nil {
+		// close engine
+		if g.engine != nil {
+			g.engine.Close()
+		}
+
+		return nil
+	}
+
+	// stop goroutines
+	g.tomb.Kill(nil)
+
+	// close socket
+	err := g.conn.Close()
+
+	// wait for exit
+	_ = g.tomb.Wait()
+
+	// disconnect anonymous client
+	if g.anonymous != nil {
+		_ = g.anonymous.Disconnect()
+		g.anonymous = nil
+	}
+
+	// close engine
+	if g.engine != nil {
+		g.engine.Close()
+	}
+
+	return err
+}
+
+func (g *Gateway) reader() error {
+	// prepare buffer
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		// read next datagram
+		n, addr, err := g.conn.ReadFrom(buf)
+		if err != nil {
+			// ignore errors when closing
+			if !g.tomb.Alive() {
+				return nil
+			}
+
+			// call error callback if available
+			if g.config.OnError != nil {
+				g.config.OnError(err)
+			}
+
+			return err
+		}
+
+		// decode packet
+		pkt, err := mqttsn.Decode(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		// dispatch packet
+		g.dispatch(pkt, addr)
+	}
+}
+
+func (g *Gateway) dispatch(pkt mqttsn.Generic, addr net.Addr) {
+	// answer searches
+	if _, ok := pkt.(*mqttsn.Searchgw); ok {
+		g.send(&mqttsn.Gwinfo{GatewayID: g.config.GatewayID}, addr)
+		return
+	}
+
+	// handle connection-less publishes
+	if publish, ok := pkt.(*mqttsn.Publish); ok && publish.QOS == mqttsn.QOSMinusOne {
+		select {
+		case g.anonQueue <- publish:
+		default:
+			// drop publish if the queue is full
+		}
+
+		return
+	}
+
+	// acquire mutex
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	// get session
+	s, ok := g.sessions[addr.String()]
+	if !ok {
+		// ignore pings and disconnects from unknown clients
+		if pkt.Type() == mqttsn.PINGREQ || pkt.Type() == mqttsn.DISCONNECT {
+			return
+		}
+
+		// tell unknown clients to reconnect
+		if pkt.Type() != mqttsn.CONNECT {
+			g.send(mqttsn.NewDisconnect(), addr)
+			return
+		}
+
+		// create session
+		s = newSession(g, addr)
+		g.sessions[addr.String()] = s
+
+		// run session
+		g.tomb.Go(s.run)
+	}
+
+	// queue packet
+	select {
+	case s.inbox <- pkt:
+	default:
+		// drop packet if the session is congested
+	}
+}
+
+func (g *Gateway) remove(s *session) {
+	// acquire mutex
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	// remove session if still current
+	if g.sessions[s.addr.String()] == s {
+		delete(g.sessions, s.addr.String())
+	}
+}
+
+func (g *Gateway) send(pkt mqttsn.Generic, addr net.Addr) {
+	// encode packet
+	buf, err := mqttsn.Encode(pkt)
+	if err != nil {
+		return
+	}
+
+	// write datagram
+	_, _ = g.conn.WriteTo(buf, addr)
+}
+
+func (g *Gateway) resolve(tit mqttsn.TopicIDType, id uint16) (string, bool) {
+	switch tit {
+	case mqttsn.PredefinedTopicID:
+		topic, ok := g.config.PredefinedTopics[id]
+		return topic, ok
+	case mqttsn.ShortTopicName:
+		return mqttsn.ShortTopic(id), true
+	}
+
+	return "", false
+}
+
+func (g *Gateway) publisher() error {
+	for {
+		select {
+		case publish := <-g.anonQueue:
+			g.publishAnonymous(publish)
+		case <-g.tomb.Dying():
+			return tomb.ErrDying
+		}
+	}
+}
+
+func (g *Gateway) publishAnonymous(publish *mqttsn.Publish) {
+	// resolve topic
+	topic, ok := g.resolve(publish.TopicIDType, publish.TopicID)
+	if !ok {
+		return
+	}
+
+	// connect anonymous client if missing
+	if g.anonymous == nil {
+		c := client.New()
+		err := g.connect(c, g.upstreamConfig())
+		if err != nil {
+			return
+		}
+
+		g.anonymous = c
+	}
+
+	// publish message
+	_, err := g.anonymous.Publish(topic, publish.Data, 0, publish.Retain)
+	if err != nil {
+		_ = g.anonymous.Close()
+		g.anonymous = nil
+	}
+}
+
+func (g *Gateway) upstreamConfig() *client.Config {
+	// prepare config
+	config := client.NewConfig(g.config.BrokerURL)
+	config.Dialer = g.dialer
+	config.KeepAlive = g.config.KeepAlive
+	config.ValidateSubs = false
+
+	return config
+}
+
+func (g *Gateway) connect(c *client.Client, config *client.Config) error {
+	// connect client
+	cf, err := c.Connect(config)
+	if err != nil {
+		return err
+	}
+
+	// wait for connack
+	err = cf.Wait(g.config.Timeout)
+	if err != nil {
+		_ = c.Close()
+		return err
+	}
+
+	// check return code
+	if cf.ReturnCode() != packet.ConnectionAccepted {
+		_ = c.Close()
+		return fmt.Errorf("connection rejected: %s", cf.ReturnCode().String())
+	}
+
+	return nil
+}
+
+type backendDialer struct {
+	engine *broker.Engine
+}
+
+func (d *backendDialer) Dial(string) (transport.Conn, error) {
+	// create connection pair
+	conn1, conn2 := transport.NewMemoryConnPair("gateway")
+
+	// handle remote end
+	if !d.engine.Handle(conn2) {
+		return nil, ErrClosing
+	}
+
+	return conn1, nil
+}
@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/broker"
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/mqttsn"
+	"github.com/256dpi/gomqtt/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewaySearch(t *testing.T) {
+	g := testGateway(t, nil)
+	c := dialGateway(t, g)
+
+	c.request(&mqttsn.Searchgw{Radius: 1}, &mqttsn.Gwinfo{GatewayID: 7})
+}
+
+func TestGatewayUnknownClient(t *testing.T) {
+	g := testGateway(t, nil)
+	c := dialGateway(t, g)
+
+	c.request(&mqttsn.Register{MessageID: 1, TopicName: "foo"}, mqttsn.NewDisconnect())
+
+	c.send(mqttsn.NewPingreq())
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestGatewayInvalidDatagram(t *testing.T) {
+	g := testGateway(t, nil)
+	c := dialGateway(t, g)
+
+	_, err := c.conn.Write([]byte{0x05, 0x03})
+	require.NoError(t, err)
+
+	c.request(&mqttsn.Searchgw{Radius: 1}, &mqttsn.Gwinfo{GatewayID: 7})
+}
+
+func TestGatewayQOSMinusOne(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "#", 0)
+
+	c := dialGateway(t, g)
+
+	c.send(&mqttsn.Publish{
+		QOS:         mqttsn.QOSMinusOne,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		Data:        []byte("pre"),
+	})
+
+	id, _ := mqttsn.ShortTopicID("ab")
+	c.send(&mqttsn.Publish{
+		QOS:         mqttsn.QOSMinusOne,
+		TopicIDType: mqttsn.ShortTopicName,
+		TopicID:     id,
+		Data:        []byte("short"),
+	})
+
+	msg := receiveMessage(t, ch)
+	assert.Equal(t, "pre/topic", msg.Topic)
+	assert.Equal(t, []byte("pre"), msg.Payload)
+
+	msg = receiveMessage(t, ch)
+	assert.Equal(t, "ab", msg.Topic)
+	assert.Equal(t, []byte("short"), msg.Payload)
+
+	c.expectNothing(100 * time.Millisecond)
+}
+
+type blockingDialer struct {
+	release chan struct{}
+}
+
+func (d *blockingDialer) Dial(string) (transport.Conn, error) {
+	<-d.release
+	return nil, errors.New("failed")
+}
+
+func TestGatewayQOSMinusOneBlockingUpstream(t *testing.T) {
+	dialer := &blockingDialer{release: make(chan struct{})}
+
+	g := testGateway(t, func(config *Config) {
+		config.Backend = nil
+		config.Dialer = dialer
+	})
+
+	c := dialGateway(t, g)
+
+	c.send(&mqttsn.Publish{
+		QOS:         mqttsn.QOSMinusOne,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		Data:        []byte("pre"),
+	})
+
+	c.request(&mqttsn.Searchgw{Radius: 1}, &mqttsn.Gwinfo{GatewayID: 7})
+
+	close(dialer.release)
+}
+
+func TestGatewayNotServing(t *testing.T) {
+	g := New(NewConfig("tcp://localhost:1"))
+	assert.Nil(t, g.Addr())
+	assert.NoError(t, g.Close())
+}
+
+func TestGatewayServe(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	config := NewConfig("tcp://localhost:1")
+	config.Timeout = 100 * time.Millisecond
+
+	g := New(config)
+	g.Serve(conn)
+	assert.Equal(t, conn.LocalAddr(), g.Addr())
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{ClientID: "c1"}, &mqttsn.Connack{ReturnCode: mqttsn.RejectedCongestion})
+
+	assert.NoError(t, g.Close())
+}
+
+func TestGatewayClose(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "will", 0)
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{Will: true, ClientID: "c1"}, mqttsn.NewWilltopicreq())
+	c.request(&mqttsn.Willtopic{Topic: "will"}, mqttsn.NewWillmsgreq())
+	c.request(&mqttsn.Willmsg{Message: []byte("bye")}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	assert.NoError(t, g.Close())
+
+	select {
+	case <-ch:
+		assert.Fail(t, "unexpected will")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestGatewayCloseEngine(t *testing.T) {
+	g := testGateway(t, nil)
+	assert.NoError(t, g.Close())
+
+	conn, err := g.dialer.Dial("")
+	assert.Equal(t, ErrClosing, err)
+	assert.Nil(t, conn)
+
+	g = New(NewConfigWithBackend(broker.NewMemoryBackend()))
+	assert.NoError(t, g.Close())
+
+	conn, err = g.dialer.Dial("")
+	assert.Equal(t, ErrClosing, err)
+	assert.Nil(t, conn)
+}
+
+func TestGatewayUpstreamFailure(t *testing.T) {
+	g := testGateway(t, nil)
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	// take over upstream session
+	config := g.upstreamConfig()
+	config.ClientID = "c1"
+	cl := client.New()
+	cf, err := cl.Connect(config)
+	require.NoError(t, err)
+	require.NoError(t, cf.Wait(time.Second))
+
+	c.expect(mqttsn.NewDisconnect())
+
+	c.request(&mqttsn.Register{MessageID: 1, TopicName: "foo"}, mqttsn.NewDisconnect())
+
+	require.NoError(t, cl.Disconnect())
+}
@@ -0,0 +1,835 @@
+package gateway
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/mqttsn"
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// the size of the session inbox
+const inboxSize = 64
+
+type state int
+
+const (
+	awaitWillTopic state = iota
+	awaitWillMsg
+	active
+	asleep
+	awake
+)
+
+// an unacknowledged packet sent to the client
+type inflight struct {
+	msg      *packet.Message
+	pkt      mqttsn.Generic
+	retries  int
+	deadline time.Time
+}
+
+// the error of an upstream client
+type upstreamError struct {
+	client *client.Client
+	err    error
+}
+
+type session struct {
+	gateway *Gateway
+	addr    net.Addr
+
+	inbox  chan mqttsn.Generic
+	notify chan struct{}
+	failed chan upstreamError
+
+	state    state
+	connect  *mqttsn.Connect
+	will     *packet.Message
+	client   *client.Client
+	duration time.Duration
+	deadline time.Time
+
+	topics   map[string]uint16
+	names    map[uint16]string
+	nextID   uint16
+	nextMsg  uint16
+	received map[uint16]bool
+	inflight *inflight
+
+	queue []*packet.Message
+	mutex sync.Mutex
+}
+
+func newSession(g *Gateway, addr net.Addr) *session {
+	return &session{
+		gateway:  g,
+		addr:     addr,
+		inbox:    make(chan mqttsn.Generic, inboxSize),
+		notify:   make(chan struct{}, 1),
+		failed:   make(chan upstreamError, 1),
+		topics:   make(map[string]uint16),
+		names:    make(map[uint16]string),
+		received: make(map[uint16]bool),
+	}
+}
+
+func (s *session) run() error {
+	// ensure cleanup
+	defer s.gateway.remove(s)
+
+	for {
+		// prepare timer
+		timer := time.NewTimer(s.timeout())
+
+		select {
+		case pkt := <-s.inbox:
+			timer.Stop()
+
+			// handle packet
+			if !s.handle(pkt) {
+				return nil
+			}
+		case <-s.notify:
+			timer.Stop()
+
+			// deliver queued messages
+			s.deliver()
+		case e := <-s.failed:
+			timer.Stop()
+
+			// ignore errors from replaced clients
+			if e.client != s.client {
+				continue
+			}
+
+			// close session
+			s.gateway.remove(s)
+			s.send(mqttsn.NewDisconnect())
+			s.client = nil
+
+			return nil
+		case <-timer.C:
+			// check deadlines
+			if !s.check() {
+				return nil
+			}
+		case <-s.gateway.tomb.Dying():
+			timer.Stop()
+
+			// disconnect upstream client
+			if s.client != nil {
+				_ = s.client.Disconnect()
+			}
+
+			return nil
+		}
+	}
+}
+
+func (s *session) timeout() time.Duration {
+	// get earliest deadline
+	deadline := s.deadline
+	if s.inflight != nil && (s.state == active || s.state == awake) {
+		if deadline.IsZero() || s.inflight.deadline.Before(deadline) {
+			deadline = s.inflight.deadline
+		}
+	}
+
+	// wait for packets if no deadline is set
+	if deadline.IsZero() {
+		return time.Hour
+	}
+
+	return time.Until(deadline)
+}
+
+func (s *session) check() bool {
+	// get time
+	now := time.Now()
+
+	// check session deadline
+	if !s.deadline.IsZero() && now.After(s.deadline) {
+		s.lost()
+		return false
+	}
+
+	// check inflight packet
+	if s.inflight == nil || (s.state != active && s.state != awake) || now.Before(s.inflight.deadline) {
+		return true
+	}
+
+	// check retries
+	if s.inflight.retries >= s.gateway.config.MaxRetries {
+		s.lost()
+		return false
+	}
+
+	// set dup flag
+	if publish, ok := s.inflight.pkt.(*mqttsn.Publish); ok {
+		publish.Dup = true
+	}
+
+	// resend packet
+	s.inflight.retries++
+	s.transmit(s.inflight)
+
+	return true
+}
+
+func (s *session) handle(pkt mqttsn.Generic) bool {
+	// handle connects in all states
+	if connect, ok := pkt.(*mqttsn.Connect); ok {
+		return s.handleConnect(connect)
+	}
+
+	// handle will setup
+	switch s.state {
+	case awaitWillTopic:
+		if willTopic, ok := pkt.(*mqttsn.Willtopic); ok {
+			return s.handleWillTopic(willTopic)
+		}
+
+		return true
+	case awaitWillMsg:
+		if willMsg, ok := pkt.(*mqttsn.Willmsg); ok {
+			return s.handleWillMsg(willMsg)
+		}
+
+		return true
+	}
+
+	// refresh keep alive
+	if s.state == active {
+		s.refresh()
+	}
+
+	switch p := pkt.(type) {
+	case *mqttsn.Register:
+		s.handleRegister(p)
+	case *mqttsn.Regack:
+		s.handleRegack(p)
+	case *mqttsn.Publish:
+		s.handlePublish(p)
+	case *mqttsn.Puback:
+		s.handlePuback(p)
+	case *mqttsn.Pubrec:
+		s.handlePubrec(p)
+	case *mqttsn.Pubrel:
+		s.handlePubrel(p)
+	case *mqttsn.Pubcomp:
+		s.handlePubcomp(p)
+	case *mqttsn.Subscribe:
+		s.handleSubscribe(p)
+	case *mqttsn.Unsubscribe:
+		s.handleUnsubscribe(p)
+	case *mqttsn.Pingreq:
+		s.handlePingreq(p)
+	case *mqttsn.Disconnect:
+		return s.handleDisconnect(p)
+	case *mqttsn.Willtopicupd:
+		s.send(&mqttsn.Willtopicresp{ReturnCode: mqttsn.RejectedNotSupported})
+	case *mqttsn.Willmsgupd:
+		s.send(&mqttsn.Willmsgresp{ReturnCode: mqttsn.RejectedNotSupported})
+	}
+
+	return true
+}
+
+func (s *session) handleConnect(connect *mqttsn.Connect) bool {
+	// save connect
+	s.connect = connect
+	s.will = nil
+
+	// request will topic if requested
+	if connect.Will {
+		s.state = awaitWillTopic
+		s.send(mqttsn.NewWilltopicreq())
+		return true
+	}
+
+	return s.open()
+}
+
+func (s *session) handleWillTopic(willTopic *mqttsn.Willtopic) bool {
+	// open connection if will is empty
+	if willTopic.Topic == "" {
+		return s.open()
+	}
+
+	// save will
+	s.will = &packet.Message{
+		Topic:  willTopic.Topic,
+		QOS:    packet.QOS(willTopic.QOS),
+		Retain: willTopic.Retain,
+	}
+
+	// request will message
+	s.state = awaitWillMsg
+	s.send(mqttsn.NewWillmsgreq())
+
+	return true
+}
+
+func (s *session) handleWillMsg(willMsg *mqttsn.Willmsg) bool {
+	// set payload
+	s.will.Payload = willMsg.Message
+
+	return s.open()
+}
+
+func (s *session) open() bool {
+	// close previous client
+	if s.client != nil {
+		_ = s.client.Disconnect()
+		s.client = nil
+	}
+
+	// reset session state if requested
+	if s.connect.CleanSession {
+		s.topics = make(map[string]uint16)
+		s.names = make(map[uint16]string)
+		s.received = make(map[uint16]bool)
+		s.nextID = 0
+		s.inflight = nil
+		s.mutex.Lock()
+		s.queue = nil
+		s.mutex.Unlock()
+	}
+
+	// prepare config
+	config := s.gateway.upstreamConfig()
+	config.ClientID = s.connect.ClientID
+	config.CleanSession = s.connect.CleanSession
+	config.WillMessage = s.will
+
+	// prepare client
+	c := client.New()
+	c.Callback = func(msg *packet.Message, err error) error {
+		// handle errors
+		if err != nil {
+			select {
+			case s.failed <- upstreamError{client: c, err: err}:
+			default:
+			}
+
+			return nil
+		}
+
+		// queue message
+		s.enqueue(msg)
+
+		return nil
+	}
+
+	// connect client
+	err := s.gateway.connect(c, config)
+	if err != nil {
+		s.gateway.remove(s)
+		s.send(&mqttsn.Connack{ReturnCode: mqttsn.RejectedCongestion})
+		return false
+	}
+
+	// set client and state
+	s.client = c
+	s.state = active
+	s.duration = time.Duration(s.connect.Duration) * time.Second
+	s.refresh()
+
+	// acknowledge connection
+	s.send(&mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	// deliver queued messages
+	s.deliver()
+
+	return true
+}
+
+func (s *session) handleRegister(register *mqttsn.Register) {
+	// register topic
+	id := s.register(register.TopicName)
+
+	// acknowledge registration
+	s.send(&mqttsn.Regack{
+		TopicID:    id,
+		MessageID:  register.MessageID,
+		ReturnCode: mqttsn.Accepted,
+	})
+}
+
+func (s *session) handleRegack(regack *mqttsn.Regack) {
+	// check inflight registration
+	if s.inflight == nil {
+		return
+	}
+	register, ok := s.inflight.pkt.(*mqttsn.Register)
+	if !ok || register.MessageID != regack.MessageID {
+		return
+	}
+
+	// drop message if rejected
+	msg := s.inflight.msg
+	s.inflight = nil
+	if regack.ReturnCode != mqttsn.Accepted {
+		s.deliver()
+		return
+	}
+
+	// save registration
+	s.topics[register.TopicName] = register.TopicID
+	s.names[register.TopicID] = register.TopicName
+
+	// publish message
+	s.publish(msg, mqttsn.NormalTopicID, register.TopicID)
+
+	// deliver queued messages
+	s.deliver()
+}
+
+func (s *session) handlePublish(publish *mqttsn.Publish) {
+	// prepare response
+	puback := &mqttsn.Puback{
+		TopicID:   publish.TopicID,
+		MessageID: publish.MessageID,
+	}
+
+	// resolve topic
+	topic, ok := s.resolve(publish.TopicIDType, publish.TopicID)
+	if !ok {
+		puback.ReturnCode = mqttsn.RejectedInvalidTopicID
+		s.send(puback)
+		return
+	}
+
+	// acknowledge duplicates
+	if publish.QOS == mqttsn.QOSExactlyOnce && s.received[publish.MessageID] {
+		s.send(&mqttsn.Pubrec{MessageID: publish.MessageID})
+		return
+	}
+
+	// publish message
+	pf, err := s.client.Publish(topic, publish.Data, packet.QOS(publish.QOS), publish.Retain)
+
+	// return immediately for QOS 0
+	if publish.QOS == mqttsn.QOSAtMostOnce {
+		return
+	}
+
+	// wait for completion
+	if err == nil {
+		err = pf.Wait(s.gateway.config.Timeout)
+	}
+
+	// reject message on errors
+	if err != nil {
+		puback.ReturnCode = mqttsn.RejectedCongestion
+		s.send(puback)
+		return
+	}
+
+	// acknowledge QOS 1 messages
+	if publish.QOS == mqttsn.QOSAtLeastOnce {
+		s.send(puback)
+		return
+	}
+
+	// remember QOS 2 messages
+	s.received[publish.MessageID] = true
+	s.send(&mqttsn.Pubrec{MessageID: publish.MessageID})
+}
+
+func (s *session) handlePubrel(pubrel *mqttsn.Pubrel) {
+	// forget message
+	delete(s.received, pubrel.MessageID)
+
+	// complete flow
+	s.send(&mqttsn.Pubcomp{MessageID: pubrel.MessageID})
+}
+
+func (s *session) handlePuback(puback *mqttsn.Puback) {
+	// check inflight publish
+	if s.inflight == nil {
+		return
+	}
+	publish, ok := s.inflight.pkt.(*mqttsn.Publish)
+	if !ok || publish.MessageID != puback.MessageID {
+		return
+	}
+
+	// forget registration if topic id is invalid
+	if puback.ReturnCode == mqttsn.RejectedInvalidTopicID && publish.TopicIDType == mqttsn.NormalTopicID {
+		delete(s.topics, s.names[publish.TopicID])
+		delete(s.names, publish.TopicID)
+	}
+
+	// complete message
+	s.inflight = nil
+
+	// deliver queued messages
+	s.deliver()
+}
+
+func (s *session) handlePubrec(pubrec *mqttsn.Pubrec) {
+	// check inflight packet
+	if s.inflight == nil {
+		return
+	}
+
+	// get message id
+	var id uint16
+	switch p := s.inflight.pkt.(type) {
+	case *mqttsn.Publish:
+		id = p.MessageID
+	case *mqttsn.Pubrel:
+		id = p.MessageID
+	}
+
+	// check message id
+	if id != pubrec.MessageID {
+		return
+	}
+
+	// release message
+	s.inflight.pkt = &mqttsn.Pubrel{MessageID: id}
+	s.inflight.retries = 0
+	s.transmit(s.inflight)
+}
+
+func (s *session) handlePubcomp(pubcomp *mqttsn.Pubcomp) {
+	// check inflight release
+	if s.inflight == nil {
+		return
+	}
+	pubrel, ok := s.inflight.pkt.(*mqttsn.Pubrel)
+	if !ok || pubrel.MessageID != pubcomp.MessageID {
+		return
+	}
+
+	// complete message
+	s.inflight = nil
+
+	// deliver queued messages
+	s.deliver()
+}
+
+func (s *session) handleSubscribe(subscribe *mqttsn.Subscribe) {
+	// prepare response
+	suback := &mqttsn.Suback{
+		MessageID: subscribe.MessageID,
+	}
+
+	// get topic
+	topic := subscribe.TopicName
+	if subscribe.TopicIDType == mqttsn.PredefinedTopicID {
+		var ok bool
+		topic, ok = s.gateway.config.PredefinedTopics[subscribe.TopicID]
+		if !ok {
+			suback.ReturnCode = mqttsn.RejectedInvalidTopicID
+			s.send(suback)
+			return
+		}
+	}
+
+	// subscribe topic
+	sf, err := s.client.Subscribe(topic, packet.QOS(subscribe.QOS))
+	if err == nil {
+		err = sf.Wait(s.gateway.config.Timeout)
+	}
+	if err != nil {
+		suback.ReturnCode = mqttsn.RejectedCongestion
+		s.send(suback)
+		return
+	}
+
+	// check return code
+	rc := sf.ReturnCodes()[0]
+	if rc == packet.QOSFailure {
+		suback.ReturnCode = mqttsn.RejectedNotSupported
+		s.send(suback)
+		return
+	}
+
+	// set granted qos
+	suback.QOS = mqttsn.QOS(rc)
+
+	// set topic id
+	switch subscribe.TopicIDType {
+	case mqttsn.PredefinedTopicID:
+		suback.TopicID = subscribe.TopicID
+	case mqttsn.NormalTopicID:
+		if !strings.ContainsAny(topic, "+#") {
+			suback.TopicID = s.register(topic)
+		}
+	}
+
+	// acknowledge subscription
+	s.send(suback)
+}
+
+func (s *session) handleUnsubscribe(unsubscribe *mqttsn.Unsubscribe) {
+	// get topic
+	topic := unsubscribe.TopicName
+	if unsubscribe.TopicIDType == mqttsn.PredefinedTopicID {
+		topic = s.gateway.config.PredefinedTopics[unsubscribe.TopicID]
+	}
+
+	// unsubscribe topic
+	if topic != "" {
+		uf, err := s.client.Unsubscribe(topic)
+		if err == nil {
+			_ = uf.Wait(s.gateway.config.Timeout)
+		}
+	}
+
+	// acknowledge unsubscription
+	s.send(&mqttsn.Unsuback{MessageID: unsubscribe.MessageID})
+}
+
+func (s *session) handlePingreq(pingreq *mqttsn.Pingreq) {
+	// respond immediately if not sleeping
+	if s.state != asleep {
+		s.send(mqttsn.NewPingresp())
+		return
+	}
+
+	// ignore pings without client id
+	if pingreq.ClientID == "" {
+		return
+	}
+
+	// wake up client
+	s.state = awake
+
+	// resend inflight packet
+	if s.inflight != nil {
+		s.transmit(s.inflight)
+	}
+
+	// deliver queued messages
+	s.deliver()
+}
+
+func (s *session) handleDisconnect(disconnect *mqttsn.Disconnect) bool {
+	// close session if no duration is provided
+	if disconnect.Duration == 0 {
+		s.gateway.remove(s)
+		_ = s.client.Disconnect()
+		s.client = nil
+		s.send(mqttsn.NewDisconnect())
+		return false
+	}
+
+	// put client to sleep
+	s.state = asleep
+	s.duration = time.Duration(disconnect.Duration) * time.Second
+	s.refresh()
+
+	// acknowledge sleep
+	s.send(mqttsn.NewDisconnect())
+
+	return true
+}
+
+func (s *session) deliver() {
+	// deliver messages while the client is reachable
+	for s.inflight == nil && (s.state == active || s.state == awake) {
+		// get next message
+		msg := s.dequeue()
+		if msg == nil {
+			break
+		}
+
+		// get topic id
+		tit, id, ok := s.lookup(msg.Topic)
+		if !ok {
+			// register topic
+			s.inflight = &inflight{msg: msg, pkt: &mqttsn.Register{
+				TopicID:   s.allocate(),
+				MessageID: s.messageID(),
+				TopicName: msg.Topic,
+			}}
+			s.transmit(s.inflight)
+			break
+		}
+
+		// publish message
+		s.publish(msg, tit, id)
+	}
+
+	// put awake client back to sleep when all messages have been delivered
+	if s.state == awake && s.inflight == nil && s.pending() == 0 {
+		s.state = asleep
+		s.refresh()
+		s.send(mqttsn.NewPingresp())
+	}
+}
+
+func (s *session) publish(msg *packet.Message, tit mqttsn.TopicIDType, id uint16) {
+	// prepare packet
+	publish := &mqttsn.Publish{
+		QOS:         mqttsn.QOS(msg.QOS),
+		Retain:      msg.Retain,
+		TopicIDType: tit,
+		TopicID:     id,
+		Data:        msg.Payload,
+	}
+
+	// send QOS 0 messages directly
+	if msg.QOS == 0 {
+		s.send(publish)
+		return
+	}
+
+	// send message
+	publish.MessageID = s.messageID()
+	s.inflight = &inflight{msg: msg, pkt: publish}
+	s.transmit(s.inflight)
+}
+
+func (s *session) transmit(inflight *inflight) {
+	// set deadline
+	inflight.deadline = time.Now().Add(s.gateway.config.RetryTimeout)
+
+	// send packet
+	s.send(inflight.pkt)
+}
+
+func (s *session) lost() {
+	// remove session
+	s.gateway.remove(s)
+
+	// close upstream client without disconnecting to trigger the will
+	if s.client != nil {
+		_ = s.client.Close()
+		s.client = nil
+	}
+}
+
+func (s *session) refresh() {
+	// clear deadline if no duration is set
+	if s.duration == 0 {
+		s.deadline = time.Time{}
+		return
+	}
+
+	// allow one and a half times the duration
+	s.deadline = time.Now().Add(s.duration * 3 / 2)
+}
+
+func (s *session) send(pkt mqttsn.Generic) {
+	s.gateway.send(pkt, s.addr)
+}
+
+func (s *session) resolve(tit mqttsn.TopicIDType, id uint16) (string, bool) {
+	// resolve registered topics
+	if tit == mqttsn.NormalTopicID {
+		topic, ok := s.names[id]
+		return topic, ok
+	}
+
+	return s.gateway.resolve(tit, id)
+}
+
+func (s *session) lookup(topic string) (mqttsn.TopicIDType, uint16, bool) {
+	// check predefined topics
+	if id, ok := s.gateway.predefined[topic]; ok {
+		return mqttsn.PredefinedTopicID, id, true
+	}
+
+	// check short topics
+	if id, ok := mqttsn.ShortTopicID(topic); ok {
+		return mqttsn.ShortTopicName, id, true
+	}
+
+	// check registered topics
+	if id, ok := s.topics[topic]; ok {
+		return mqttsn.NormalTopicID, id, true
+	}
+
+	return 0, 0, false
+}
+
+func (s *session) register(topic string) uint16 {
+	// return existing registration
+	if id, ok := s.topics[topic]; ok {
+		return id
+	}
+
+	// register topic
+	id := s.allocate()
+	s.topics[topic] = id
+	s.names[id] = topic
+
+	return id
+}
+
+func (s *session) allocate() uint16 {
+	// find next free topic id
+	for {
+		s.nextID++
+		if _, ok := s.names[s.nextID]; s.nextID != 0 && s.nextID != 0xFFFF && !ok {
+			return s.nextID
+		}
+	}
+}
+
+func (s *session) messageID() uint16 {
+	// skip zero
+	s.nextMsg++
+	if s.nextMsg == 0 {
+		s.nextMsg++
+	}
+
+	return s.nextMsg
+}
+
+func (s *session) enqueue(msg *packet.Message) {
+	// acquire mutex
+	s.mutex.Lock()
+
+	// drop oldest message if full
+	if size := s.gateway.config.QueueSize; size > 0 && len(s.queue) >= size {
+		s.queue = s.queue[1:]
+	}
+
+	// add message
+	s.queue = append(s.queue, msg)
+
+	// release mutex
+	s.mutex.Unlock()
+
+	// signal session
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *session) dequeue() *packet.Message {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// check queue
+	if len(s.queue) == 0 {
+		return nil
+	}
+
+	// get message
+	msg := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	return msg
+}
+
+func (s *session) pending() int {
+	// acquire mutex
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.queue)
+}
@@ -0,0 +1,444 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/mqttsn"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionPublishSubscribe(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "foo/bar", 1)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Register{MessageID: 1, TopicName: "foo/bar"}, &mqttsn.Regack{
+		TopicID:   1,
+		MessageID: 1,
+	})
+
+	c.request(&mqttsn.Subscribe{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		MessageID: 2,
+		TopicName: "foo/bar",
+	}, &mqttsn.Suback{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   1,
+		MessageID: 2,
+	})
+
+	c.request(&mqttsn.Publish{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   1,
+		MessageID: 3,
+		Data:      []byte("hello"),
+	}, &mqttsn.Puback{
+		TopicID:   1,
+		MessageID: 3,
+	})
+
+	msg := receiveMessage(t, ch)
+	assert.Equal(t, "foo/bar", msg.Topic)
+	assert.Equal(t, []byte("hello"), msg.Payload)
+
+	c.expect(&mqttsn.Publish{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   1,
+		MessageID: 1,
+		Data:      []byte("hello"),
+	})
+
+	c.send(&mqttsn.Puback{TopicID: 1, MessageID: 1})
+
+	c.request(&mqttsn.Unsubscribe{MessageID: 4, TopicName: "foo/bar"}, &mqttsn.Unsuback{MessageID: 4})
+
+	c.send(&mqttsn.Publish{
+		TopicID: 1,
+		Data:    []byte("hello"),
+	})
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestSessionRegisterDownstream(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Subscribe{
+		MessageID: 1,
+		TopicName: "dev/#",
+	}, &mqttsn.Suback{
+		MessageID: 1,
+	})
+
+	testPublish(t, g, "dev/1", "a", 0)
+
+	c.expect(&mqttsn.Register{
+		TopicID:   1,
+		MessageID: 1,
+		TopicName: "dev/1",
+	})
+
+	c.request(&mqttsn.Regack{TopicID: 1, MessageID: 1}, &mqttsn.Publish{
+		TopicID: 1,
+		Data:    []byte("a"),
+	})
+
+	testPublish(t, g, "dev/1", "b", 0)
+
+	c.expect(&mqttsn.Publish{
+		TopicID: 1,
+		Data:    []byte("b"),
+	})
+
+	testPublish(t, g, "dev/2", "c", 0)
+
+	c.expect(&mqttsn.Register{
+		TopicID:   2,
+		MessageID: 2,
+		TopicName: "dev/2",
+	})
+
+	c.send(&mqttsn.Regack{TopicID: 2, MessageID: 2, ReturnCode: mqttsn.RejectedNotSupported})
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestSessionPredefinedAndShortTopics(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Subscribe{
+		TopicIDType: mqttsn.PredefinedTopicID,
+		MessageID:   1,
+		TopicID:     1,
+	}, &mqttsn.Suback{
+		TopicID:   1,
+		MessageID: 1,
+	})
+
+	c.request(&mqttsn.Subscribe{
+		TopicIDType: mqttsn.PredefinedTopicID,
+		MessageID:   2,
+		TopicID:     2,
+	}, &mqttsn.Suback{
+		MessageID:  2,
+		ReturnCode: mqttsn.RejectedInvalidTopicID,
+	})
+
+	c.request(&mqttsn.Subscribe{
+		TopicIDType: mqttsn.ShortTopicName,
+		MessageID:   3,
+		TopicName:   "ab",
+	}, &mqttsn.Suback{
+		MessageID: 3,
+	})
+
+	testPublish(t, g, "pre/topic", "a", 0)
+
+	c.expect(&mqttsn.Publish{
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		Data:        []byte("a"),
+	})
+
+	testPublish(t, g, "ab", "b", 0)
+
+	id, _ := mqttsn.ShortTopicID("ab")
+	c.expect(&mqttsn.Publish{
+		TopicIDType: mqttsn.ShortTopicName,
+		TopicID:     id,
+		Data:        []byte("b"),
+	})
+
+	c.request(&mqttsn.Unsubscribe{
+		TopicIDType: mqttsn.PredefinedTopicID,
+		MessageID:   4,
+		TopicID:     1,
+	}, &mqttsn.Unsuback{MessageID: 4})
+
+	testPublish(t, g, "pre/topic", "c", 0)
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestSessionInvalidTopicID(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Publish{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   5,
+		MessageID: 1,
+	}, &mqttsn.Puback{
+		TopicID:    5,
+		MessageID:  1,
+		ReturnCode: mqttsn.RejectedInvalidTopicID,
+	})
+}
+
+func TestSessionQOS2(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "pre/topic", 2)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	publish := &mqttsn.Publish{
+		QOS:         mqttsn.QOSExactlyOnce,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		MessageID:   1,
+		Data:        []byte("once"),
+	}
+
+	c.request(publish, &mqttsn.Pubrec{MessageID: 1})
+
+	publish.Dup = true
+	c.request(publish, &mqttsn.Pubrec{MessageID: 1})
+
+	c.request(&mqttsn.Pubrel{MessageID: 1}, &mqttsn.Pubcomp{MessageID: 1})
+
+	msg := receiveMessage(t, ch)
+	assert.Equal(t, []byte("once"), msg.Payload)
+
+	select {
+	case <-ch:
+		assert.Fail(t, "unexpected message")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.request(&mqttsn.Subscribe{
+		QOS:         mqttsn.QOSExactlyOnce,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		MessageID:   2,
+		TopicID:     1,
+	}, &mqttsn.Suback{
+		QOS:       mqttsn.QOSExactlyOnce,
+		TopicID:   1,
+		MessageID: 2,
+	})
+
+	testPublish(t, g, "pre/topic", "twice", 2)
+
+	c.expect(&mqttsn.Publish{
+		QOS:         mqttsn.QOSExactlyOnce,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		MessageID:   1,
+		Data:        []byte("twice"),
+	})
+
+	c.request(&mqttsn.Pubrec{MessageID: 1}, &mqttsn.Pubrel{MessageID: 1})
+	c.request(&mqttsn.Pubrec{MessageID: 1}, &mqttsn.Pubrel{MessageID: 1})
+	c.send(&mqttsn.Pubcomp{MessageID: 1})
+	c.expectNothing(200 * time.Millisecond)
+}
+
+func TestSessionRetries(t *testing.T) {
+	g := testGateway(t, func(config *Config) {
+		config.MaxRetries = 1
+	})
+
+	ch := testSubscriber(t, g, "will", 0)
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{Will: true, ClientID: "c1"}, mqttsn.NewWilltopicreq())
+	c.request(&mqttsn.Willtopic{Topic: "will"}, mqttsn.NewWillmsgreq())
+	c.request(&mqttsn.Willmsg{Message: []byte("bye")}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	c.request(&mqttsn.Subscribe{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		MessageID:   1,
+		TopicID:     1,
+	}, &mqttsn.Suback{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   1,
+		MessageID: 1,
+	})
+
+	testPublish(t, g, "pre/topic", "a", 1)
+
+	publish := &mqttsn.Publish{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.PredefinedTopicID,
+		TopicID:     1,
+		MessageID:   1,
+		Data:        []byte("a"),
+	}
+
+	c.expect(publish)
+
+	publish.Dup = true
+	c.expect(publish)
+
+	msg := receiveMessage(t, ch)
+	assert.Equal(t, "will", msg.Topic)
+	assert.Equal(t, []byte("bye"), msg.Payload)
+
+	c.send(mqttsn.NewPingreq())
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestSessionKeepAlive(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "will", 0)
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{Will: true, Duration: 1, ClientID: "c1"}, mqttsn.NewWilltopicreq())
+	c.request(&mqttsn.Willtopic{Topic: "will"}, mqttsn.NewWillmsgreq())
+	c.request(&mqttsn.Willmsg{Message: []byte("bye")}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	time.Sleep(time.Second)
+	c.request(mqttsn.NewPingreq(), mqttsn.NewPingresp())
+
+	select {
+	case <-ch:
+		assert.Fail(t, "unexpected will")
+	case <-time.After(time.Second):
+	}
+
+	select {
+	case msg := <-ch:
+		assert.Equal(t, []byte("bye"), msg.Payload)
+	case <-time.After(time.Second):
+		assert.Fail(t, "missing will")
+	}
+}
+
+func TestSessionSleep(t *testing.T) {
+	g := testGateway(t, func(config *Config) {
+		config.QueueSize = 2
+	})
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Subscribe{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.ShortTopicName,
+		MessageID:   1,
+		TopicName:   "sl",
+	}, &mqttsn.Suback{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		MessageID: 1,
+	})
+
+	c.request(&mqttsn.Disconnect{Duration: 10}, mqttsn.NewDisconnect())
+
+	testPublish(t, g, "sl", "1", 1)
+	testPublish(t, g, "sl", "2", 1)
+	testPublish(t, g, "sl", "3", 1)
+
+	c.expectNothing(100 * time.Millisecond)
+
+	c.send(&mqttsn.Pingreq{})
+	c.expectNothing(100 * time.Millisecond)
+
+	id, _ := mqttsn.ShortTopicID("sl")
+
+	c.request(&mqttsn.Pingreq{ClientID: "c1"}, &mqttsn.Publish{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.ShortTopicName,
+		TopicID:     id,
+		MessageID:   1,
+		Data:        []byte("2"),
+	})
+
+	c.request(&mqttsn.Puback{TopicID: id, MessageID: 1}, &mqttsn.Publish{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.ShortTopicName,
+		TopicID:     id,
+		MessageID:   2,
+		Data:        []byte("3"),
+	})
+
+	c.request(&mqttsn.Puback{TopicID: id, MessageID: 2}, mqttsn.NewPingresp())
+
+	testPublish(t, g, "sl", "4", 1)
+	c.expectNothing(100 * time.Millisecond)
+
+	c.request(&mqttsn.Connect{Duration: 10, ClientID: "c1"}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	c.expect(&mqttsn.Publish{
+		QOS:         mqttsn.QOSAtLeastOnce,
+		TopicIDType: mqttsn.ShortTopicName,
+		TopicID:     id,
+		MessageID:   3,
+		Data:        []byte("4"),
+	})
+}
+
+func TestSessionSleepTimeout(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Disconnect{Duration: 1}, mqttsn.NewDisconnect())
+
+	time.Sleep(2 * time.Second)
+
+	c.send(&mqttsn.Pingreq{ClientID: "c1"})
+	c.expectNothing(100 * time.Millisecond)
+}
+
+func TestSessionDisconnect(t *testing.T) {
+	g := testGateway(t, nil)
+	ch := testSubscriber(t, g, "will", 0)
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{Will: true, ClientID: "c1"}, mqttsn.NewWilltopicreq())
+	c.request(&mqttsn.Willtopic{Topic: "will"}, mqttsn.NewWillmsgreq())
+	c.request(&mqttsn.Willmsg{Message: []byte("bye")}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	c.request(mqttsn.NewDisconnect(), mqttsn.NewDisconnect())
+
+	select {
+	case <-ch:
+		assert.Fail(t, "unexpected will")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.request(&mqttsn.Register{MessageID: 1, TopicName: "foo"}, mqttsn.NewDisconnect())
+}
+
+func TestSessionWillUpdate(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.request(&mqttsn.Connect{Will: true, ClientID: "c1"}, mqttsn.NewWilltopicreq())
+	c.request(&mqttsn.Willtopic{}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+
+	c.request(&mqttsn.Willtopicupd{Topic: "will"}, &mqttsn.Willtopicresp{ReturnCode: mqttsn.RejectedNotSupported})
+	c.request(&mqttsn.Willmsgupd{Message: []byte("bye")}, &mqttsn.Willmsgresp{ReturnCode: mqttsn.RejectedNotSupported})
+}
+
+func TestSessionReconnect(t *testing.T) {
+	g := testGateway(t, nil)
+
+	c := dialGateway(t, g)
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Register{MessageID: 1, TopicName: "foo"}, &mqttsn.Regack{TopicID: 1, MessageID: 1})
+
+	c.connect("c1", 0)
+
+	c.request(&mqttsn.Publish{
+		QOS:       mqttsn.QOSAtLeastOnce,
+		TopicID:   1,
+		MessageID: 2,
+	}, &mqttsn.Puback{
+		TopicID:    1,
+		MessageID:  2,
+		ReturnCode: mqttsn.RejectedInvalidTopicID,
+	})
+}
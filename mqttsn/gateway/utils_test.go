@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/broker"
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/mqttsn"
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGateway(t *testing.T, fn func(*Config)) *Gateway {
+	// prepare config
+	config := NewConfigWithBackend(broker.NewMemoryBackend())
+	config.GatewayID = 7
+	config.PredefinedTopics = map[uint16]string{1: "pre/topic"}
+	config.RetryTimeout = 100 * time.Millisecond
+	if fn != nil {
+		fn(config)
+	}
+
+	// start gateway
+	g := New(config)
+	err := g.Listen("127.0.0.1:0")
+	require.NoError(t, err)
+
+	// ensure close
+	t.Cleanup(func() {
+		_ = g.Close()
+	})
+
+	return g
+}
+
+func testSubscriber(t *testing.T, g *Gateway, topic string, qos packet.QOS) chan *packet.Message {
+	// prepare channel
+	ch := make(chan *packet.Message, 10)
+
+	// prepare client
+	c := client.New()
+	c.Callback = func(msg *packet.Message, err error) error {
+		if err == nil {
+			ch <- msg
+		}
+
+		return nil
+	}
+
+	// connect client
+	cf, err := c.Connect(g.upstreamConfig())
+	require.NoError(t, err)
+	require.NoError(t, cf.Wait(time.Second))
+
+	// subscribe topic
+	if topic != "" {
+		sf, err := c.Subscribe(topic, qos)
+		require.NoError(t, err)
+		require.NoError(t, sf.Wait(time.Second))
+	}
+
+	// ensure disconnect
+	t.Cleanup(func() {
+		_ = c.Disconnect()
+	})
+
+	return ch
+}
+
+func testPublish(t *testing.T, g *Gateway, topic string, payload string, qos packet.QOS) {
+	// prepare client
+	c := client.New()
+
+	// connect client
+	cf, err := c.Connect(g.upstreamConfig())
+	require.NoError(t, err)
+	require.NoError(t, cf.Wait(time.Second))
+
+	// publish message
+	pf, err := c.Publish(topic, []byte(payload), qos, false)
+	require.NoError(t, err)
+	require.NoError(t, pf.Wait(time.Second))
+
+	// disconnect client
+	require.NoError(t, c.Disconnect())
+}
+
+func receiveMessage(t *testing.T, ch chan *packet.Message) *packet.Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		require.Fail(t, "nothing received")
+		return nil
+	}
+}
+
+type snClient struct {
+	t    *testing.T
+	conn *net.UDPConn
+}
+
+func dialGateway(t *testing.T, g *Gateway) *snClient {
+	// open socket
+	conn, err := net.DialUDP("udp", nil, g.Addr().(*net.UDPAddr))
+	require.NoError(t, err)
+
+	// ensure close
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return &snClient{t: t, conn: conn}
+}
+
+func (c *snClient) send(pkt mqttsn.Generic) {
+	buf, err := mqttsn.Encode(pkt)
+	require.NoError(c.t, err)
+
+	_, err = c.conn.Write(buf)
+	require.NoError(c.t, err)
+}
+
+func (c *snClient) receive(timeout time.Duration) (mqttsn.Generic, error) {
+	err := c.conn.SetReadDeadline(time.Now().Add(timeout))
+	require.NoError(c.t, err)
+
+	buf := make([]byte, 65535)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return mqttsn.Decode(buf[:n])
+}
+
+func (c *snClient) expect(pkt mqttsn.Generic) {
+	c.t.Helper()
+
+	res, err := c.receive(time.Second)
+	require.NoError(c.t, err)
+	assert.Equal(c.t, pkt, res)
+}
+
+func (c *snClient) expectNothing(timeout time.Duration) {
+	c.t.Helper()
+
+	pkt, err := c.receive(timeout)
+	assert.Error(c.t, err)
+	assert.Nil(c.t, pkt)
+}
+
+func (c *snClient) request(pkt, res mqttsn.Generic) {
+	c.t.Helper()
+
+	c.send(pkt)
+	c.expect(res)
+}
+
+func (c *snClient) connect(id string, duration uint16) {
+	c.t.Helper()
+
+	c.request(&mqttsn.Connect{
+		CleanSession: true,
+		Duration:     duration,
+		ClientID:     id,
+	}, &mqttsn.Connack{ReturnCode: mqttsn.Accepted})
+}
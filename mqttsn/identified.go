@@ -0,0 +1,230 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// decodes the body of an identified packet
+func identifiedDecode(src []byte, t Type) (uint16, error) {
+	// check length
+	err := checkLen(t, src, 2, true)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint16(src), nil
+}
+
+// A Pubrec packet is the first response to a Publish packet with QOS level 2.
+type Pubrec struct {
+	// The message identifier.
+	MessageID uint16
+}
+
+// NewPubrec creates a new Pubrec packet.
+func NewPubrec() *Pubrec {
+	return &Pubrec{}
+}
+
+// Type returns the packets type.
+func (pp *Pubrec) Type() Type {
+	return PUBREC
+}
+
+// String returns a string representation of the packet.
+func (pp *Pubrec) String() string {
+	return fmt.Sprintf("<Pubrec MessageID=%d>", pp.MessageID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Pubrec) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Pubrec) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Pubrec) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Pubrec) bodyLen() int {
+	return 2
+}
+
+func (pp *Pubrec) encodeBody(dst []byte) error {
+	binary.BigEndian.PutUint16(dst, pp.MessageID)
+	return nil
+}
+
+func (pp *Pubrec) decodeBody(src []byte) (err error) {
+	pp.MessageID, err = identifiedDecode(src, PUBREC)
+	return err
+}
+
+// A Pubrel packet is the response to a Pubrec packet.
+type Pubrel struct {
+	// The message identifier.
+	MessageID uint16
+}
+
+// NewPubrel creates a new Pubrel packet.
+func NewPubrel() *Pubrel {
+	return &Pubrel{}
+}
+
+// Type returns the packets type.
+func (pp *Pubrel) Type() Type {
+	return PUBREL
+}
+
+// String returns a string representation of the packet.
+func (pp *Pubrel) String() string {
+	return fmt.Sprintf("<Pubrel MessageID=%d>", pp.MessageID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Pubrel) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Pubrel) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Pubrel) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Pubrel) bodyLen() int {
+	return 2
+}
+
+func (pp *Pubrel) encodeBody(dst []byte) error {
+	binary.BigEndian.PutUint16(dst, pp.MessageID)
+	return nil
+}
+
+func (pp *Pubrel) decodeBody(src []byte) (err error) {
+	pp.MessageID, err = identifiedDecode(src, PUBREL)
+	return err
+}
+
+// A Pubcomp packet is the response to a Pubrel packet.
+type Pubcomp struct {
+	// The message identifier.
+	MessageID uint16
+}
+
+// NewPubcomp creates a new Pubcomp packet.
+func NewPubcomp() *Pubcomp {
+	return &Pubcomp{}
+}
+
+// Type returns the packets type.
+func (pp *Pubcomp) Type() Type {
+	return PUBCOMP
+}
+
+// String returns a string representation of the packet.
+func (pp *Pubcomp) String() string {
+	return fmt.Sprintf("<Pubcomp MessageID=%d>", pp.MessageID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Pubcomp) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Pubcomp) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Pubcomp) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Pubcomp) bodyLen() int {
+	return 2
+}
+
+func (pp *Pubcomp) encodeBody(dst []byte) error {
+	binary.BigEndian.PutUint16(dst, pp.MessageID)
+	return nil
+}
+
+func (pp *Pubcomp) decodeBody(src []byte) (err error) {
+	pp.MessageID, err = identifiedDecode(src, PUBCOMP)
+	return err
+}
+
+// An Unsuback packet is sent by the gateway to the client to confirm the
+// receipt of an Unsubscribe packet.
+type Unsuback struct {
+	// The message identifier.
+	MessageID uint16
+}
+
+// NewUnsuback creates a new Unsuback packet.
+func NewUnsuback() *Unsuback {
+	return &Unsuback{}
+}
+
+// Type returns the packets type.
+func (up *Unsuback) Type() Type {
+	return UNSUBACK
+}
+
+// String returns a string representation of the packet.
+func (up *Unsuback) String() string {
+	return fmt.Sprintf("<Unsuback MessageID=%d>", up.MessageID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (up *Unsuback) Len() int {
+	return packetLen(up.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (up *Unsuback) Decode(src []byte) (int, error) {
+	return decode(src, up)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (up *Unsuback) Encode(dst []byte) (int, error) {
+	return encode(dst, up)
+}
+
+func (up *Unsuback) bodyLen() int {
+	return 2
+}
+
+func (up *Unsuback) encodeBody(dst []byte) error {
+	binary.BigEndian.PutUint16(dst, up.MessageID)
+	return nil
+}
+
+func (up *Unsuback) decodeBody(src []byte) (err error) {
+	up.MessageID, err = identifiedDecode(src, UNSUBACK)
+	return err
+}
@@ -0,0 +1,20 @@
+package mqttsn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdentifiedPackets(t *testing.T) {
+	for _, pkt := range []Generic{
+		&Pubrec{MessageID: 0x0102},
+		&Pubrel{MessageID: 0x0102},
+		&Pubcomp{MessageID: 0x0102},
+		&Unsuback{MessageID: 0x0102},
+	} {
+		assertCodec(t, pkt, []byte{0x04, byte(pkt.Type()), 0x01, 0x02})
+
+		assertDecodeError(t, reflect.New(reflect.TypeOf(pkt).Elem()).Interface().(Generic),
+			[]byte{0x03, byte(pkt.Type()), 0x01})
+	}
+}
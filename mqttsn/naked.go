@@ -0,0 +1,154 @@
+package mqttsn
+
+// decodes the body of a naked packet
+func nakedDecode(src []byte, t Type) error {
+	return checkLen(t, src, 0, true)
+}
+
+// A Willtopicreq packet is sent by the gateway to request the will topic of a
+// client.
+type Willtopicreq struct{}
+
+// NewWilltopicreq creates a new Willtopicreq packet.
+func NewWilltopicreq() *Willtopicreq {
+	return &Willtopicreq{}
+}
+
+// Type returns the packets type.
+func (wp *Willtopicreq) Type() Type {
+	return WILLTOPICREQ
+}
+
+// String returns a string representation of the packet.
+func (wp *Willtopicreq) String() string {
+	return "<Willtopicreq>"
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willtopicreq) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willtopicreq) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willtopicreq) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willtopicreq) bodyLen() int {
+	return 0
+}
+
+func (wp *Willtopicreq) encodeBody([]byte) error {
+	return nil
+}
+
+func (wp *Willtopicreq) decodeBody(src []byte) error {
+	return nakedDecode(src, WILLTOPICREQ)
+}
+
+// A Willmsgreq packet is sent by the gateway to request the will message of a
+// client.
+type Willmsgreq struct{}
+
+// NewWillmsgreq creates a new Willmsgreq packet.
+func NewWillmsgreq() *Willmsgreq {
+	return &Willmsgreq{}
+}
+
+// Type returns the packets type.
+func (wp *Willmsgreq) Type() Type {
+	return WILLMSGREQ
+}
+
+// String returns a string representation of the packet.
+func (wp *Willmsgreq) String() string {
+	return "<Willmsgreq>"
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willmsgreq) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willmsgreq) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willmsgreq) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willmsgreq) bodyLen() int {
+	return 0
+}
+
+func (wp *Willmsgreq) encodeBody([]byte) error {
+	return nil
+}
+
+func (wp *Willmsgreq) decodeBody(src []byte) error {
+	return nakedDecode(src, WILLMSGREQ)
+}
+
+// A Pingresp packet is sent in response to a Pingreq packet. It is also sent
+// by the gateway to a sleeping client after all buffered messages have been
+// delivered.
+type Pingresp struct{}
+
+// NewPingresp creates a new Pingresp packet.
+func NewPingresp() *Pingresp {
+	return &Pingresp{}
+}
+
+// Type returns the packets type.
+func (pp *Pingresp) Type() Type {
+	return PINGRESP
+}
+
+// String returns a string representation of the packet.
+func (pp *Pingresp) String() string {
+	return "<Pingresp>"
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Pingresp) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Pingresp) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Pingresp) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Pingresp) bodyLen() int {
+	return 0
+}
+
+func (pp *Pingresp) encodeBody([]byte) error {
+	return nil
+}
+
+func (pp *Pingresp) decodeBody(src []byte) error {
+	return nakedDecode(src, PINGRESP)
+}
@@ -0,0 +1,15 @@
+package mqttsn
+
+import "testing"
+
+func TestNakedPackets(t *testing.T) {
+	for _, pkt := range []Generic{
+		NewWilltopicreq(),
+		NewWillmsgreq(),
+		NewPingresp(),
+	} {
+		assertCodec(t, pkt, []byte{0x02, byte(pkt.Type())})
+
+		assertDecodeError(t, pkt, []byte{0x03, byte(pkt.Type()), 0x00})
+	}
+}
@@ -0,0 +1,357 @@
+// Package mqttsn implements functionality for encoding and decoding MQTT-SN 1.2
+// packets.
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// the maximum length of an encoded packet
+const maxLength = 65535
+
+// QOS is the type used to store quality of service levels.
+type QOS int8
+
+const (
+	// QOSAtMostOnce defines that the message is delivered at most once.
+	QOSAtMostOnce QOS = 0
+
+	// QOSAtLeastOnce defines that the message is always delivered at least once.
+	QOSAtLeastOnce QOS = 1
+
+	// QOSExactlyOnce defines that the message is always delivered exactly once.
+	QOSExactlyOnce QOS = 2
+
+	// QOSMinusOne defines that the message is published by a client without
+	// setting up a connection. The topic must be predefined or a short name.
+	QOSMinusOne QOS = -1
+)
+
+// Valid returns whether the quality of service level is valid.
+func (qos QOS) Valid() bool {
+	return qos >= QOSMinusOne && qos <= QOSExactlyOnce
+}
+
+// TopicIDType defines how the topic of a packet is specified.
+type TopicIDType byte
+
+const (
+	// NormalTopicID is a topic id that has been registered by the client or the
+	// gateway. Subscribe and Unsubscribe packets carry a topic name instead.
+	NormalTopicID TopicIDType = 0
+
+	// PredefinedTopicID is a topic id that is known in advance by the client
+	// and the gateway.
+	PredefinedTopicID TopicIDType = 1
+
+	// ShortTopicName is a topic name with a length of two bytes that is
+	// transmitted in place of the topic id.
+	ShortTopicName TopicIDType = 2
+)
+
+// Valid returns whether the topic id type is valid.
+func (t TopicIDType) Valid() bool {
+	return t <= ShortTopicName
+}
+
+// ReturnCode is the type used to store return codes.
+type ReturnCode byte
+
+// All available return codes.
+const (
+	Accepted ReturnCode = iota
+	RejectedCongestion
+	RejectedInvalidTopicID
+	RejectedNotSupported
+)
+
+// Valid returns whether the return code is valid.
+func (rc ReturnCode) Valid() bool {
+	return rc <= RejectedNotSupported
+}
+
+// String returns the corresponding error string for the return code.
+func (rc ReturnCode) String() string {
+	switch rc {
+	case Accepted:
+		return "accepted"
+	case RejectedCongestion:
+		return "rejected: congestion"
+	case RejectedInvalidTopicID:
+		return "rejected: invalid topic ID"
+	case RejectedNotSupported:
+		return "rejected: not supported"
+	}
+
+	return "unknown return code"
+}
+
+// Generic is an MQTT-SN packet that can be encoded to a buffer or decoded from
+// a buffer.
+type Generic interface {
+	// Type returns the packets type.
+	Type() Type
+
+	// Len returns the byte length of the encoded packet.
+	Len() int
+
+	// Decode reads from the byte slice argument. It returns the total number of
+	// bytes decoded, and whether there have been any errors during the process.
+	Decode(src []byte) (int, error)
+
+	// Encode writes the packet bytes into the byte slice from the argument. It
+	// returns the number of bytes encoded and whether there's any errors along
+	// the way. If there is an error, the byte slice should be considered invalid.
+	Encode(dst []byte) (int, error)
+
+	// String returns a string representation of the packet.
+	String() string
+}
+
+// DetectPacket tries to detect the next packet in a buffer. It returns a length
+// greater than zero if the packet has been detected as well as its Type.
+func DetectPacket(src []byte) (int, Type) {
+	// decode header
+	length, hl, typ, err := decodeHeader(src)
+	if err != nil || length < hl {
+		return 0, 0
+	}
+
+	return length, typ
+}
+
+// Decode will decode a single packet from a datagram. An error is returned if
+// the datagram does not contain exactly one packet.
+func Decode(src []byte) (Generic, error) {
+	// detect packet
+	length, typ := DetectPacket(src)
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid packet header")
+	}
+
+	// check length
+	if length != len(src) {
+		return nil, makeError(typ, "packet length (%d) does not match datagram length (%d)", length, len(src))
+	}
+
+	// create packet
+	pkt, err := typ.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// decode packet
+	_, err = pkt.Decode(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+// Encode will encode a single packet into a new buffer.
+func Encode(pkt Generic) ([]byte, error) {
+	// allocate buffer
+	buf := make([]byte, pkt.Len())
+
+	// encode packet
+	n, err := pkt.Encode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// ShortTopicID returns the topic id that represents the provided short topic
+// name. It returns false if the name is not two bytes long.
+func ShortTopicID(name string) (uint16, bool) {
+	// check length
+	if len(name) != 2 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint16([]byte(name)), true
+}
+
+// ShortTopic returns the short topic name represented by the topic id.
+func ShortTopic(id uint16) string {
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, id)
+	return string(buf)
+}
+
+// a packet that is encoded as a header followed by a body
+type body interface {
+	Generic
+
+	// returns the length of the body
+	bodyLen() int
+
+	// encodes the body into a buffer of the body length
+	encodeBody(dst []byte) error
+
+	// decodes the body from a buffer of the body length
+	decodeBody(src []byte) error
+}
+
+// returns the length of a packet with the specified body length
+func packetLen(bl int) int {
+	// use short header if possible
+	if bl+2 <= 255 {
+		return bl + 2
+	}
+
+	return bl + 4
+}
+
+// decodes the header and returns the total length, the header length and the
+// packet type
+func decodeHeader(src []byte) (int, int, Type, error) {
+	// check buffer
+	if len(src) < 2 {
+		return 0, 0, 0, fmt.Errorf("insufficient buffer size, expected 2, got %d", len(src))
+	}
+
+	// read short length
+	if src[0] != 0x01 {
+		return int(src[0]), 2, Type(src[1]), nil
+	}
+
+	// check buffer
+	if len(src) < 4 {
+		return 0, 0, 0, fmt.Errorf("insufficient buffer size, expected 4, got %d", len(src))
+	}
+
+	return int(binary.BigEndian.Uint16(src[1:])), 4, Type(src[3]), nil
+}
+
+// encodes a packet
+func encode(dst []byte, pkt body) (int, error) {
+	// get length
+	total := packetLen(pkt.bodyLen())
+
+	// check length
+	if total > maxLength {
+		return 0, makeError(pkt.Type(), "packet length (%d) out of bound (max %d)", total, maxLength)
+	}
+
+	// check buffer
+	if len(dst) < total {
+		return 0, makeError(pkt.Type(), "insufficient buffer size, expected %d, got %d", total, len(dst))
+	}
+
+	// write header
+	hl := 2
+	if total <= 255 {
+		dst[0] = byte(total)
+		dst[1] = byte(pkt.Type())
+	} else {
+		hl = 4
+		dst[0] = 0x01
+		binary.BigEndian.PutUint16(dst[1:], uint16(total))
+		dst[3] = byte(pkt.Type())
+	}
+
+	// write body
+	err := pkt.encodeBody(dst[hl:total])
+	if err != nil {
+		return hl, err
+	}
+
+	return total, nil
+}
+
+// decodes a packet
+func decode(src []byte, pkt body) (int, error) {
+	// decode header
+	total, hl, typ, err := decodeHeader(src)
+	if err != nil {
+		return 0, makeError(pkt.Type(), err.Error())
+	}
+
+	// check type
+	if typ != pkt.Type() {
+		return hl, makeError(pkt.Type(), "invalid type %d", typ)
+	}
+
+	// check length
+	if total < hl || total > len(src) {
+		return hl, makeError(pkt.Type(), "invalid length %d", total)
+	}
+
+	// read body
+	err = pkt.decodeBody(src[hl:total])
+	if err != nil {
+		return hl, err
+	}
+
+	return total, nil
+}
+
+// checks the length of a body
+func checkLen(t Type, src []byte, min int, exact bool) error {
+	// check length
+	if len(src) < min || (exact && len(src) != min) {
+		return makeError(t, "invalid body length %d, expected %d", len(src), min)
+	}
+
+	return nil
+}
+
+// encodes the flags
+func encodeFlags(dup bool, qos QOS, retain, will, clean bool, tit TopicIDType) byte {
+	// prepare flags
+	var flags byte
+
+	// set dup flag
+	if dup {
+		flags |= 0x80
+	}
+
+	// set qos
+	switch qos {
+	case QOSAtLeastOnce:
+		flags |= 0x20
+	case QOSExactlyOnce:
+		flags |= 0x40
+	case QOSMinusOne:
+		flags |= 0x60
+	}
+
+	// set retain flag
+	if retain {
+		flags |= 0x10
+	}
+
+	// set will flag
+	if will {
+		flags |= 0x08
+	}
+
+	// set clean session flag
+	if clean {
+		flags |= 0x04
+	}
+
+	// set topic id type
+	flags |= byte(tit) & 0x03
+
+	return flags
+}
+
+// decodes the flags
+func decodeFlags(flags byte) (dup bool, qos QOS, retain, will, clean bool, tit TopicIDType) {
+	// get qos
+	switch (flags >> 5) & 0x03 {
+	case 1:
+		qos = QOSAtLeastOnce
+	case 2:
+		qos = QOSExactlyOnce
+	case 3:
+		qos = QOSMinusOne
+	}
+
+	return flags&0x80 != 0, qos, flags&0x10 != 0, flags&0x08 != 0, flags&0x04 != 0, TopicIDType(flags & 0x03)
+}
@@ -0,0 +1,169 @@
+package mqttsn
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQOSValid(t *testing.T) {
+	assert.True(t, QOSMinusOne.Valid())
+	assert.True(t, QOSExactlyOnce.Valid())
+	assert.False(t, QOS(3).Valid())
+	assert.False(t, QOS(-2).Valid())
+}
+
+func TestReturnCode(t *testing.T) {
+	assert.True(t, RejectedNotSupported.Valid())
+	assert.False(t, ReturnCode(4).Valid())
+	assert.Equal(t, "accepted", Accepted.String())
+	assert.Equal(t, "rejected: congestion", RejectedCongestion.String())
+	assert.Equal(t, "rejected: invalid topic ID", RejectedInvalidTopicID.String())
+	assert.Equal(t, "rejected: not supported", RejectedNotSupported.String())
+	assert.Equal(t, "unknown return code", ReturnCode(9).String())
+}
+
+func TestFlags(t *testing.T) {
+	for _, qos := range []QOS{QOSMinusOne, QOSAtMostOnce, QOSAtLeastOnce, QOSExactlyOnce} {
+		for _, tit := range []TopicIDType{NormalTopicID, PredefinedTopicID, ShortTopicName} {
+			flags := encodeFlags(true, qos, true, false, true, tit)
+			dup, qos2, retain, will, clean, tit2 := decodeFlags(flags)
+			assert.True(t, dup)
+			assert.Equal(t, qos, qos2)
+			assert.True(t, retain)
+			assert.False(t, will)
+			assert.True(t, clean)
+			assert.Equal(t, tit, tit2)
+		}
+	}
+
+	assert.Equal(t, byte(0x60), encodeFlags(false, QOSMinusOne, false, false, false, 0))
+	assert.Equal(t, byte(0x9E), encodeFlags(true, 0, true, true, true, ShortTopicName))
+}
+
+func TestShortTopic(t *testing.T) {
+	id, ok := ShortTopicID("ab")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0x6162), id)
+	assert.Equal(t, "ab", ShortTopic(id))
+
+	_, ok = ShortTopicID("abc")
+	assert.False(t, ok)
+}
+
+func TestLongHeader(t *testing.T) {
+	pkt := NewPublish()
+	pkt.TopicID = 1
+	pkt.Data = bytes.Repeat([]byte{'x'}, 300)
+
+	buf, err := Encode(pkt)
+	require.NoError(t, err)
+	assert.Len(t, buf, 309)
+	assert.Equal(t, []byte{0x01, 0x01, 0x35, byte(PUBLISH)}, buf[:4])
+
+	n, typ := DetectPacket(buf)
+	assert.Equal(t, 309, n)
+	assert.Equal(t, PUBLISH, typ)
+
+	pkt2, err := Decode(buf)
+	require.NoError(t, err)
+	assert.Equal(t, pkt, pkt2)
+}
+
+func TestHeaderBoundary(t *testing.T) {
+	pkt := NewWillmsg()
+
+	pkt.Message = make([]byte, 253)
+	assert.Equal(t, 255, pkt.Len())
+
+	pkt.Message = make([]byte, 254)
+	assert.Equal(t, 258, pkt.Len())
+
+	pkt.Message = make([]byte, maxLength)
+	assertEncodeError(t, pkt)
+}
+
+func TestDetectPacket(t *testing.T) {
+	n, typ := DetectPacket([]byte{0x03, byte(SEARCHGW), 0x01})
+	assert.Equal(t, 3, n)
+	assert.Equal(t, SEARCHGW, typ)
+
+	n, _ = DetectPacket([]byte{0x03})
+	assert.Equal(t, 0, n)
+
+	n, _ = DetectPacket([]byte{0x01, 0x00})
+	assert.Equal(t, 0, n)
+
+	n, _ = DetectPacket([]byte{0x01, 0x00, 0x02, byte(PINGRESP)})
+	assert.Equal(t, 0, n)
+}
+
+func TestDecode(t *testing.T) {
+	pkt, err := Decode([]byte{0x02, byte(PINGRESP)})
+	assert.NoError(t, err)
+	assert.Equal(t, NewPingresp(), pkt)
+
+	_, err = Decode([]byte{0x02})
+	assert.Error(t, err)
+
+	_, err = Decode([]byte{0x02, byte(PINGRESP), 0x00})
+	assert.Error(t, err)
+
+	_, err = Decode([]byte{0x02, 0x03})
+	assert.Equal(t, ErrInvalidPacketType, err)
+
+	_, err = Decode([]byte{0x03, byte(CONNACK), 0x09})
+	assert.Error(t, err)
+}
+
+func TestEncode(t *testing.T) {
+	buf, err := Encode(NewPingresp())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x02, byte(PINGRESP)}, buf)
+
+	_, err = Encode(NewConnect())
+	assert.Error(t, err)
+}
+
+func TestDecodeTypeMismatch(t *testing.T) {
+	assertDecodeError(t, NewPingreq(), []byte{0x02, byte(PINGRESP)})
+	assertDecodeError(t, NewPingreq(), []byte{0x05, byte(PINGREQ)})
+	assertDecodeError(t, NewPingreq(), []byte{0x02})
+}
+
+func BenchmarkPublishEncode(b *testing.B) {
+	pkt := NewPublish()
+	pkt.QOS = QOSAtLeastOnce
+	pkt.TopicID = 1
+	pkt.MessageID = 1
+	pkt.Data = []byte("hello world")
+
+	buf := make([]byte, pkt.Len())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := pkt.Encode(buf)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkPublishDecode(b *testing.B) {
+	buf := []byte{0x0c, byte(PUBLISH), 0x20, 0x00, 0x01, 0x00, 0x01, 'h', 'e', 'l', 'l', 'o'}
+	pkt := NewPublish()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := pkt.Decode(buf)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A Publish packet is sent by a client or a gateway to transport a message.
+type Publish struct {
+	// Whether the packet is sent again.
+	Dup bool
+
+	// The QOS level of the message.
+	QOS QOS
+
+	// Whether the message should be retained.
+	Retain bool
+
+	// The type of the topic id.
+	TopicIDType TopicIDType
+
+	// The topic id, predefined topic id or encoded short topic name.
+	TopicID uint16
+
+	// The message identifier. It is zero for QOS levels 0 and -1.
+	MessageID uint16
+
+	// The payload.
+	Data []byte
+}
+
+// NewPublish creates a new Publish packet.
+func NewPublish() *Publish {
+	return &Publish{}
+}
+
+// Type returns the packets type.
+func (pp *Publish) Type() Type {
+	return PUBLISH
+}
+
+// String returns a string representation of the packet.
+func (pp *Publish) String() string {
+	return fmt.Sprintf("<Publish TopicIDType=%d TopicID=%d MessageID=%d QOS=%d Retain=%t Dup=%t Data=%x>",
+		pp.TopicIDType, pp.TopicID, pp.MessageID, pp.QOS, pp.Retain, pp.Dup, pp.Data)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Publish) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Publish) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Publish) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Publish) bodyLen() int {
+	return 5 + len(pp.Data)
+}
+
+func (pp *Publish) encodeBody(dst []byte) error {
+	// check qos
+	if !pp.QOS.Valid() {
+		return makeError(pp.Type(), "invalid QOS level (%d)", pp.QOS)
+	}
+
+	// check topic id type
+	if !pp.TopicIDType.Valid() {
+		return makeError(pp.Type(), "invalid topic id type (%d)", pp.TopicIDType)
+	}
+
+	// check qos -1 topic id type
+	if pp.QOS == QOSMinusOne && pp.TopicIDType == NormalTopicID {
+		return makeError(pp.Type(), "QOS level -1 requires a predefined topic id or short topic name")
+	}
+
+	// write fields
+	dst[0] = encodeFlags(pp.Dup, pp.QOS, pp.Retain, false, false, pp.TopicIDType)
+	binary.BigEndian.PutUint16(dst[1:], pp.TopicID)
+	binary.BigEndian.PutUint16(dst[3:], pp.MessageID)
+	copy(dst[5:], pp.Data)
+
+	return nil
+}
+
+func (pp *Publish) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(pp.Type(), src, 5, false)
+	if err != nil {
+		return err
+	}
+
+	// read flags
+	pp.Dup, pp.QOS, pp.Retain, _, _, pp.TopicIDType = decodeFlags(src[0])
+
+	// check topic id type
+	if !pp.TopicIDType.Valid() {
+		return makeError(pp.Type(), "invalid topic id type (%d)", pp.TopicIDType)
+	}
+
+	// read fields
+	pp.TopicID = binary.BigEndian.Uint16(src[1:])
+	pp.MessageID = binary.BigEndian.Uint16(src[3:])
+	pp.Data = append([]byte(nil), src[5:]...)
+
+	return nil
+}
+
+// A Puback packet is sent in response to a Publish packet with QOS level 1
+// or to reject a Publish packet.
+type Puback struct {
+	// The topic id.
+	TopicID uint16
+
+	// The message identifier.
+	MessageID uint16
+
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewPuback creates a new Puback packet.
+func NewPuback() *Puback {
+	return &Puback{}
+}
+
+// Type returns the packets type.
+func (pp *Puback) Type() Type {
+	return PUBACK
+}
+
+// String returns a string representation of the packet.
+func (pp *Puback) String() string {
+	return fmt.Sprintf("<Puback TopicID=%d MessageID=%d ReturnCode=%d>", pp.TopicID, pp.MessageID, pp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Puback) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Puback) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Puback) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Puback) bodyLen() int {
+	return 5
+}
+
+func (pp *Puback) encodeBody(dst []byte) error {
+	// write ids
+	binary.BigEndian.PutUint16(dst, pp.TopicID)
+	binary.BigEndian.PutUint16(dst[2:], pp.MessageID)
+
+	return codedEncode(dst[4:], pp.ReturnCode, pp.Type())
+}
+
+func (pp *Puback) decodeBody(src []byte) (err error) {
+	// check length
+	err = checkLen(pp.Type(), src, 5, true)
+	if err != nil {
+		return err
+	}
+
+	// read ids
+	pp.TopicID = binary.BigEndian.Uint16(src)
+	pp.MessageID = binary.BigEndian.Uint16(src[2:])
+
+	// read return code
+	pp.ReturnCode, err = codedDecode(src[4:], pp.Type())
+
+	return err
+}
@@ -0,0 +1,47 @@
+package mqttsn
+
+import "testing"
+
+func TestPublish(t *testing.T) {
+	pkt := NewPublish()
+	pkt.Dup = true
+	pkt.QOS = QOSAtLeastOnce
+	pkt.Retain = true
+	pkt.TopicID = 1
+	pkt.MessageID = 2
+	pkt.Data = []byte("hi")
+
+	assertCodec(t, pkt, []byte{0x09, byte(PUBLISH), 0xB0, 0x00, 0x01, 0x00, 0x02, 'h', 'i'})
+}
+
+func TestPublishMinusOne(t *testing.T) {
+	id, _ := ShortTopicID("ab")
+
+	pkt := NewPublish()
+	pkt.QOS = QOSMinusOne
+	pkt.TopicIDType = ShortTopicName
+	pkt.TopicID = id
+
+	assertCodec(t, pkt, []byte{0x07, byte(PUBLISH), 0x62, 'a', 'b', 0x00, 0x00})
+}
+
+func TestPublishErrors(t *testing.T) {
+	assertEncodeError(t, &Publish{QOS: 3})
+	assertEncodeError(t, &Publish{TopicIDType: 3})
+	assertEncodeError(t, &Publish{QOS: QOSMinusOne})
+
+	assertDecodeError(t, NewPublish(), []byte{0x06, byte(PUBLISH), 0x00, 0x00, 0x01, 0x00})
+	assertDecodeError(t, NewPublish(), []byte{0x07, byte(PUBLISH), 0x03, 0x00, 0x01, 0x00, 0x00})
+}
+
+func TestPuback(t *testing.T) {
+	pkt := NewPuback()
+	pkt.TopicID = 1
+	pkt.MessageID = 2
+	pkt.ReturnCode = RejectedInvalidTopicID
+
+	assertCodec(t, pkt, []byte{0x07, byte(PUBACK), 0x00, 0x01, 0x00, 0x02, 0x02})
+
+	assertEncodeError(t, &Puback{ReturnCode: 5})
+	assertDecodeError(t, NewPuback(), []byte{0x06, byte(PUBACK), 0x00, 0x01, 0x00, 0x02})
+}
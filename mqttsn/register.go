@@ -0,0 +1,159 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A Register packet is sent by a client to request a topic id for a topic name
+// or by a gateway to inform a client about the topic id it will use.
+type Register struct {
+	// The topic id. It is zero if sent by a client.
+	TopicID uint16
+
+	// The message identifier.
+	MessageID uint16
+
+	// The topic name.
+	TopicName string
+}
+
+// NewRegister creates a new Register packet.
+func NewRegister() *Register {
+	return &Register{}
+}
+
+// Type returns the packets type.
+func (rp *Register) Type() Type {
+	return REGISTER
+}
+
+// String returns a string representation of the packet.
+func (rp *Register) String() string {
+	return fmt.Sprintf("<Register TopicID=%d MessageID=%d TopicName=%q>", rp.TopicID, rp.MessageID, rp.TopicName)
+}
+
+// Len returns the byte length of the encoded packet.
+func (rp *Register) Len() int {
+	return packetLen(rp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (rp *Register) Decode(src []byte) (int, error) {
+	return decode(src, rp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (rp *Register) Encode(dst []byte) (int, error) {
+	return encode(dst, rp)
+}
+
+func (rp *Register) bodyLen() int {
+	return 4 + len(rp.TopicName)
+}
+
+func (rp *Register) encodeBody(dst []byte) error {
+	// check topic name
+	if len(rp.TopicName) == 0 {
+		return makeError(rp.Type(), "missing topic name")
+	}
+
+	// write fields
+	binary.BigEndian.PutUint16(dst, rp.TopicID)
+	binary.BigEndian.PutUint16(dst[2:], rp.MessageID)
+	copy(dst[4:], rp.TopicName)
+
+	return nil
+}
+
+func (rp *Register) decodeBody(src []byte) error {
+	// check length
+	err := checkLen(rp.Type(), src, 5, false)
+	if err != nil {
+		return err
+	}
+
+	// read fields
+	rp.TopicID = binary.BigEndian.Uint16(src)
+	rp.MessageID = binary.BigEndian.Uint16(src[2:])
+	rp.TopicName = string(src[4:])
+
+	return nil
+}
+
+// A Regack packet is sent in response to a Register packet.
+type Regack struct {
+	// The topic id.
+	TopicID uint16
+
+	// The message identifier.
+	MessageID uint16
+
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewRegack creates a new Regack packet.
+func NewRegack() *Regack {
+	return &Regack{}
+}
+
+// Type returns the packets type.
+func (rp *Regack) Type() Type {
+	return REGACK
+}
+
+// String returns a string representation of the packet.
+func (rp *Regack) String() string {
+	return fmt.Sprintf("<Regack TopicID=%d MessageID=%d ReturnCode=%d>", rp.TopicID, rp.MessageID, rp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (rp *Regack) Len() int {
+	return packetLen(rp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (rp *Regack) Decode(src []byte) (int, error) {
+	return decode(src, rp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (rp *Regack) Encode(dst []byte) (int, error) {
+	return encode(dst, rp)
+}
+
+func (rp *Regack) bodyLen() int {
+	return 5
+}
+
+func (rp *Regack) encodeBody(dst []byte) error {
+	// write ids
+	binary.BigEndian.PutUint16(dst, rp.TopicID)
+	binary.BigEndian.PutUint16(dst[2:], rp.MessageID)
+
+	return codedEncode(dst[4:], rp.ReturnCode, rp.Type())
+}
+
+func (rp *Regack) decodeBody(src []byte) (err error) {
+	// check length
+	err = checkLen(rp.Type(), src, 5, true)
+	if err != nil {
+		return err
+	}
+
+	// read ids
+	rp.TopicID = binary.BigEndian.Uint16(src)
+	rp.MessageID = binary.BigEndian.Uint16(src[2:])
+
+	// read return code
+	rp.ReturnCode, err = codedDecode(src[4:], rp.Type())
+
+	return err
+}
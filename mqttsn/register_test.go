@@ -0,0 +1,28 @@
+package mqttsn
+
+import "testing"
+
+func TestRegister(t *testing.T) {
+	pkt := NewRegister()
+	pkt.TopicID = 1
+	pkt.MessageID = 2
+	pkt.TopicName = "foo"
+
+	assertCodec(t, pkt, []byte{0x09, byte(REGISTER), 0x00, 0x01, 0x00, 0x02, 'f', 'o', 'o'})
+
+	assertEncodeError(t, NewRegister())
+	assertDecodeError(t, NewRegister(), []byte{0x06, byte(REGISTER), 0x00, 0x01, 0x00, 0x02})
+}
+
+func TestRegack(t *testing.T) {
+	pkt := NewRegack()
+	pkt.TopicID = 1
+	pkt.MessageID = 2
+	pkt.ReturnCode = RejectedInvalidTopicID
+
+	assertCodec(t, pkt, []byte{0x07, byte(REGACK), 0x00, 0x01, 0x00, 0x02, 0x02})
+
+	assertEncodeError(t, &Regack{ReturnCode: 5})
+	assertDecodeError(t, NewRegack(), []byte{0x07, byte(REGACK), 0x00, 0x01, 0x00, 0x02, 0x05})
+	assertDecodeError(t, NewRegack(), []byte{0x06, byte(REGACK), 0x00, 0x01, 0x00, 0x02})
+}
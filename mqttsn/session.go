@@ -0,0 +1,137 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A Pingreq packet is sent by a client or gateway to check the connection. A
+// sleeping client sends it with its client id to receive buffered messages.
+type Pingreq struct {
+	// The client id of a sleeping client.
+	ClientID string
+}
+
+// NewPingreq creates a new Pingreq packet.
+func NewPingreq() *Pingreq {
+	return &Pingreq{}
+}
+
+// Type returns the packets type.
+func (pp *Pingreq) Type() Type {
+	return PINGREQ
+}
+
+// String returns a string representation of the packet.
+func (pp *Pingreq) String() string {
+	return fmt.Sprintf("<Pingreq ClientID=%q>", pp.ClientID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (pp *Pingreq) Len() int {
+	return packetLen(pp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (pp *Pingreq) Decode(src []byte) (int, error) {
+	return decode(src, pp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (pp *Pingreq) Encode(dst []byte) (int, error) {
+	return encode(dst, pp)
+}
+
+func (pp *Pingreq) bodyLen() int {
+	return len(pp.ClientID)
+}
+
+func (pp *Pingreq) encodeBody(dst []byte) error {
+	copy(dst, pp.ClientID)
+	return nil
+}
+
+func (pp *Pingreq) decodeBody(src []byte) error {
+	pp.ClientID = string(src)
+	return nil
+}
+
+// A Disconnect packet is sent by a client to close the connection or to enter
+// the sleeping state. It is also sent by the gateway to close a connection.
+type Disconnect struct {
+	// The sleep duration in seconds. A zero duration closes the connection.
+	Duration uint16
+}
+
+// NewDisconnect creates a new Disconnect packet.
+func NewDisconnect() *Disconnect {
+	return &Disconnect{}
+}
+
+// Type returns the packets type.
+func (dp *Disconnect) Type() Type {
+	return DISCONNECT
+}
+
+// String returns a string representation of the packet.
+func (dp *Disconnect) String() string {
+	return fmt.Sprintf("<Disconnect Duration=%d>", dp.Duration)
+}
+
+// Len returns the byte length of the encoded packet.
+func (dp *Disconnect) Len() int {
+	return packetLen(dp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (dp *Disconnect) Decode(src []byte) (int, error) {
+	return decode(src, dp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (dp *Disconnect) Encode(dst []byte) (int, error) {
+	return encode(dst, dp)
+}
+
+func (dp *Disconnect) bodyLen() int {
+	// the duration is only present if set
+	if dp.Duration == 0 {
+		return 0
+	}
+
+	return 2
+}
+
+func (dp *Disconnect) encodeBody(dst []byte) error {
+	// write duration if present
+	if dp.Duration > 0 {
+		binary.BigEndian.PutUint16(dst, dp.Duration)
+	}
+
+	return nil
+}
+
+func (dp *Disconnect) decodeBody(src []byte) error {
+	// check empty body
+	if len(src) == 0 {
+		dp.Duration = 0
+		return nil
+	}
+
+	// check length
+	err := checkLen(dp.Type(), src, 2, true)
+	if err != nil {
+		return err
+	}
+
+	// read duration
+	dp.Duration = binary.BigEndian.Uint16(src)
+
+	return nil
+}
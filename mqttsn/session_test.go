@@ -0,0 +1,23 @@
+package mqttsn
+
+import "testing"
+
+func TestPingreq(t *testing.T) {
+	assertCodec(t, NewPingreq(), []byte{0x02, byte(PINGREQ)})
+
+	pkt := NewPingreq()
+	pkt.ClientID = "c1"
+
+	assertCodec(t, pkt, []byte{0x04, byte(PINGREQ), 'c', '1'})
+}
+
+func TestDisconnect(t *testing.T) {
+	assertCodec(t, NewDisconnect(), []byte{0x02, byte(DISCONNECT)})
+
+	pkt := NewDisconnect()
+	pkt.Duration = 60
+
+	assertCodec(t, pkt, []byte{0x04, byte(DISCONNECT), 0x00, 0x3C})
+
+	assertDecodeError(t, NewDisconnect(), []byte{0x03, byte(DISCONNECT), 0x00})
+}
@@ -0,0 +1,339 @@
+package mqttsn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// returns the body length of a topic
+func topicLen(tit TopicIDType, name string) int {
+	// normal topics are sent by name
+	if tit == NormalTopicID {
+		return len(name)
+	}
+
+	return 2
+}
+
+// encodes a topic
+func topicEncode(dst []byte, tit TopicIDType, name string, id uint16, t Type) error {
+	switch tit {
+	case NormalTopicID:
+		// check name
+		if len(name) == 0 {
+			return makeError(t, "missing topic name")
+		}
+
+		// write name
+		copy(dst, name)
+	case PredefinedTopicID:
+		// write id
+		binary.BigEndian.PutUint16(dst, id)
+	case ShortTopicName:
+		// check name
+		if len(name) != 2 {
+			return makeError(t, "short topic name must be 2 bytes long")
+		}
+
+		// write name
+		copy(dst, name)
+	default:
+		return makeError(t, "invalid topic id type (%d)", tit)
+	}
+
+	return nil
+}
+
+// decodes a topic
+func topicDecode(src []byte, tit TopicIDType, t Type) (string, uint16, error) {
+	switch tit {
+	case NormalTopicID:
+		// check name
+		if len(src) == 0 {
+			return "", 0, makeError(t, "missing topic name")
+		}
+
+		return string(src), 0, nil
+	case PredefinedTopicID:
+		// check length
+		err := checkLen(t, src, 2, true)
+		if err != nil {
+			return "", 0, err
+		}
+
+		return "", binary.BigEndian.Uint16(src), nil
+	case ShortTopicName:
+		// check length
+		err := checkLen(t, src, 2, true)
+		if err != nil {
+			return "", 0, err
+		}
+
+		return string(src), 0, nil
+	}
+
+	return "", 0, makeError(t, "invalid topic id type (%d)", tit)
+}
+
+// A Subscribe packet is sent by a client to subscribe to a topic.
+type Subscribe struct {
+	// Whether the packet is sent again.
+	Dup bool
+
+	// The requested QOS level.
+	QOS QOS
+
+	// The type of the topic.
+	TopicIDType TopicIDType
+
+	// The message identifier.
+	MessageID uint16
+
+	// The topic name or short topic name.
+	TopicName string
+
+	// The predefined topic id.
+	TopicID uint16
+}
+
+// NewSubscribe creates a new Subscribe packet.
+func NewSubscribe() *Subscribe {
+	return &Subscribe{}
+}
+
+// Type returns the packets type.
+func (sp *Subscribe) Type() Type {
+	return SUBSCRIBE
+}
+
+// String returns a string representation of the packet.
+func (sp *Subscribe) String() string {
+	return fmt.Sprintf("<Subscribe MessageID=%d TopicIDType=%d TopicName=%q TopicID=%d QOS=%d Dup=%t>",
+		sp.MessageID, sp.TopicIDType, sp.TopicName, sp.TopicID, sp.QOS, sp.Dup)
+}
+
+// Len returns the byte length of the encoded packet.
+func (sp *Subscribe) Len() int {
+	return packetLen(sp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (sp *Subscribe) Decode(src []byte) (int, error) {
+	return decode(src, sp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (sp *Subscribe) Encode(dst []byte) (int, error) {
+	return encode(dst, sp)
+}
+
+func (sp *Subscribe) bodyLen() int {
+	return 3 + topicLen(sp.TopicIDType, sp.TopicName)
+}
+
+func (sp *Subscribe) encodeBody(dst []byte) error {
+	// check qos
+	if sp.QOS < QOSAtMostOnce || sp.QOS > QOSExactlyOnce {
+		return makeError(sp.Type(), "invalid QOS level (%d)", sp.QOS)
+	}
+
+	// write fields
+	dst[0] = encodeFlags(sp.Dup, sp.QOS, false, false, false, sp.TopicIDType)
+	binary.BigEndian.PutUint16(dst[1:], sp.MessageID)
+
+	return topicEncode(dst[3:], sp.TopicIDType, sp.TopicName, sp.TopicID, sp.Type())
+}
+
+func (sp *Subscribe) decodeBody(src []byte) (err error) {
+	// check length
+	err = checkLen(sp.Type(), src, 4, false)
+	if err != nil {
+		return err
+	}
+
+	// read flags
+	sp.Dup, sp.QOS, _, _, _, sp.TopicIDType = decodeFlags(src[0])
+
+	// check qos
+	if sp.QOS < QOSAtMostOnce {
+		return makeError(sp.Type(), "invalid QOS level (%d)", sp.QOS)
+	}
+
+	// read message id
+	sp.MessageID = binary.BigEndian.Uint16(src[1:])
+
+	// read topic
+	sp.TopicName, sp.TopicID, err = topicDecode(src[3:], sp.TopicIDType, sp.Type())
+
+	return err
+}
+
+// A Suback packet is sent by the gateway in response to a Subscribe packet.
+type Suback struct {
+	// The granted QOS level.
+	QOS QOS
+
+	// The topic id. It is zero for wildcard subscriptions.
+	TopicID uint16
+
+	// The message identifier.
+	MessageID uint16
+
+	// The return code.
+	ReturnCode ReturnCode
+}
+
+// NewSuback creates a new Suback packet.
+func NewSuback() *Suback {
+	return &Suback{}
+}
+
+// Type returns the packets type.
+func (sp *Suback) Type() Type {
+	return SUBACK
+}
+
+// String returns a string representation of the packet.
+func (sp *Suback) String() string {
+	return fmt.Sprintf("<Suback TopicID=%d MessageID=%d QOS=%d ReturnCode=%d>",
+		sp.TopicID, sp.MessageID, sp.QOS, sp.ReturnCode)
+}
+
+// Len returns the byte length of the encoded packet.
+func (sp *Suback) Len() int {
+	return packetLen(sp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (sp *Suback) Decode(src []byte) (int, error) {
+	return decode(src, sp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (sp *Suback) Encode(dst []byte) (int, error) {
+	return encode(dst, sp)
+}
+
+func (sp *Suback) bodyLen() int {
+	return 6
+}
+
+func (sp *Suback) encodeBody(dst []byte) error {
+	// check qos
+	if sp.QOS < QOSAtMostOnce || sp.QOS > QOSExactlyOnce {
+		return makeError(sp.Type(), "invalid QOS level (%d)", sp.QOS)
+	}
+
+	// write fields
+	dst[0] = encodeFlags(false, sp.QOS, false, false, false, 0)
+	binary.BigEndian.PutUint16(dst[1:], sp.TopicID)
+	binary.BigEndian.PutUint16(dst[3:], sp.MessageID)
+
+	return codedEncode(dst[5:], sp.ReturnCode, sp.Type())
+}
+
+func (sp *Suback) decodeBody(src []byte) (err error) {
+	// check length
+	err = checkLen(sp.Type(), src, 6, true)
+	if err != nil {
+		return err
+	}
+
+	// read flags
+	_, sp.QOS, _, _, _, _ = decodeFlags(src[0])
+
+	// read ids
+	sp.TopicID = binary.BigEndian.Uint16(src[1:])
+	sp.MessageID = binary.BigEndian.Uint16(src[3:])
+
+	// read return code
+	sp.ReturnCode, err = codedDecode(src[5:], sp.Type())
+
+	return err
+}
+
+// An Unsubscribe packet is sent by a client to unsubscribe from a topic.
+type Unsubscribe struct {
+	// The type of the topic.
+	TopicIDType TopicIDType
+
+	// The message identifier.
+	MessageID uint16
+
+	// The topic name or short topic name.
+	TopicName string
+
+	// The predefined topic id.
+	TopicID uint16
+}
+
+// NewUnsubscribe creates a new Unsubscribe packet.
+func NewUnsubscribe() *Unsubscribe {
+	return &Unsubscribe{}
+}
+
+// Type returns the packets type.
+func (up *Unsubscribe) Type() Type {
+	return UNSUBSCRIBE
+}
+
+// String returns a string representation of the packet.
+func (up *Unsubscribe) String() string {
+	return fmt.Sprintf("<Unsubscribe MessageID=%d TopicIDType=%d TopicName=%q TopicID=%d>",
+		up.MessageID, up.TopicIDType, up.TopicName, up.TopicID)
+}
+
+// Len returns the byte length of the encoded packet.
+func (up *Unsubscribe) Len() int {
+	return packetLen(up.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (up *Unsubscribe) Decode(src []byte) (int, error) {
+	return decode(src, up)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (up *Unsubscribe) Encode(dst []byte) (int, error) {
+	return encode(dst, up)
+}
+
+func (up *Unsubscribe) bodyLen() int {
+	return 3 + topicLen(up.TopicIDType, up.TopicName)
+}
+
+func (up *Unsubscribe) encodeBody(dst []byte) error {
+	// write fields
+	dst[0] = encodeFlags(false, 0, false, false, false, up.TopicIDType)
+	binary.BigEndian.PutUint16(dst[1:], up.MessageID)
+
+	return topicEncode(dst[3:], up.TopicIDType, up.TopicName, up.TopicID, up.Type())
+}
+
+func (up *Unsubscribe) decodeBody(src []byte) (err error) {
+	// check length
+	err = checkLen(up.Type(), src, 4, false)
+	if err != nil {
+		return err
+	}
+
+	// read flags
+	_, _, _, _, _, up.TopicIDType = decodeFlags(src[0])
+
+	// read message id
+	up.MessageID = binary.BigEndian.Uint16(src[1:])
+
+	// read topic
+	up.TopicName, up.TopicID, err = topicDecode(src[3:], up.TopicIDType, up.Type())
+
+	return err
+}
@@ -0,0 +1,69 @@
+package mqttsn
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	pkt := NewSubscribe()
+	pkt.QOS = QOSAtLeastOnce
+	pkt.MessageID = 1
+	pkt.TopicName = "a/#"
+
+	assertCodec(t, pkt, []byte{0x08, byte(SUBSCRIBE), 0x20, 0x00, 0x01, 'a', '/', '#'})
+
+	pkt = NewSubscribe()
+	pkt.TopicIDType = PredefinedTopicID
+	pkt.MessageID = 1
+	pkt.TopicID = 5
+
+	assertCodec(t, pkt, []byte{0x07, byte(SUBSCRIBE), 0x01, 0x00, 0x01, 0x00, 0x05})
+
+	pkt = NewSubscribe()
+	pkt.TopicIDType = ShortTopicName
+	pkt.MessageID = 1
+	pkt.TopicName = "ab"
+
+	assertCodec(t, pkt, []byte{0x07, byte(SUBSCRIBE), 0x02, 0x00, 0x01, 'a', 'b'})
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	assertEncodeError(t, &Subscribe{QOS: QOSMinusOne, TopicName: "a"})
+	assertEncodeError(t, &Subscribe{TopicIDType: ShortTopicName, TopicName: "abc"})
+	assertEncodeError(t, &Subscribe{TopicIDType: 3, TopicName: "a"})
+	assertEncodeError(t, &Subscribe{})
+
+	assertDecodeError(t, NewSubscribe(), []byte{0x05, byte(SUBSCRIBE), 0x00, 0x00, 0x01})
+	assertDecodeError(t, NewSubscribe(), []byte{0x06, byte(SUBSCRIBE), 0x60, 0x00, 0x01, 'a'})
+	assertDecodeError(t, NewSubscribe(), []byte{0x06, byte(SUBSCRIBE), 0x03, 0x00, 0x01, 'a'})
+	assertDecodeError(t, NewSubscribe(), []byte{0x06, byte(SUBSCRIBE), 0x01, 0x00, 0x01, 'a'})
+	assertDecodeError(t, NewSubscribe(), []byte{0x08, byte(SUBSCRIBE), 0x02, 0x00, 0x01, 'a', 'b', 'c'})
+}
+
+func TestSuback(t *testing.T) {
+	pkt := NewSuback()
+	pkt.QOS = QOSExactlyOnce
+	pkt.TopicID = 1
+	pkt.MessageID = 2
+
+	assertCodec(t, pkt, []byte{0x08, byte(SUBACK), 0x40, 0x00, 0x01, 0x00, 0x02, 0x00})
+
+	assertEncodeError(t, &Suback{QOS: QOSMinusOne})
+	assertDecodeError(t, NewSuback(), []byte{0x07, byte(SUBACK), 0x40, 0x00, 0x01, 0x00, 0x02})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	pkt := NewUnsubscribe()
+	pkt.MessageID = 1
+	pkt.TopicName = "a/#"
+
+	assertCodec(t, pkt, []byte{0x08, byte(UNSUBSCRIBE), 0x00, 0x00, 0x01, 'a', '/', '#'})
+
+	pkt = NewUnsubscribe()
+	pkt.TopicIDType = PredefinedTopicID
+	pkt.MessageID = 1
+	pkt.TopicID = 5
+
+	assertCodec(t, pkt, []byte{0x07, byte(UNSUBSCRIBE), 0x01, 0x00, 0x01, 0x00, 0x05})
+
+	assertEncodeError(t, &Unsubscribe{})
+	assertDecodeError(t, NewUnsubscribe(), []byte{0x05, byte(UNSUBSCRIBE), 0x00, 0x00, 0x01})
+}
@@ -0,0 +1,180 @@
+package mqttsn
+
+import "errors"
+
+// ErrInvalidPacketType is returned by New if the packet type is invalid.
+var ErrInvalidPacketType = errors.New("invalid packet type")
+
+// Type represents the MQTT-SN packet types.
+type Type byte
+
+// All packet types.
+const (
+	ADVERTISE     Type = 0x00
+	SEARCHGW      Type = 0x01
+	GWINFO        Type = 0x02
+	CONNECT       Type = 0x04
+	CONNACK       Type = 0x05
+	WILLTOPICREQ  Type = 0x06
+	WILLTOPIC     Type = 0x07
+	WILLMSGREQ    Type = 0x08
+	WILLMSG       Type = 0x09
+	REGISTER      Type = 0x0A
+	REGACK        Type = 0x0B
+	PUBLISH       Type = 0x0C
+	PUBACK        Type = 0x0D
+	PUBCOMP       Type = 0x0E
+	PUBREC        Type = 0x0F
+	PUBREL        Type = 0x10
+	SUBSCRIBE     Type = 0x12
+	SUBACK        Type = 0x13
+	UNSUBSCRIBE   Type = 0x14
+	UNSUBACK      Type = 0x15
+	PINGREQ       Type = 0x16
+	PINGRESP      Type = 0x17
+	DISCONNECT    Type = 0x18
+	WILLTOPICUPD  Type = 0x1A
+	WILLTOPICRESP Type = 0x1B
+	WILLMSGUPD    Type = 0x1C
+	WILLMSGRESP   Type = 0x1D
+)
+
+// Types returns a list of all known packet types.
+func Types() []Type {
+	return []Type{ADVERTISE, SEARCHGW, GWINFO, CONNECT, CONNACK, WILLTOPICREQ,
+		WILLTOPIC, WILLMSGREQ, WILLMSG, REGISTER, REGACK, PUBLISH, PUBACK,
+		PUBCOMP, PUBREC, PUBREL, SUBSCRIBE, SUBACK, UNSUBSCRIBE, UNSUBACK,
+		PINGREQ, PINGRESP, DISCONNECT, WILLTOPICUPD, WILLTOPICRESP, WILLMSGUPD,
+		WILLMSGRESP}
+}
+
+// String returns the type as a string.
+func (t Type) String() string {
+	switch t {
+	case ADVERTISE:
+		return "Advertise"
+	case SEARCHGW:
+		return "Searchgw"
+	case GWINFO:
+		return "Gwinfo"
+	case CONNECT:
+		return "Connect"
+	case CONNACK:
+		return "Connack"
+	case WILLTOPICREQ:
+		return "Willtopicreq"
+	case WILLTOPIC:
+		return "Willtopic"
+	case WILLMSGREQ:
+		return "Willmsgreq"
+	case WILLMSG:
+		return "Willmsg"
+	case REGISTER:
+		return "Register"
+	case REGACK:
+		return "Regack"
+	case PUBLISH:
+		return "Publish"
+	case PUBACK:
+		return "Puback"
+	case PUBCOMP:
+		return "Pubcomp"
+	case PUBREC:
+		return "Pubrec"
+	case PUBREL:
+		return "Pubrel"
+	case SUBSCRIBE:
+		return "Subscribe"
+	case SUBACK:
+		return "Suback"
+	case UNSUBSCRIBE:
+		return "Unsubscribe"
+	case UNSUBACK:
+		return "Unsuback"
+	case PINGREQ:
+		return "Pingreq"
+	case PINGRESP:
+		return "Pingresp"
+	case DISCONNECT:
+		return "Disconnect"
+	case WILLTOPICUPD:
+		return "Willtopicupd"
+	case WILLTOPICRESP:
+		return "Willtopicresp"
+	case WILLMSGUPD:
+		return "Willmsgupd"
+	case WILLMSGRESP:
+		return "Willmsgresp"
+	}
+
+	return "Unknown"
+}
+
+// New creates a new packet based on the type. It is a shortcut to call one of
+// the New* functions. An error is returned if the type is invalid.
+func (t Type) New() (Generic, error) {
+	switch t {
+	case ADVERTISE:
+		return NewAdvertise(), nil
+	case SEARCHGW:
+		return NewSearchgw(), nil
+	case GWINFO:
+		return NewGwinfo(), nil
+	case CONNECT:
+		return NewConnect(), nil
+	case CONNACK:
+		return NewConnack(), nil
+	case WILLTOPICREQ:
+		return NewWilltopicreq(), nil
+	case WILLTOPIC:
+		return NewWilltopic(), nil
+	case WILLMSGREQ:
+		return NewWillmsgreq(), nil
+	case WILLMSG:
+		return NewWillmsg(), nil
+	case REGISTER:
+		return NewRegister(), nil
+	case REGACK:
+		return NewRegack(), nil
+	case PUBLISH:
+		return NewPublish(), nil
+	case PUBACK:
+		return NewPuback(), nil
+	case PUBCOMP:
+		return NewPubcomp(), nil
+	case PUBREC:
+		return NewPubrec(), nil
+	case PUBREL:
+		return NewPubrel(), nil
+	case SUBSCRIBE:
+		return NewSubscribe(), nil
+	case SUBACK:
+		return NewSuback(), nil
+	case UNSUBSCRIBE:
+		return NewUnsubscribe(), nil
+	case UNSUBACK:
+		return NewUnsuback(), nil
+	case PINGREQ:
+		return NewPingreq(), nil
+	case PINGRESP:
+		return NewPingresp(), nil
+	case DISCONNECT:
+		return NewDisconnect(), nil
+	case WILLTOPICUPD:
+		return NewWilltopicupd(), nil
+	case WILLTOPICRESP:
+		return NewWilltopicresp(), nil
+	case WILLMSGUPD:
+		return NewWillmsgupd(), nil
+	case WILLMSGRESP:
+		return NewWillmsgresp(), nil
+	}
+
+	return nil, ErrInvalidPacketType
+}
+
+// Valid returns a boolean indicating whether the type is valid or not.
+func (t Type) Valid() bool {
+	_, err := t.New()
+	return err == nil
+}
@@ -0,0 +1,33 @@
+package mqttsn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypes(t *testing.T) {
+	assert.Len(t, Types(), 27)
+}
+
+func TestTypeString(t *testing.T) {
+	assert.Equal(t, "Unknown", Type(0x03).String())
+}
+
+func TestTypeValid(t *testing.T) {
+	assert.True(t, CONNECT.Valid())
+	assert.False(t, Type(0x11).Valid())
+}
+
+func TestTypeNew(t *testing.T) {
+	for _, tt := range Types() {
+		m, err := tt.New()
+		assert.NotNil(t, m)
+		assert.NoError(t, err)
+		assert.Equal(t, tt, m.Type())
+		assert.NotEqual(t, "Unknown", tt.String())
+	}
+
+	_, err := Type(0xFF).New()
+	assert.Equal(t, ErrInvalidPacketType, err)
+}
@@ -0,0 +1,51 @@
+package mqttsn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertCodec(t *testing.T, pkt Generic, expected []byte) {
+	// check length
+	assert.Equal(t, len(expected), pkt.Len())
+
+	// check encoding
+	dst := make([]byte, pkt.Len())
+	n, err := pkt.Encode(dst)
+	require.NoError(t, err)
+	assert.Equal(t, len(expected), n)
+	assert.Equal(t, expected, dst)
+
+	// check insufficient buffer
+	_, err = pkt.Encode(make([]byte, pkt.Len()-1))
+	assert.Error(t, err)
+
+	// check decoding
+	pkt2, err := pkt.Type().New()
+	require.NoError(t, err)
+	n, err = pkt2.Decode(expected)
+	require.NoError(t, err)
+	assert.Equal(t, len(expected), n)
+	assert.Equal(t, pkt, pkt2)
+
+	// check truncated decoding
+	_, err = pkt2.Decode(expected[:len(expected)-1])
+	assert.Error(t, err)
+
+	// check string
+	assert.Contains(t, pkt.String(), "<"+pkt.Type().String())
+}
+
+func assertDecodeError(t *testing.T, pkt Generic, src []byte) {
+	_, err := pkt.Decode(src)
+	assert.Error(t, err)
+	assert.IsType(t, &Error{}, err)
+}
+
+func assertEncodeError(t *testing.T, pkt Generic) {
+	_, err := pkt.Encode(make([]byte, pkt.Len()))
+	assert.Error(t, err)
+	assert.IsType(t, &Error{}, err)
+}
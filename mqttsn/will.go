@@ -0,0 +1,278 @@
+package mqttsn
+
+import "fmt"
+
+// returns the body length of a will topic packet
+func willTopicLen(topic string) int {
+	// an empty body deletes the will
+	if topic == "" {
+		return 0
+	}
+
+	return 1 + len(topic)
+}
+
+// encodes the body of a will topic packet
+func willTopicEncode(dst []byte, qos QOS, retain bool, topic string, t Type) error {
+	// check topic
+	if topic == "" {
+		return nil
+	}
+
+	// check qos
+	if qos < QOSAtMostOnce || qos > QOSExactlyOnce {
+		return makeError(t, "invalid QOS level (%d)", qos)
+	}
+
+	// write fields
+	dst[0] = encodeFlags(false, qos, retain, false, false, 0)
+	copy(dst[1:], topic)
+
+	return nil
+}
+
+// decodes the body of a will topic packet
+func willTopicDecode(src []byte, t Type) (QOS, bool, string, error) {
+	// an empty body deletes the will
+	if len(src) == 0 {
+		return 0, false, "", nil
+	}
+
+	// check length
+	err := checkLen(t, src, 2, false)
+	if err != nil {
+		return 0, false, "", err
+	}
+
+	// read flags
+	_, qos, retain, _, _, _ := decodeFlags(src[0])
+
+	// check qos
+	if qos < QOSAtMostOnce {
+		return 0, false, "", makeError(t, "invalid QOS level (%d)", qos)
+	}
+
+	return qos, retain, string(src[1:]), nil
+}
+
+// A Willtopic packet is sent by a client in response to a Willtopicreq packet.
+type Willtopic struct {
+	// The QOS level of the will message.
+	QOS QOS
+
+	// Whether the will message should be retained.
+	Retain bool
+
+	// The will topic. An empty topic deletes the will.
+	Topic string
+}
+
+// NewWilltopic creates a new Willtopic packet.
+func NewWilltopic() *Willtopic {
+	return &Willtopic{}
+}
+
+// Type returns the packets type.
+func (wp *Willtopic) Type() Type {
+	return WILLTOPIC
+}
+
+// String returns a string representation of the packet.
+func (wp *Willtopic) String() string {
+	return fmt.Sprintf("<Willtopic Topic=%q QOS=%d Retain=%t>", wp.Topic, wp.QOS, wp.Retain)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willtopic) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willtopic) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willtopic) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willtopic) bodyLen() int {
+	return willTopicLen(wp.Topic)
+}
+
+func (wp *Willtopic) encodeBody(dst []byte) error {
+	return willTopicEncode(dst, wp.QOS, wp.Retain, wp.Topic, wp.Type())
+}
+
+func (wp *Willtopic) decodeBody(src []byte) (err error) {
+	wp.QOS, wp.Retain, wp.Topic, err = willTopicDecode(src, wp.Type())
+	return err
+}
+
+// A Willmsg packet is sent by a client in response to a Willmsgreq packet.
+type Willmsg struct {
+	// The will message.
+	Message []byte
+}
+
+// NewWillmsg creates a new Willmsg packet.
+func NewWillmsg() *Willmsg {
+	return &Willmsg{}
+}
+
+// Type returns the packets type.
+func (wp *Willmsg) Type() Type {
+	return WILLMSG
+}
+
+// String returns a string representation of the packet.
+func (wp *Willmsg) String() string {
+	return fmt.Sprintf("<Willmsg Message=%x>", wp.Message)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willmsg) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willmsg) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willmsg) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willmsg) bodyLen() int {
+	return len(wp.Message)
+}
+
+func (wp *Willmsg) encodeBody(dst []byte) error {
+	copy(dst, wp.Message)
+	return nil
+}
+
+func (wp *Willmsg) decodeBody(src []byte) error {
+	wp.Message = append([]byte(nil), src...)
+	return nil
+}
+
+// A Willtopicupd packet is sent by a client to update its will topic.
+type Willtopicupd struct {
+	// The QOS level of the will message.
+	QOS QOS
+
+	// Whether the will message should be retained.
+	Retain bool
+
+	// The will topic. An empty topic deletes the will.
+	Topic string
+}
+
+// NewWilltopicupd creates a new Willtopicupd packet.
+func NewWilltopicupd() *Willtopicupd {
+	return &Willtopicupd{}
+}
+
+// Type returns the packets type.
+func (wp *Willtopicupd) Type() Type {
+	return WILLTOPICUPD
+}
+
+// String returns a string representation of the packet.
+func (wp *Willtopicupd) String() string {
+	return fmt.Sprintf("<Willtopicupd Topic=%q QOS=%d Retain=%t>", wp.Topic, wp.QOS, wp.Retain)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willtopicupd) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willtopicupd) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willtopicupd) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willtopicupd) bodyLen() int {
+	return willTopicLen(wp.Topic)
+}
+
+func (wp *Willtopicupd) encodeBody(dst []byte) error {
+	return willTopicEncode(dst, wp.QOS, wp.Retain, wp.Topic, wp.Type())
+}
+
+func (wp *Willtopicupd) decodeBody(src []byte) (err error) {
+	wp.QOS, wp.Retain, wp.Topic, err = willTopicDecode(src, wp.Type())
+	return err
+}
+
+// A Willmsgupd packet is sent by a client to update its will message.
+type Willmsgupd struct {
+	// The will message.
+	Message []byte
+}
+
+// NewWillmsgupd creates a new Willmsgupd packet.
+func NewWillmsgupd() *Willmsgupd {
+	return &Willmsgupd{}
+}
+
+// Type returns the packets type.
+func (wp *Willmsgupd) Type() Type {
+	return WILLMSGUPD
+}
+
+// String returns a string representation of the packet.
+func (wp *Willmsgupd) String() string {
+	return fmt.Sprintf("<Willmsgupd Message=%x>", wp.Message)
+}
+
+// Len returns the byte length of the encoded packet.
+func (wp *Willmsgupd) Len() int {
+	return packetLen(wp.bodyLen())
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+func (wp *Willmsgupd) Decode(src []byte) (int, error) {
+	return decode(src, wp)
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. If there is an error, the byte slice should be considered invalid.
+func (wp *Willmsgupd) Encode(dst []byte) (int, error) {
+	return encode(dst, wp)
+}
+
+func (wp *Willmsgupd) bodyLen() int {
+	return len(wp.Message)
+}
+
+func (wp *Willmsgupd) encodeBody(dst []byte) error {
+	copy(dst, wp.Message)
+	return nil
+}
+
+func (wp *Willmsgupd) decodeBody(src []byte) error {
+	wp.Message = append([]byte(nil), src...)
+	return nil
+}
@@ -0,0 +1,42 @@
+package mqttsn
+
+import "testing"
+
+func TestWilltopic(t *testing.T) {
+	pkt := NewWilltopic()
+	pkt.QOS = QOSAtLeastOnce
+	pkt.Retain = true
+	pkt.Topic = "w"
+
+	assertCodec(t, pkt, []byte{0x04, byte(WILLTOPIC), 0x30, 'w'})
+
+	assertCodec(t, NewWilltopic(), []byte{0x02, byte(WILLTOPIC)})
+
+	assertEncodeError(t, &Willtopic{QOS: QOSMinusOne, Topic: "w"})
+	assertDecodeError(t, NewWilltopic(), []byte{0x04, byte(WILLTOPIC), 0x60, 'w'})
+	assertDecodeError(t, NewWilltopic(), []byte{0x03, byte(WILLTOPIC), 0x00})
+}
+
+func TestWillmsg(t *testing.T) {
+	pkt := NewWillmsg()
+	pkt.Message = []byte("bye")
+
+	assertCodec(t, pkt, []byte{0x05, byte(WILLMSG), 'b', 'y', 'e'})
+}
+
+func TestWilltopicupd(t *testing.T) {
+	pkt := NewWilltopicupd()
+	pkt.QOS = QOSExactlyOnce
+	pkt.Topic = "w"
+
+	assertCodec(t, pkt, []byte{0x04, byte(WILLTOPICUPD), 0x40, 'w'})
+
+	assertCodec(t, NewWilltopicupd(), []byte{0x02, byte(WILLTOPICUPD)})
+}
+
+func TestWillmsgupd(t *testing.T) {
+	pkt := NewWillmsgupd()
+	pkt.Message = []byte("bye")
+
+	assertCodec(t, pkt, []byte{0x05, byte(WILLMSGUPD), 'b', 'y', 'e'})
+}
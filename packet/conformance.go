@@ -0,0 +1,184 @@
+package packet
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strictness defines how strictly a Decoder enforces the specification.
+type Strictness int
+
+const (
+	// Lenient will only reject packets that cannot be decoded. This is the
+	// default.
+	Lenient Strictness = iota
+
+	// Strict will additionally reject packets that violate a normative
+	// statement of the MQTT 3.1.1 specification that requires the receiver to
+	// close the connection.
+	Strict
+)
+
+// A ConformanceError is returned by a strict Decoder and Validate if a packet
+// violates a normative statement of the specification.
+type ConformanceError struct {
+	// The type of the offending packet.
+	Type Type
+
+	// The identifier of the violated normative statement e.g. "MQTT-1.5.3-1".
+	Statement string
+
+	// The description of the violation.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ConformanceError) Error() string {
+	return fmt.Sprintf("%s violates %s: %s", e.Type, e.Statement, e.Reason)
+}
+
+func violation(typ Type, statement, reason string) *ConformanceError {
+	return &ConformanceError{
+		Type:      typ,
+		Statement: statement,
+		Reason:    reason,
+	}
+}
+
+// Validate will check the packet for violations of the normative statements of
+// the specification that are not already checked when decoding. It will return
+// a ConformanceError for the first violation found.
+func Validate(pkt Generic) error {
+	switch p := pkt.(type) {
+	case *Connect:
+		// check strings
+		for _, str := range []string{p.ClientID, p.Username} {
+			if err := validateString(p.Type(), str); err != nil {
+				return err
+			}
+		}
+
+		// check will topic
+		if p.Will != nil {
+			if err := validateTopic(p.Type(), p.Will.Topic); err != nil {
+				return err
+			}
+		}
+	case *Publish:
+		// check topic
+		if err := validateTopic(p.Type(), p.Message.Topic); err != nil {
+			return err
+		}
+
+		// check dup flag
+		if p.Dup && p.Message.QOS == 0 {
+			return violation(p.Type(), "MQTT-3.3.1-2", "dup flag must be zero for qos 0 messages")
+		}
+	case *PublishStream:
+		// check header
+		return Validate(&p.Header)
+	case *Subscribe:
+		// check subscriptions
+		if len(p.Subscriptions) == 0 {
+			return violation(p.Type(), "MQTT-3.8.3-3", "at least one subscription is required")
+		}
+
+		// check filters
+		for _, sub := range p.Subscriptions {
+			if err := validateFilter(p.Type(), sub.Topic); err != nil {
+				return err
+			}
+		}
+	case *Unsubscribe:
+		// check topics
+		if len(p.Topics) == 0 {
+			return violation(p.Type(), "MQTT-3.10.3-2", "at least one topic filter is required")
+		}
+
+		// check filters
+		for _, topic := range p.Topics {
+			if err := validateFilter(p.Type(), topic); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validates the fixed header flags of an encoded packet
+func validateFlags(typ Type, flags byte) error {
+	// publish flags are checked while decoding
+	if typ == PUBLISH {
+		return nil
+	}
+
+	// check flags
+	if flags != typ.defaultFlags() {
+		return violation(typ, "MQTT-2.2.2-2", fmt.Sprintf("reserved flags must be %d, got %d", typ.defaultFlags(), flags))
+	}
+
+	return nil
+}
+
+func validateString(typ Type, str string) error {
+	// check encoding
+	if !utf8.ValidString(str) {
+		return violation(typ, "MQTT-1.5.3-1", "string is not valid UTF-8")
+	}
+
+	// check null character
+	if strings.IndexByte(str, 0) >= 0 {
+		return violation(typ, "MQTT-1.5.3-2", "string contains the null character")
+	}
+
+	return nil
+}
+
+func validateTopic(typ Type, topic string) error {
+	// check string
+	if err := validateString(typ, topic); err != nil {
+		return err
+	}
+
+	// check length
+	if topic == "" {
+		return violation(typ, "MQTT-4.7.3-1", "topic name must not be empty")
+	}
+
+	// check wildcards
+	if strings.ContainsAny(topic, "+#") {
+		return violation(typ, "MQTT-3.3.2-2", "topic name must not contain wildcards")
+	}
+
+	return nil
+}
+
+func validateFilter(typ Type, filter string) error {
+	// check string
+	if err := validateString(typ, filter); err != nil {
+		return err
+	}
+
+	// check length
+	if filter == "" {
+		return violation(typ, "MQTT-4.7.3-1", "topic filter must not be empty")
+	}
+
+	// check levels
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		// check multi level wildcard
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return violation(typ, "MQTT-4.7.1-2", "multi level wildcard must be the last level")
+		}
+
+		// check single level wildcard
+		if strings.Contains(level, "+") && level != "+" {
+			return violation(typ, "MQTT-4.7.1-3", "single level wildcard must occupy an entire level")
+		}
+	}
+
+	return nil
+}
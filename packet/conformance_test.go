@@ -0,0 +1,87 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	publish := func(topic string, qos QOS, dup bool) *Publish {
+		pkt := NewPublish()
+		pkt.Message.Topic = topic
+		pkt.Message.QOS = qos
+		pkt.Dup = dup
+		return pkt
+	}
+
+	subscribe := func(filters ...string) *Subscribe {
+		pkt := NewSubscribe()
+		for _, filter := range filters {
+			pkt.Subscriptions = append(pkt.Subscriptions, Subscription{Topic: filter})
+		}
+		return pkt
+	}
+
+	unsubscribe := func(filters ...string) *Unsubscribe {
+		pkt := NewUnsubscribe()
+		pkt.Topics = filters
+		return pkt
+	}
+
+	connect := func(id, username, willTopic string) *Connect {
+		pkt := NewConnect()
+		pkt.ClientID = id
+		pkt.Username = username
+		if willTopic != "" {
+			pkt.Will = &Message{Topic: willTopic}
+		}
+		return pkt
+	}
+
+	table := []struct {
+		pkt       Generic
+		statement string
+	}{
+		{NewPingreq(), ""},
+		{connect("foo", "bar", "baz"), ""},
+		{connect("foo\xff", "", ""), "MQTT-1.5.3-1"},
+		{connect("foo", "b\x00r", ""), "MQTT-1.5.3-2"},
+		{connect("foo", "", "baz/#"), "MQTT-3.3.2-2"},
+		{publish("foo/bar", 1, true), ""},
+		{publish("foo/\xc3\x28", 0, false), "MQTT-1.5.3-1"},
+		{publish("foo\x00bar", 0, false), "MQTT-1.5.3-2"},
+		{publish("", 0, false), "MQTT-4.7.3-1"},
+		{publish("foo/+", 0, false), "MQTT-3.3.2-2"},
+		{publish("foo/#", 0, false), "MQTT-3.3.2-2"},
+		{publish("foo", 0, true), "MQTT-3.3.1-2"},
+		{subscribe("foo/+/bar", "#", "/+", "foo/#"), ""},
+		{subscribe(), "MQTT-3.8.3-3"},
+		{subscribe(""), "MQTT-4.7.3-1"},
+		{subscribe("foo/#/bar"), "MQTT-4.7.1-2"},
+		{subscribe("foo#"), "MQTT-4.7.1-2"},
+		{subscribe("foo+"), "MQTT-4.7.1-3"},
+		{subscribe("foo/\xff"), "MQTT-1.5.3-1"},
+		{unsubscribe("foo/+"), ""},
+		{unsubscribe(), "MQTT-3.10.3-2"},
+		{unsubscribe("foo/+bar"), "MQTT-4.7.1-3"},
+	}
+
+	for _, item := range table {
+		err := Validate(item.pkt)
+		if item.statement == "" {
+			assert.NoError(t, err, item.pkt.String())
+			continue
+		}
+
+		if assert.IsType(t, &ConformanceError{}, err, item.pkt.String()) {
+			assert.Equal(t, item.statement, err.(*ConformanceError).Statement)
+			assert.Equal(t, item.pkt.Type(), err.(*ConformanceError).Type)
+		}
+	}
+}
+
+func TestConformanceError(t *testing.T) {
+	err := violation(PUBLISH, "MQTT-3.3.2-2", "topic name must not contain wildcards")
+	assert.Equal(t, "Publish violates MQTT-3.3.2-2: topic name must not contain wildcards", err.Error())
+}
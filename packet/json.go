@@ -0,0 +1,619 @@
+package packet
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PayloadEncoding defines how binary payloads are represented in JSON.
+type PayloadEncoding string
+
+// The available payload encodings.
+const (
+	// Base64Encoding represents payloads as standard base64 strings.
+	Base64Encoding PayloadEncoding = "base64"
+
+	// HexEncoding represents payloads as hex strings.
+	HexEncoding PayloadEncoding = "hex"
+
+	// TextEncoding represents payloads as plain strings. Payloads that are not
+	// valid UTF-8 are represented using base64 instead.
+	TextEncoding PayloadEncoding = "text"
+)
+
+// ToJSON will return the JSON representation of the packet. Payloads of
+// messages are encoded using the specified encoding, which is stored alongside
+// the payload and used by FromJSON. The MarshalJSON methods of the packets use
+// Base64Encoding.
+func ToJSON(pkt Generic, encoding PayloadEncoding) ([]byte, error) {
+	// check packet
+	switch pkt := pkt.(type) {
+	case *Connect:
+		return pkt.marshalJSON(encoding)
+	case *Publish:
+		return pkt.marshalJSON(encoding)
+	}
+
+	return json.Marshal(pkt)
+}
+
+// FromJSON will create a packet from its JSON representation. The packet type
+// is read from the "type" field which must contain a type name e.g. "Publish".
+func FromJSON(data []byte) (Generic, error) {
+	// read type
+	var header struct {
+		Type string `json:"type"`
+	}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse type
+	typ, ok := typeFromString(header.Type)
+	if !ok {
+		return nil, ErrInvalidPacketType
+	}
+
+	// create packet
+	pkt, err := typ.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// decode packet
+	err = json.Unmarshal(data, pkt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+func typeFromString(name string) (Type, bool) {
+	// find type
+	for _, t := range Types() {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
+// checks the type field of a decoded packet if present
+func checkJSONType(name string, t Type) error {
+	// check name
+	if name != "" && !strings.EqualFold(name, t.String()) {
+		return fmt.Errorf("unexpected packet type %q, expected %q", name, t.String())
+	}
+
+	return nil
+}
+
+type jsonMessage struct {
+	Topic    string          `json:"topic"`
+	Payload  string          `json:"payload"`
+	Encoding PayloadEncoding `json:"encoding"`
+	QOS      QOS             `json:"qos"`
+	Retain   bool            `json:"retain"`
+}
+
+// converts the message using the specified payload encoding
+func toJSONMessage(m *Message, encoding PayloadEncoding) (*jsonMessage, error) {
+	// fallback to base64 for binary text
+	if encoding == TextEncoding && !utf8.Valid(m.Payload) {
+		encoding = Base64Encoding
+	}
+
+	// encode payload
+	var payload string
+	switch encoding {
+	case Base64Encoding:
+		payload = base64.StdEncoding.EncodeToString(m.Payload)
+	case HexEncoding:
+		payload = hex.EncodeToString(m.Payload)
+	case TextEncoding:
+		payload = string(m.Payload)
+	default:
+		return nil, fmt.Errorf("unsupported payload encoding %q", encoding)
+	}
+
+	return &jsonMessage{
+		Topic:    m.Topic,
+		Payload:  payload,
+		Encoding: encoding,
+		QOS:      m.QOS,
+		Retain:   m.Retain,
+	}, nil
+}
+
+// converts the message and decodes the payload using the stored encoding or
+// base64 if missing
+func fromJSONMessage(msg *jsonMessage) (*Message, error) {
+	// decode payload
+	var payload []byte
+	var err error
+	switch msg.Encoding {
+	case Base64Encoding, "":
+		payload, err = base64.StdEncoding.DecodeString(msg.Payload)
+	case HexEncoding:
+		payload, err = hex.DecodeString(msg.Payload)
+	case TextEncoding:
+		payload = []byte(msg.Payload)
+	default:
+		err = fmt.Errorf("unsupported payload encoding %q", msg.Encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// keep nil payloads
+	if len(payload) == 0 {
+		payload = nil
+	}
+
+	return &Message{
+		Topic:   msg.Topic,
+		Payload: payload,
+		QOS:     msg.QOS,
+		Retain:  msg.Retain,
+	}, nil
+}
+
+type jsonConnect struct {
+	Type         string       `json:"type"`
+	ClientID     string       `json:"client_id"`
+	KeepAlive    uint16       `json:"keep_alive"`
+	Username     string       `json:"username"`
+	Password     string       `json:"password,omitempty"`
+	CleanSession bool         `json:"clean_session"`
+	Will         *jsonMessage `json:"will"`
+	Version      byte         `json:"version"`
+}
+
+// MarshalJSON returns the JSON representation of the packet. The password is
+// redacted and not included in the representation.
+func (cp *Connect) MarshalJSON() ([]byte, error) {
+	return cp.marshalJSON(Base64Encoding)
+}
+
+func (cp *Connect) marshalJSON(encoding PayloadEncoding) ([]byte, error) {
+	// convert will
+	var will *jsonMessage
+	if cp.Will != nil {
+		var err error
+		will, err = toJSONMessage(cp.Will, encoding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(jsonConnect{
+		Type:         cp.Type().String(),
+		ClientID:     cp.ClientID,
+		KeepAlive:    cp.KeepAlive,
+		Username:     cp.Username,
+		CleanSession: cp.CleanSession,
+		Will:         will,
+		Version:      cp.Version,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (cp *Connect) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonConnect
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, cp.Type())
+	if err != nil {
+		return err
+	}
+
+	// convert will
+	var will *Message
+	if p.Will != nil {
+		will, err = fromJSONMessage(p.Will)
+		if err != nil {
+			return err
+		}
+	}
+
+	// set fields
+	cp.ClientID = p.ClientID
+	cp.KeepAlive = p.KeepAlive
+	cp.Username = p.Username
+	cp.Password = p.Password
+	cp.CleanSession = p.CleanSession
+	cp.Will = will
+	cp.Version = p.Version
+
+	return nil
+}
+
+type jsonConnack struct {
+	Type           string      `json:"type"`
+	SessionPresent bool        `json:"session_present"`
+	ReturnCode     ConnackCode `json:"return_code"`
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (cp *Connack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonConnack{
+		Type:           cp.Type().String(),
+		SessionPresent: cp.SessionPresent,
+		ReturnCode:     cp.ReturnCode,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (cp *Connack) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonConnack
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, cp.Type())
+	if err != nil {
+		return err
+	}
+
+	// set fields
+	cp.SessionPresent = p.SessionPresent
+	cp.ReturnCode = p.ReturnCode
+
+	return nil
+}
+
+type jsonPublish struct {
+	Type    string      `json:"type"`
+	ID      ID          `json:"id"`
+	Dup     bool        `json:"dup"`
+	Message jsonMessage `json:"message"`
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Publish) MarshalJSON() ([]byte, error) {
+	return pp.marshalJSON(Base64Encoding)
+}
+
+func (pp *Publish) marshalJSON(encoding PayloadEncoding) ([]byte, error) {
+	// convert message
+	msg, err := toJSONMessage(&pp.Message, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonPublish{
+		Type:    pp.Type().String(),
+		ID:      pp.ID,
+		Dup:     pp.Dup,
+		Message: *msg,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Publish) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonPublish
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, pp.Type())
+	if err != nil {
+		return err
+	}
+
+	// convert message
+	msg, err := fromJSONMessage(&p.Message)
+	if err != nil {
+		return err
+	}
+
+	// set fields
+	pp.ID = p.ID
+	pp.Dup = p.Dup
+	pp.Message = *msg
+
+	return nil
+}
+
+type jsonSubscription struct {
+	Topic string `json:"topic"`
+	QOS   QOS    `json:"qos"`
+}
+
+type jsonSubscribe struct {
+	Type          string             `json:"type"`
+	ID            ID                 `json:"id"`
+	Subscriptions []jsonSubscription `json:"subscriptions"`
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (sp *Subscribe) MarshalJSON() ([]byte, error) {
+	// convert subscriptions
+	subs := make([]jsonSubscription, 0, len(sp.Subscriptions))
+	for _, sub := range sp.Subscriptions {
+		subs = append(subs, jsonSubscription(sub))
+	}
+
+	return json.Marshal(jsonSubscribe{
+		Type:          sp.Type().String(),
+		ID:            sp.ID,
+		Subscriptions: subs,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (sp *Subscribe) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonSubscribe
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, sp.Type())
+	if err != nil {
+		return err
+	}
+
+	// convert subscriptions
+	var subs []Subscription
+	for _, sub := range p.Subscriptions {
+		subs = append(subs, Subscription(sub))
+	}
+
+	// set fields
+	sp.ID = p.ID
+	sp.Subscriptions = subs
+
+	return nil
+}
+
+type jsonSuback struct {
+	Type        string `json:"type"`
+	ID          ID     `json:"id"`
+	ReturnCodes []QOS  `json:"return_codes"`
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (sp *Suback) MarshalJSON() ([]byte, error) {
+	// ensure list
+	codes := sp.ReturnCodes
+	if codes == nil {
+		codes = []QOS{}
+	}
+
+	return json.Marshal(jsonSuback{
+		Type:        sp.Type().String(),
+		ID:          sp.ID,
+		ReturnCodes: codes,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (sp *Suback) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonSuback
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, sp.Type())
+	if err != nil {
+		return err
+	}
+
+	// keep nil lists
+	if len(p.ReturnCodes) == 0 {
+		p.ReturnCodes = nil
+	}
+
+	// set fields
+	sp.ID = p.ID
+	sp.ReturnCodes = p.ReturnCodes
+
+	return nil
+}
+
+type jsonUnsubscribe struct {
+	Type   string   `json:"type"`
+	ID     ID       `json:"id"`
+	Topics []string `json:"topics"`
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (up *Unsubscribe) MarshalJSON() ([]byte, error) {
+	// ensure list
+	topics := up.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+
+	return json.Marshal(jsonUnsubscribe{
+		Type:   up.Type().String(),
+		ID:     up.ID,
+		Topics: topics,
+	})
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (up *Unsubscribe) UnmarshalJSON(data []byte) error {
+	// decode packet
+	var p jsonUnsubscribe
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, up.Type())
+	if err != nil {
+		return err
+	}
+
+	// keep nil lists
+	if len(p.Topics) == 0 {
+		p.Topics = nil
+	}
+
+	// set fields
+	up.ID = p.ID
+	up.Topics = p.Topics
+
+	return nil
+}
+
+type jsonIdentified struct {
+	Type string `json:"type"`
+	ID   ID     `json:"id"`
+}
+
+func identifiedMarshalJSON(t Type, id ID) ([]byte, error) {
+	return json.Marshal(jsonIdentified{
+		Type: t.String(),
+		ID:   id,
+	})
+}
+
+func identifiedUnmarshalJSON(data []byte, t Type) (ID, error) {
+	// decode packet
+	var p jsonIdentified
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return 0, err
+	}
+
+	// check type
+	err = checkJSONType(p.Type, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.ID, nil
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Puback) MarshalJSON() ([]byte, error) {
+	return identifiedMarshalJSON(pp.Type(), pp.ID)
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Puback) UnmarshalJSON(data []byte) (err error) {
+	pp.ID, err = identifiedUnmarshalJSON(data, pp.Type())
+	return err
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Pubcomp) MarshalJSON() ([]byte, error) {
+	return identifiedMarshalJSON(pp.Type(), pp.ID)
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Pubcomp) UnmarshalJSON(data []byte) (err error) {
+	pp.ID, err = identifiedUnmarshalJSON(data, pp.Type())
+	return err
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Pubrec) MarshalJSON() ([]byte, error) {
+	return identifiedMarshalJSON(pp.Type(), pp.ID)
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Pubrec) UnmarshalJSON(data []byte) (err error) {
+	pp.ID, err = identifiedUnmarshalJSON(data, pp.Type())
+	return err
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Pubrel) MarshalJSON() ([]byte, error) {
+	return identifiedMarshalJSON(pp.Type(), pp.ID)
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Pubrel) UnmarshalJSON(data []byte) (err error) {
+	pp.ID, err = identifiedUnmarshalJSON(data, pp.Type())
+	return err
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (up *Unsuback) MarshalJSON() ([]byte, error) {
+	return identifiedMarshalJSON(up.Type(), up.ID)
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (up *Unsuback) UnmarshalJSON(data []byte) (err error) {
+	up.ID, err = identifiedUnmarshalJSON(data, up.Type())
+	return err
+}
+
+type jsonNaked struct {
+	Type string `json:"type"`
+}
+
+func nakedMarshalJSON(t Type) ([]byte, error) {
+	return json.Marshal(jsonNaked{
+		Type: t.String(),
+	})
+}
+
+func nakedUnmarshalJSON(data []byte, t Type) error {
+	// decode packet
+	var p jsonNaked
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	return checkJSONType(p.Type, t)
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (dp *Disconnect) MarshalJSON() ([]byte, error) {
+	return nakedMarshalJSON(dp.Type())
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (dp *Disconnect) UnmarshalJSON(data []byte) error {
+	return nakedUnmarshalJSON(data, dp.Type())
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Pingreq) MarshalJSON() ([]byte, error) {
+	return nakedMarshalJSON(pp.Type())
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Pingreq) UnmarshalJSON(data []byte) error {
+	return nakedUnmarshalJSON(data, pp.Type())
+}
+
+// MarshalJSON returns the JSON representation of the packet.
+func (pp *Pingresp) MarshalJSON() ([]byte, error) {
+	return nakedMarshalJSON(pp.Type())
+}
+
+// UnmarshalJSON decodes the JSON representation of the packet.
+func (pp *Pingresp) UnmarshalJSON(data []byte) error {
+	return nakedUnmarshalJSON(data, pp.Type())
+}
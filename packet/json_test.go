@@ -0,0 +1,225 @@
+package packet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	packets := []Generic{
+		&Connect{
+			ClientID:     "c1",
+			KeepAlive:    30,
+			Username:     "user",
+			CleanSession: true,
+			Will: &Message{
+				Topic:   "will",
+				Payload: []byte("bye"),
+				QOS:     1,
+				Retain:  true,
+			},
+			Version: Version311,
+		},
+		&Connack{SessionPresent: true, ReturnCode: NotAuthorized},
+		&Publish{
+			Message: Message{
+				Topic:   "foo",
+				Payload: []byte{0x00, 0xFF, 0x10},
+				QOS:     2,
+			},
+			Dup: true,
+			ID:  7,
+		},
+		&Puback{ID: 1},
+		&Pubrec{ID: 2},
+		&Pubrel{ID: 3},
+		&Pubcomp{ID: 4},
+		&Subscribe{
+			Subscriptions: []Subscription{
+				{Topic: "foo/+", QOS: 1},
+				{Topic: "bar/#", QOS: 2},
+			},
+			ID: 5,
+		},
+		&Suback{ReturnCodes: []QOS{0, 1, QOSFailure}, ID: 5},
+		&Unsubscribe{Topics: []string{"foo/+"}, ID: 6},
+		&Unsuback{ID: 6},
+		&Pingreq{},
+		&Pingresp{},
+		&Disconnect{},
+	}
+
+	for _, pkt := range packets {
+		t.Run(pkt.Type().String(), func(t *testing.T) {
+			data, err := json.Marshal(pkt)
+			require.NoError(t, err)
+
+			out, err := FromJSON(data)
+			require.NoError(t, err)
+			assert.Equal(t, pkt, out)
+		})
+	}
+}
+
+func TestJSONConnectPassword(t *testing.T) {
+	data, err := json.Marshal(&Connect{
+		ClientID: "c1",
+		Username: "user",
+		Password: "secret",
+	})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.NotContains(t, string(data), "password")
+
+	pkt, err := FromJSON([]byte(`{"type":"Connect","username":"user","password":"secret"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Connect{Username: "user", Password: "secret"}, pkt)
+}
+
+func TestJSONMessage(t *testing.T) {
+	data, err := json.Marshal(Message{Topic: "foo", Payload: []byte("bar"), QOS: 1})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"Topic": "foo",
+		"Payload": "YmFy",
+		"QOS": 1,
+		"Retain": false
+	}`, string(data))
+}
+
+func TestJSONFormat(t *testing.T) {
+	data, err := json.Marshal(&Publish{
+		Message: Message{
+			Topic:   "foo",
+			Payload: []byte("bar"),
+			QOS:     1,
+		},
+		ID: 1,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "Publish",
+		"id": 1,
+		"dup": false,
+		"message": {
+			"topic": "foo",
+			"payload": "YmFy",
+			"encoding": "base64",
+			"qos": 1,
+			"retain": false
+		}
+	}`, string(data))
+
+	data, err = json.Marshal(&Subscribe{
+		Subscriptions: []Subscription{{Topic: "foo", QOS: 1}},
+		ID:            2,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "Subscribe",
+		"id": 2,
+		"subscriptions": [{"topic": "foo", "qos": 1}]
+	}`, string(data))
+
+	data, err = json.Marshal(&Pingreq{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "Pingreq"}`, string(data))
+}
+
+func TestToJSON(t *testing.T) {
+	table := []struct {
+		encoding PayloadEncoding
+		payload  []byte
+		result   string
+		actual   PayloadEncoding
+	}{
+		{Base64Encoding, []byte("bar"), "YmFy", Base64Encoding},
+		{HexEncoding, []byte("bar"), "626172", HexEncoding},
+		{TextEncoding, []byte("bar"), "bar", TextEncoding},
+		{TextEncoding, []byte{0xFF}, "/w==", Base64Encoding},
+	}
+
+	for _, item := range table {
+		pkt := &Publish{Message: Message{Topic: "foo", Payload: item.payload}}
+
+		data, err := ToJSON(pkt, item.encoding)
+		assert.NoError(t, err)
+
+		var out jsonPublish
+		err = json.Unmarshal(data, &out)
+		assert.NoError(t, err)
+		assert.Equal(t, item.result, out.Message.Payload)
+		assert.Equal(t, item.actual, out.Message.Encoding)
+
+		pkt2, err := FromJSON(data)
+		assert.NoError(t, err)
+		assert.Equal(t, pkt, pkt2)
+
+		will := &Connect{Will: &Message{Topic: "foo", Payload: item.payload}}
+
+		data, err = ToJSON(will, item.encoding)
+		assert.NoError(t, err)
+
+		will2, err := FromJSON(data)
+		assert.NoError(t, err)
+		assert.Equal(t, will, will2)
+	}
+
+	data, err := ToJSON(&Puback{ID: 1}, HexEncoding)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "Puback", "id": 1}`, string(data))
+
+	_, err = ToJSON(&Publish{}, "foo")
+	assert.Error(t, err)
+
+	_, err = ToJSON(&Connect{Will: &Message{}}, "foo")
+	assert.Error(t, err)
+}
+
+func TestFromJSON(t *testing.T) {
+	pkt, err := FromJSON([]byte(`{"type":"publish","message":{"topic":"foo","payload":"bar","encoding":"text"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Publish{
+		Message: Message{
+			Topic:   "foo",
+			Payload: []byte("bar"),
+		},
+	}, pkt)
+
+	pkt, err = FromJSON([]byte(`{"type":"Puback","id":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Puback{ID: 5}, pkt)
+
+	pkt, err = FromJSON([]byte(`{"type":"Foo"}`))
+	assert.Equal(t, ErrInvalidPacketType, err)
+	assert.Nil(t, pkt)
+
+	pkt, err = FromJSON([]byte(`{}`))
+	assert.Equal(t, ErrInvalidPacketType, err)
+	assert.Nil(t, pkt)
+
+	pkt, err = FromJSON([]byte(`[`))
+	assert.Error(t, err)
+	assert.Nil(t, pkt)
+
+	pkt, err = FromJSON([]byte(`{"type":"Publish","message":{"payload":"foo","encoding":"bar"}}`))
+	assert.Error(t, err)
+	assert.Nil(t, pkt)
+}
+
+func TestJSONTypeMismatch(t *testing.T) {
+	var puback Puback
+	err := json.Unmarshal([]byte(`{"type":"Pubrec","id":1}`), &puback)
+	assert.Error(t, err)
+
+	var connect Connect
+	err = json.Unmarshal([]byte(`{"type":"Publish"}`), &connect)
+	assert.Error(t, err)
+
+	err = json.Unmarshal([]byte(`{"client_id":"foo"}`), &connect)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", connect.ClientID)
+}
@@ -0,0 +1,75 @@
+package packet
+
+import "sync"
+
+// the maximum size of buffers that are returned to the pool
+const maxPooledBuffer = 64 << 10
+
+var publishPool = sync.Pool{
+	New: func() interface{} {
+		return &Publish{}
+	},
+}
+
+var bufferPool sync.Pool
+
+// returns a pooled publish packet
+func acquirePublish() *Publish {
+	// get packet
+	pp := publishPool.Get().(*Publish)
+	pp.pooled = true
+
+	return pp
+}
+
+// returns a pooled buffer with the specified length
+func acquireBuffer(length int) *[]byte {
+	// get buffer from pool
+	if value := bufferPool.Get(); value != nil {
+		buf := value.(*[]byte)
+		if cap(*buf) >= length {
+			*buf = (*buf)[:length]
+			return buf
+		}
+	}
+
+	// allocate new buffer
+	buf := make([]byte, length)
+
+	return &buf
+}
+
+// returns the buffer to the pool
+func releaseBuffer(buf *[]byte) {
+	// skip large buffers
+	if cap(*buf) > maxPooledBuffer {
+		return
+	}
+
+	bufferPool.Put(buf)
+}
+
+// Release will return a publish packet that has been read by a pooled decoder
+// together with its payload buffer to the pool. The packet and the payload must
+// not be used after calling Release. Calling Release on packets that have not
+// been read by a pooled decoder has no effect.
+func (pp *Publish) Release() {
+	// check if pooled
+	if !pp.pooled {
+		return
+	}
+
+	// get buffer
+	buf := pp.buffer
+
+	// reset packet
+	*pp = Publish{}
+
+	// return buffer
+	if buf != nil {
+		releaseBuffer(buf)
+	}
+
+	// return packet
+	publishPool.Put(pp)
+}
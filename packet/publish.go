@@ -19,6 +19,10 @@ type Publish struct {
 
 	// The packet identifier.
 	ID ID
+
+	// the pool state
+	pooled bool
+	buffer *[]byte
 }
 
 // NewPublish creates a new Publish packet.
@@ -46,6 +50,12 @@ func (pp *Publish) Len() int {
 // Decode reads from the byte slice argument. It returns the total number of
 // bytes decoded, and whether there have been any errors during the process.
 func (pp *Publish) Decode(src []byte) (int, error) {
+	return pp.decode(src, false)
+}
+
+// decode will decode the packet and reference the payload in the source
+// instead of copying it if requested
+func (pp *Publish) decode(src []byte, zeroCopy bool) (int, error) {
 	// decode header
 	hl, flags, rl, err := headerDecode(src, PUBLISH)
 	total := hl
@@ -53,6 +63,36 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 		return total, err
 	}
 
+	// decode variable header
+	n, err := pp.decodeVariableHeader(src[total:], flags)
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	// calculate payload length
+	l := rl - (total - hl)
+
+	// reference payload
+	if l > 0 && zeroCopy {
+		pp.Message.Payload = src[total : total+l]
+		total += l
+		return total, nil
+	}
+
+	// read payload
+	if l > 0 {
+		pp.Message.Payload = make([]byte, l)
+		copy(pp.Message.Payload, src[total:total+l])
+		total += len(pp.Message.Payload)
+	}
+
+	return total, nil
+}
+
+// decodeVariableHeader will decode the flags and the variable header that
+// follows the fixed header
+func (pp *Publish) decodeVariableHeader(src []byte, flags byte) (int, error) {
 	// read flags
 	pp.Dup = ((flags >> 3) & 0x1) == 1
 	pp.Message.Retain = (flags & 0x1) == 1
@@ -60,13 +100,13 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 
 	// check qos
 	if !pp.Message.QOS.Successful() {
-		return total, makeError(pp.Type(), "invalid QOS level (%d)", pp.Message.QOS)
+		return 0, makeError(pp.Type(), "invalid QOS level (%d)", pp.Message.QOS)
 	}
 
 	// read topic
-	n := 0
-	pp.Message.Topic, n, err = readLPString(src[total:], pp.Type())
-	total += n
+	var err error
+	total := 0
+	pp.Message.Topic, total, err = readLPString(src, pp.Type())
 	if err != nil {
 		return total, err
 	}
@@ -88,16 +128,6 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 		}
 	}
 
-	// calculate payload length
-	l := rl - (total - hl)
-
-	// read payload
-	if l > 0 {
-		pp.Message.Payload = make([]byte, l)
-		copy(pp.Message.Payload, src[total:total+l])
-		total += len(pp.Message.Payload)
-	}
-
 	return total, nil
 }
 
@@ -105,6 +135,22 @@ func (pp *Publish) Decode(src []byte) (int, error) {
 // returns the number of bytes encoded and whether there's any errors along
 // the way. If there is an error, the byte slice should be considered invalid.
 func (pp *Publish) Encode(dst []byte) (int, error) {
+	// encode headers
+	total, err := pp.encodeHeaders(dst, len(pp.Message.Payload), pp.Len())
+	if err != nil {
+		return total, err
+	}
+
+	// write payload
+	copy(dst[total:], pp.Message.Payload)
+	total += len(pp.Message.Payload)
+
+	return total, nil
+}
+
+// encodeHeaders will encode the fixed and variable header for a payload of the
+// specified length. The buffer must have the specified minimum length.
+func (pp *Publish) encodeHeaders(dst []byte, payloadLength, minLength int) (int, error) {
 	// check topic length
 	if len(pp.Message.Topic) == 0 {
 		return 0, makeError(pp.Type(), "topic name is empty")
@@ -137,7 +183,7 @@ func (pp *Publish) Encode(dst []byte) (int, error) {
 	flags = (flags & 249) | (byte(pp.Message.QOS) << 1) // 249 = 11111001
 
 	// encode header
-	total, err := headerEncode(dst, flags, pp.len(), pp.Len(), PUBLISH)
+	total, err := headerEncode(dst, flags, pp.variableHeaderLen()+payloadLength, minLength, PUBLISH)
 	if err != nil {
 		return total, err
 	}
@@ -155,16 +201,17 @@ func (pp *Publish) Encode(dst []byte) (int, error) {
 		total += 2
 	}
 
-	// write payload
-	copy(dst[total:], pp.Message.Payload)
-	total += len(pp.Message.Payload)
-
 	return total, nil
 }
 
-// Returns the payload length.
+// Returns the remaining length.
 func (pp *Publish) len() int {
-	total := 2 + len(pp.Message.Topic) + len(pp.Message.Payload)
+	return pp.variableHeaderLen() + len(pp.Message.Payload)
+}
+
+// Returns the variable header length.
+func (pp *Publish) variableHeaderLen() int {
+	total := 2 + len(pp.Message.Topic)
 	if pp.Message.QOS != 0 {
 		total += 2
 	}
@@ -0,0 +1,159 @@
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A PublishStream is a publish packet whose payload is streamed instead of
+// being held in memory. It is returned by a Decoder for publish packets that
+// exceed the stream threshold and can be written by an Encoder to send a
+// payload from a reader.
+type PublishStream struct {
+	// The publish packet that carries the header fields. The payload of its
+	// message is ignored.
+	Header Publish
+
+	// The length of the payload.
+	Length int
+
+	// The payload.
+	Payload io.Reader
+}
+
+// NewPublishStream creates a new PublishStream packet.
+func NewPublishStream() *PublishStream {
+	return &PublishStream{}
+}
+
+// Type returns the packets type.
+func (ps *PublishStream) Type() Type {
+	return PUBLISH
+}
+
+// String returns a string representation of the packet.
+func (ps *PublishStream) String() string {
+	return fmt.Sprintf("<PublishStream ID=%d Topic=%q QOS=%d Retain=%t Dup=%t Length=%d>",
+		ps.Header.ID, ps.Header.Message.Topic, ps.Header.Message.QOS, ps.Header.Message.Retain, ps.Header.Dup, ps.Length)
+}
+
+// Len returns the byte length of the encoded packet.
+func (ps *PublishStream) Len() int {
+	ml := ps.Header.variableHeaderLen() + ps.Length
+	return headerLen(ml) + ml
+}
+
+// Decode reads from the byte slice argument. It returns the total number of
+// bytes decoded, and whether there have been any errors during the process.
+// The payload is copied and made available as a reader.
+func (ps *PublishStream) Decode(src []byte) (int, error) {
+	// decode packet
+	var pp Publish
+	n, err := pp.Decode(src)
+	if err != nil {
+		return n, err
+	}
+
+	// set fields
+	ps.Length = len(pp.Message.Payload)
+	ps.Payload = bytes.NewReader(pp.Message.Payload)
+	pp.Message.Payload = nil
+	ps.Header = pp
+
+	return n, nil
+}
+
+// Encode writes the packet bytes into the byte slice from the argument. It
+// returns the number of bytes encoded and whether there's any errors along
+// the way. The payload is consumed from the reader. If there is an error, the
+// byte slice should be considered invalid.
+func (ps *PublishStream) Encode(dst []byte) (int, error) {
+	// encode headers
+	total, err := ps.Header.encodeHeaders(dst, ps.Length, ps.Len())
+	if err != nil {
+		return total, err
+	}
+
+	// read payload
+	n, err := io.ReadFull(ps.payload(), dst[total:total+ps.Length])
+	total += n
+	if err != nil {
+		return total, makeError(ps.Type(), "failed to read payload: %s", err.Error())
+	}
+
+	return total, nil
+}
+
+// Buffer will read the payload and return a regular publish packet.
+func (ps *PublishStream) Buffer() (*Publish, error) {
+	// read payload
+	payload := make([]byte, ps.Length)
+	_, err := io.ReadFull(ps.payload(), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare packet
+	pp := NewPublish()
+	pp.Message = ps.Header.Message
+	pp.Message.Payload = payload
+	pp.Dup = ps.Header.Dup
+	pp.ID = ps.Header.ID
+
+	// keep nil payloads
+	if ps.Length == 0 {
+		pp.Message.Payload = nil
+	}
+
+	return pp, nil
+}
+
+func (ps *PublishStream) payload() io.Reader {
+	// check reader
+	if ps.Payload == nil {
+		return bytes.NewReader(nil)
+	}
+
+	return ps.Payload
+}
+
+// a reader that reads a fixed number of bytes from a buffered reader
+type payloadReader struct {
+	reader    *bufio.Reader
+	remaining int
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	// check remaining
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	// limit buffer
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	// read data
+	n, err := r.reader.Read(p)
+	r.remaining -= n
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// drain will discard all remaining bytes
+func (r *payloadReader) drain() error {
+	// discard remaining bytes
+	n, err := r.reader.Discard(r.remaining)
+	r.remaining -= n
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,131 @@
+package packet
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishStreamInterface(t *testing.T) {
+	pkt := NewPublishStream()
+	pkt.Header.Message.Topic = "foo"
+	pkt.Length = 3
+
+	assert.Equal(t, PUBLISH, pkt.Type())
+	assert.Equal(t, "<PublishStream ID=0 Topic=\"foo\" QOS=0 Retain=false Dup=false Length=3>", pkt.String())
+}
+
+func TestPublishStreamEncodeDecode(t *testing.T) {
+	pp := NewPublish()
+	pp.Message = Message{
+		Topic:   "foo",
+		Payload: []byte("bar"),
+		QOS:     1,
+		Retain:  true,
+	}
+	pp.ID = 7
+	pp.Dup = true
+
+	expected := make([]byte, pp.Len())
+	_, err := pp.Encode(expected)
+	require.NoError(t, err)
+
+	ps := NewPublishStream()
+	ps.Header.Message = Message{Topic: "foo", QOS: 1, Retain: true}
+	ps.Header.ID = 7
+	ps.Header.Dup = true
+	ps.Length = 3
+	ps.Payload = bytes.NewReader([]byte("bar"))
+	assert.Equal(t, pp.Len(), ps.Len())
+
+	dst := make([]byte, ps.Len())
+	n, err := ps.Encode(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), n)
+	assert.Equal(t, expected, dst)
+
+	ps2 := NewPublishStream()
+	n, err = ps2.Decode(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), n)
+	assert.Equal(t, 3, ps2.Length)
+	assert.Equal(t, "foo", ps2.Header.Message.Topic)
+	assert.Nil(t, ps2.Header.Message.Payload)
+
+	pp2, err := ps2.Buffer()
+	assert.NoError(t, err)
+	assert.Equal(t, pp, pp2)
+}
+
+func TestPublishStreamEncodeError(t *testing.T) {
+	ps := NewPublishStream()
+	ps.Header.Message.Topic = "foo"
+	ps.Length = 10
+	ps.Payload = bytes.NewReader([]byte("bar"))
+
+	dst := make([]byte, ps.Len())
+	_, err := ps.Encode(dst)
+	assert.Error(t, err)
+
+	ps.Header.Message.Topic = ""
+	_, err = ps.Encode(dst)
+	assert.Error(t, err)
+}
+
+func TestPublishStreamBufferError(t *testing.T) {
+	ps := NewPublishStream()
+	ps.Header.Message.Topic = "foo"
+	ps.Length = 10
+	ps.Payload = bytes.NewReader([]byte("bar"))
+
+	_, err := ps.Buffer()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	ps.Length = 0
+	ps.Payload = nil
+
+	pp, err := ps.Buffer()
+	assert.NoError(t, err)
+	assert.Nil(t, pp.Message.Payload)
+}
+
+func TestPublishStreamLarge(t *testing.T) {
+	// 16MB payload that is never fully held in memory
+	const size = 16 << 20
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		ps := NewPublishStream()
+		ps.Header.Message.Topic = "firmware"
+		ps.Length = size
+		ps.Payload = io.LimitReader(&repeatReader{data: []byte("0123456789")}, size)
+
+		enc := NewEncoder(writer)
+		err := enc.Write(ps, false)
+		_ = writer.CloseWithError(err)
+	}()
+
+	dec := NewDecoder(reader)
+	dec.SetReadLimit(size + 100)
+	dec.SetStreamThreshold(1024)
+
+	pkt, err := dec.Read()
+	require.NoError(t, err)
+
+	ps, ok := pkt.(*PublishStream)
+	require.True(t, ok)
+	assert.Equal(t, "firmware", ps.Header.Message.Topic)
+	assert.Equal(t, size, ps.Length)
+
+	n, err := io.Copy(ioutil.Discard, ps.Payload)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), n)
+
+	_, err = dec.Read()
+	assert.Equal(t, io.EOF, err)
+}
@@ -3,12 +3,13 @@ package packet
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"io"
+	"net"
+	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/256dpi/mercury"
 )
 
 // ErrDetectionOverflow is returned by the Decoder if the next packet couldn't
@@ -19,62 +20,305 @@ var ErrDetectionOverflow = errors.New("detection overflow")
 // exceeded its read limit.
 var ErrReadLimitExceeded = errors.New("read limit exceeded")
 
-// An Encoder wraps a writer and continuously encodes packets.
+// the size of pending data that causes an immediate write
+const maxPendingSize = 4 << 10
+
+// the payload size from which publish payloads are written without copying
+const vectoredPayloadSize = 4 << 10
+
+// the size of buffers that are kept after a write
+const maxRetainedBuffer = 64 << 10
+
+// An Encoder wraps a writer and continuously encodes packets. Packets are
+// encoded into one contiguous buffer that is written once it is flushed or gets
+// stale. Large publish payloads are queued by reference and written together
+// with the buffer using vectored I/O without copying them.
 type Encoder struct {
-	writer *mercury.Writer
-	buffer bytes.Buffer
+	delay    int64
+	writer   io.Writer
+	buffer   []byte
+	segments []segment
+	pending  int
+	timer    *time.Timer
+	err      error
+	vectored int64
+	mutex    sync.Mutex
+}
+
+// a payload that is written after the buffer up to the offset
+type segment struct {
+	offset  int
+	payload []byte
 }
 
 // NewEncoder creates a new Encoder.
 func NewEncoder(writer io.Writer) *Encoder {
 	return &Encoder{
-		writer: mercury.NewWriter(writer, 0),
+		writer:   writer,
+		vectored: vectoredPayloadSize,
 	}
 }
 
-// Write encodes and writes the passed packet to the write buffer.
+// Write encodes and writes the passed packet to the write buffer. The buffer
+// is flushed immediately if async is false, the max write delay is zero or the
+// buffer is full. Otherwise, it is flushed when it gets stale. Any error
+// encountered while flushing asynchronously is returned on the next call.
+//
+// The payload of a publish packet with a large payload is not copied and must
+// not be modified until the buffer has been flushed. Streamed publish packets
+// are always written immediately together with the buffered data, as their
+// payload is copied from its reader without buffering the whole packet.
 func (e *Encoder) Write(pkt Generic, async bool) error {
-	// reset and potentially grow buffer
-	packetLength := pkt.Len()
-	e.buffer.Reset()
-	e.buffer.Grow(packetLength)
-	buf := e.buffer.Bytes()[0:packetLength]
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// return error from asynchronous flush
+	if e.err != nil {
+		err := e.err
+		e.err = nil
+		return err
+	}
 
 	// encode packet
-	_, err := pkt.Encode(buf)
+	var err error
+	switch p := pkt.(type) {
+	case *Publish:
+		threshold := atomic.LoadInt64(&e.vectored)
+		if threshold > 0 && int64(len(p.Message.Payload)) >= threshold {
+			err = e.encodeVectored(p)
+		} else {
+			err = e.encode(pkt)
+		}
+	case *PublishStream:
+		return e.writeStream(p)
+	default:
+		err = e.encode(pkt)
+	}
 	if err != nil {
 		return err
 	}
 
-	// write buffer
-	if async {
-		_, err = e.writer.Write(buf)
-	} else {
-		_, err = e.writer.WriteAndFlush(buf)
+	// get delay
+	delay := time.Duration(atomic.LoadInt64(&e.delay))
+
+	// flush immediately if requested, the delay is zero or the buffer is full
+	if !async || delay == 0 || len(e.buffer)+e.pending >= maxPendingSize {
+		return e.flush()
+	}
+
+	// setup timer
+	if e.timer == nil {
+		e.timer = time.AfterFunc(delay, e.asyncFlush)
+	}
+
+	return nil
+}
+
+func (e *Encoder) encode(pkt Generic) error {
+	// get length
+	start := len(e.buffer)
+	length := pkt.Len()
+
+	// grow buffer
+	e.buffer = grow(e.buffer, length)
+
+	// encode packet
+	_, err := pkt.Encode(e.buffer[start : start+length])
+	if err != nil {
+		e.buffer = e.buffer[:start]
+		return err
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeVectored(pp *Publish) error {
+	// get lengths
+	start := len(e.buffer)
+	length := pp.Len() - len(pp.Message.Payload)
+
+	// grow buffer
+	e.buffer = grow(e.buffer, length)
+
+	// encode headers
+	_, err := pp.encodeHeaders(e.buffer[start:start+length], len(pp.Message.Payload), length)
+	if err != nil {
+		e.buffer = e.buffer[:start]
+		return err
+	}
+
+	// queue payload
+	e.segments = append(e.segments, segment{
+		offset:  len(e.buffer),
+		payload: pp.Message.Payload,
+	})
+	e.pending += len(pp.Message.Payload)
+
+	return nil
+}
+
+func (e *Encoder) writeStream(ps *PublishStream) error {
+	// get lengths
+	start := len(e.buffer)
+	length := ps.Len() - ps.Length
+
+	// grow buffer
+	e.buffer = grow(e.buffer, length)
+
+	// encode headers
+	_, err := ps.Header.encodeHeaders(e.buffer[start:start+length], ps.Length, length)
+	if err != nil {
+		e.buffer = e.buffer[:start]
+		return err
 	}
+
+	// write buffer
+	err = e.flush()
 	if err != nil {
 		return err
 	}
 
+	// copy payload
+	_, err = io.CopyN(e.writer, ps.payload(), int64(ps.Length))
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // Flush flushes the writer buffer.
 func (e *Encoder) Flush() error {
-	return e.writer.Flush()
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// return error from asynchronous flush
+	if e.err != nil {
+		err := e.err
+		e.err = nil
+		return err
+	}
+
+	return e.flush()
 }
 
 // SetMaxWriteDelay will set the maximum amount of time allowed to pass until
 // an asynchronous write is flushed.
 func (e *Encoder) SetMaxWriteDelay(delay time.Duration) {
-	e.writer.SetMaxDelay(delay)
+	atomic.StoreInt64(&e.delay, int64(delay))
+}
+
+// SetVectoredThreshold will set the vectored threshold. Publish packets with a
+// payload of at least the threshold are written without copying the payload
+// into the buffer. A threshold of zero disables vectored writes.
+func (e *Encoder) SetVectoredThreshold(threshold int64) {
+	atomic.StoreInt64(&e.vectored, threshold)
+}
+
+func (e *Encoder) flush() error {
+	// check buffer
+	if len(e.buffer) == 0 && len(e.segments) == 0 {
+		e.reset()
+		return nil
+	}
+
+	// write buffer
+	if len(e.segments) == 0 {
+		_, err := e.writer.Write(e.buffer)
+		e.reset()
+		return err
+	}
+
+	// prepare vectors
+	vectors := make(net.Buffers, 0, 2*len(e.segments)+1)
+	offset := 0
+	for _, seg := range e.segments {
+		if seg.offset > offset {
+			vectors = append(vectors, e.buffer[offset:seg.offset])
+		}
+		vectors = append(vectors, seg.payload)
+		offset = seg.offset
+	}
+	if len(e.buffer) > offset {
+		vectors = append(vectors, e.buffer[offset:])
+	}
+
+	// write buffer and payloads
+	_, err := vectors.WriteTo(e.writer)
+	e.reset()
+	return err
+}
+
+func (e *Encoder) asyncFlush() {
+	// acquire mutex
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// clear timer
+	e.timer = nil
+
+	// flush buffer
+	err := e.flush()
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *Encoder) reset() {
+	// stop timer
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	// release payloads
+	for i := range e.segments {
+		e.segments[i] = segment{}
+	}
+	e.segments = e.segments[:0]
+	e.pending = 0
+
+	// release large buffers
+	if cap(e.buffer) > maxRetainedBuffer {
+		e.buffer = nil
+		return
+	}
+
+	// reset buffer
+	e.buffer = e.buffer[:0]
+}
+
+// grow will extend the buffer by the specified length
+func grow(buf []byte, n int) []byte {
+	// extend buffer if capacity is available
+	if len(buf)+n <= cap(buf) {
+		return buf[:len(buf)+n]
+	}
+
+	// allocate new buffer
+	size := 2 * cap(buf)
+	if size < len(buf)+n {
+		size = len(buf) + n
+	}
+	newBuf := make([]byte, len(buf)+n, size)
+	copy(newBuf, buf)
+
+	return newBuf
 }
 
 // A Decoder wraps a Reader and continuously decodes packets.
 type Decoder struct {
-	limit  int64
-	reader *bufio.Reader
-	buffer bytes.Buffer
+	limit      int64
+	threshold  int64
+	pooled     int32
+	strictness int32
+	reader     *bufio.Reader
+	buffer     bytes.Buffer
+	payload    *payloadReader
 }
 
 // NewDecoder returns a new Decoder.
@@ -86,6 +330,15 @@ func NewDecoder(reader io.Reader) *Decoder {
 
 // Read reads the next packet from the buffered reader.
 func (d *Decoder) Read() (Generic, error) {
+	// discard the unread payload of a previously streamed packet
+	if d.payload != nil {
+		err := d.payload.drain()
+		d.payload = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// initial detection length
 	detectionLength := 2
 
@@ -120,6 +373,28 @@ func (d *Decoder) Read() (Generic, error) {
 			return nil, ErrReadLimitExceeded
 		}
 
+		// get strictness
+		strict := Strictness(atomic.LoadInt32(&d.strictness)) >= Strict
+
+		// check flags
+		if strict && packetType.Valid() {
+			err := validateFlags(packetType, header[0]&0x0f)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// read streamed publish packets
+		threshold := atomic.LoadInt64(&d.threshold)
+		if packetType == PUBLISH && threshold > 0 && int64(packetLength) > threshold {
+			return d.readStream(header, packetLength, strict)
+		}
+
+		// read pooled publish packets
+		if packetType == PUBLISH && atomic.LoadInt32(&d.pooled) == 1 {
+			return d.readPooled(packetLength, strict)
+		}
+
 		// create packet
 		pkt, err := packetType.New()
 		if err != nil {
@@ -143,10 +418,146 @@ func (d *Decoder) Read() (Generic, error) {
 			return nil, err
 		}
 
+		// validate packet
+		if strict {
+			err = Validate(pkt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		return pkt, nil
 	}
 }
 
+func (d *Decoder) readPooled(packetLength int, strict bool) (Generic, error) {
+	// get buffer
+	buf := acquireBuffer(packetLength)
+
+	// read whole packet (will not return EOF)
+	_, err := io.ReadFull(d.reader, *buf)
+	if err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+
+	// get packet
+	pp := acquirePublish()
+	pp.buffer = buf
+
+	// decode buffer without copying the payload
+	_, err = pp.decode(*buf, true)
+	if err != nil {
+		pp.Release()
+		return nil, err
+	}
+
+	// validate packet
+	if strict {
+		err = Validate(pp)
+		if err != nil {
+			pp.Release()
+			return nil, err
+		}
+	}
+
+	return pp, nil
+}
+
+func (d *Decoder) readStream(header []byte, packetLength int, strict bool) (Generic, error) {
+	// get fixed header length
+	_, n := binary.Uvarint(header[1:])
+	hl := 1 + n
+
+	// peek topic length
+	buf, err := d.reader.Peek(hl + 2)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	// get variable header length
+	vl := 2 + int(binary.BigEndian.Uint16(buf[hl:]))
+	if (buf[0]>>1)&0x3 != 0 {
+		vl += 2
+	}
+
+	// check variable header length
+	if hl+vl > packetLength {
+		return nil, makeError(PUBLISH, "remaining length (%d) is smaller than variable header (%d)", packetLength-hl, vl)
+	}
+
+	// reset and eventually grow buffer
+	d.buffer.Reset()
+	d.buffer.Grow(hl + vl)
+	buf = d.buffer.Bytes()[0 : hl+vl]
+
+	// read headers (will not return EOF)
+	_, err = io.ReadFull(d.reader, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare packet
+	ps := NewPublishStream()
+	ps.Length = packetLength - hl - vl
+
+	// decode variable header
+	_, err = ps.Header.decodeVariableHeader(buf[hl:], buf[0]&0x0f)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare payload
+	payload := &payloadReader{
+		reader:    d.reader,
+		remaining: ps.Length,
+	}
+	ps.Payload = payload
+	d.payload = payload
+
+	// validate packet
+	if strict {
+		err = Validate(ps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ps, nil
+}
+
+// SetPooling will enable or disable the pooled mode. In pooled mode, publish
+// packets and their buffers are acquired from a pool and the payload references
+// the buffer instead of being copied. The packet should be returned to the
+// pool by calling Release once the packet and its payload are not used anymore.
+// Other packet types are allocated as usual.
+func (d *Decoder) SetPooling(enabled bool) {
+	// get value
+	var value int32
+	if enabled {
+		value = 1
+	}
+
+	atomic.StoreInt32(&d.pooled, value)
+}
+
+// SetStrictness will set the strictness level. A strict decoder will return a
+// ConformanceError if a packet violates the specification.
+func (d *Decoder) SetStrictness(level Strictness) {
+	atomic.StoreInt32(&d.strictness, int32(level))
+}
+
+// SetStreamThreshold will set the stream threshold. Publish packets with a
+// length above the threshold are returned as a PublishStream whose payload is
+// read directly from the underlying reader. The payload must be consumed before
+// the next call to Read, which will otherwise discard the remaining payload.
+// The read limit is still enforced. A threshold of zero disables streaming.
+func (d *Decoder) SetStreamThreshold(threshold int64) {
+	atomic.StoreInt64(&d.threshold, threshold)
+}
+
 // SetReadLimit will set the read limit. Packets with a length above that limit
 // will cause the ErrReadLimitExceeded error.
 func (d *Decoder) SetReadLimit(limit int64) {
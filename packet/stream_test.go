@@ -3,7 +3,10 @@ package packet
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"testing"
 	"time"
 
@@ -50,6 +53,165 @@ func TestEncoderWriterError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestEncoderBatching(t *testing.T) {
+	buf := new(countingWriter)
+	enc := NewEncoder(buf)
+	enc.SetMaxWriteDelay(time.Minute)
+
+	pp := NewPublish()
+	pp.Message.Topic = "foo"
+	pp.Message.Payload = []byte("bar")
+
+	pingresp := NewPingresp()
+
+	var expected []byte
+	for i := 0; i < 10; i++ {
+		err := enc.Write(pp, true)
+		assert.NoError(t, err)
+
+		err = enc.Write(pingresp, true)
+		assert.NoError(t, err)
+
+		expected = append(expected, encode(t, pp)...)
+		expected = append(expected, encode(t, pingresp)...)
+	}
+
+	assert.Equal(t, 0, buf.Writes())
+
+	err := enc.Flush()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, buf.Writes())
+	assert.Equal(t, expected, buf.Bytes())
+
+	err = enc.Write(pingresp, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, buf.Writes())
+}
+
+func TestEncoderMaxWriteDelay(t *testing.T) {
+	buf := new(countingWriter)
+	enc := NewEncoder(buf)
+
+	err := enc.Write(NewPingresp(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, buf.Writes())
+
+	enc.SetMaxWriteDelay(10 * time.Millisecond)
+
+	err = enc.Write(NewPingresp(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, buf.Writes())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, buf.Writes())
+	assert.Equal(t, 4, buf.Len())
+}
+
+func TestEncoderFullBuffer(t *testing.T) {
+	buf := new(countingWriter)
+	enc := NewEncoder(buf)
+	enc.SetMaxWriteDelay(time.Minute)
+
+	pp := NewPublish()
+	pp.Message.Topic = "foo"
+	pp.Message.Payload = make([]byte, 1000)
+
+	for i := 0; i < 4; i++ {
+		err := enc.Write(pp, true)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 0, buf.Writes())
+
+	err := enc.Write(pp, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, buf.Writes())
+	assert.Equal(t, 5*pp.Len(), buf.Len())
+}
+
+func TestEncoderVectored(t *testing.T) {
+	buf := new(countingWriter)
+	enc := NewEncoder(buf)
+	enc.SetMaxWriteDelay(time.Minute)
+
+	small := NewPuback()
+	small.ID = 1
+
+	large := NewPublish()
+	large.Message.Topic = "foo"
+	large.Message.Payload = bytes.Repeat([]byte("x"), 8192)
+
+	err := enc.Write(small, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, buf.Writes())
+
+	err = enc.Write(large, true)
+	assert.NoError(t, err)
+	assert.Equal(t, append(encode(t, small), encode(t, large)...), buf.Bytes())
+
+	large.Message.Topic = ""
+	err = enc.Write(large, true)
+	assert.Error(t, err)
+}
+
+func TestEncoderVectoredAsync(t *testing.T) {
+	buf := new(countingWriter)
+	enc := NewEncoder(buf)
+	enc.SetMaxWriteDelay(10 * time.Millisecond)
+	enc.SetVectoredThreshold(100)
+
+	small := NewPuback()
+	small.ID = 1
+
+	large := NewPublish()
+	large.Message.Topic = "foo"
+	large.Message.Payload = bytes.Repeat([]byte("x"), 1000)
+
+	err := enc.Write(large, true)
+	assert.NoError(t, err)
+
+	err = enc.Write(small, true)
+	assert.NoError(t, err)
+
+	err = enc.Write(large, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, buf.Writes())
+
+	time.Sleep(50 * time.Millisecond)
+
+	expected := append(encode(t, large), encode(t, small)...)
+	expected = append(expected, encode(t, large)...)
+	assert.Equal(t, expected, buf.Bytes())
+
+	for i := 0; i < 4; i++ {
+		err = enc.Write(large, true)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, len(expected), buf.Len())
+
+	err = enc.Write(large, true)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected)+5*large.Len(), buf.Len())
+}
+
+func TestEncoderAsyncError(t *testing.T) {
+	enc := NewEncoder(&errorWriter{
+		err: errors.New("foo"),
+	})
+	enc.SetMaxWriteDelay(time.Millisecond)
+
+	err := enc.Write(NewPingresp(), true)
+	assert.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	err = enc.Write(NewPingresp(), true)
+	assert.Error(t, err)
+
+	err = enc.Flush()
+	assert.NoError(t, err)
+}
+
 func TestDecoder(t *testing.T) {
 	buf := new(bytes.Buffer)
 	dec := NewDecoder(buf)
@@ -144,6 +306,238 @@ func TestDecoderDecodeError(t *testing.T) {
 	assert.Nil(t, pkt)
 }
 
+func TestDecoderPooling(t *testing.T) {
+	pkt := NewPublish()
+	pkt.ID = 1
+	pkt.Message = Message{
+		Topic:   "foo",
+		Payload: []byte("bar"),
+		QOS:     1,
+	}
+
+	b := make([]byte, pkt.Len())
+	_, err := pkt.Encode(b)
+	assert.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	buf.Write(b)
+	buf.Write(b)
+	buf.Write([]byte{0x36, 0x00})
+
+	dec := NewDecoder(buf)
+	dec.SetPooling(true)
+
+	for i := 0; i < 2; i++ {
+		p, err := dec.Read()
+		assert.NoError(t, err)
+
+		pp := p.(*Publish)
+		assert.True(t, pp.pooled)
+		assert.NotNil(t, pp.buffer)
+		assert.Equal(t, pkt.Message, pp.Message)
+		assert.Equal(t, pkt.ID, pp.ID)
+		assert.Equal(t, &(*pp.buffer)[len(b)-3], &pp.Message.Payload[0])
+
+		pp.Release()
+		assert.Equal(t, &Publish{}, pp)
+	}
+
+	p, err := dec.Read()
+	assert.Error(t, err)
+	assert.Nil(t, p)
+
+	pkt.Release()
+	assert.Equal(t, "bar", string(pkt.Message.Payload))
+}
+
+func TestDecoderPoolingOtherPackets(t *testing.T) {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xc0, 0x00})
+
+	dec := NewDecoder(buf)
+	dec.SetPooling(true)
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, NewPingreq(), pkt)
+}
+
+func TestDecoderStrictness(t *testing.T) {
+	pkt := NewPublish()
+	pkt.Message.Topic = "foo/+"
+
+	b := make([]byte, pkt.Len())
+	_, err := pkt.Encode(b)
+	assert.NoError(t, err)
+
+	for _, pooled := range []bool{false, true} {
+		buf := new(bytes.Buffer)
+		buf.Write(b)
+		buf.Write(b)
+
+		dec := NewDecoder(buf)
+		dec.SetPooling(pooled)
+
+		p, err := dec.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, "foo/+", p.(*Publish).Message.Topic)
+
+		dec.SetStrictness(Strict)
+
+		p, err = dec.Read()
+		assert.Nil(t, p)
+		assert.Equal(t, &ConformanceError{
+			Type:      PUBLISH,
+			Statement: "MQTT-3.3.2-2",
+			Reason:    "topic name must not contain wildcards",
+		}, err)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{0xc1, 0x00})
+
+	dec := NewDecoder(buf)
+	dec.SetStrictness(Strict)
+
+	p, err := dec.Read()
+	assert.Nil(t, p)
+	assert.Equal(t, "MQTT-2.2.2-2", err.(*ConformanceError).Statement)
+}
+
+func TestDecoderStreaming(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	small := NewPublish()
+	small.Message.Topic = "foo"
+	small.Message.Payload = []byte("bar")
+
+	large := NewPublish()
+	large.Message.Topic = "foo"
+	large.Message.Payload = bytes.Repeat([]byte("x"), 1000)
+	large.Message.QOS = 1
+	large.ID = 1
+
+	puback := NewPuback()
+	puback.ID = 1
+
+	for _, pkt := range []Generic{small, large, large, puback} {
+		err := enc.Write(pkt, true)
+		assert.NoError(t, err)
+	}
+
+	err := enc.Flush()
+	assert.NoError(t, err)
+
+	dec := NewDecoder(buf)
+	dec.SetStreamThreshold(100)
+
+	pkt, err := dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, small, pkt)
+
+	pkt, err = dec.Read()
+	assert.NoError(t, err)
+	ps := pkt.(*PublishStream)
+	assert.Equal(t, ID(1), ps.Header.ID)
+	assert.Equal(t, QOS(1), ps.Header.Message.QOS)
+	assert.Equal(t, 1000, ps.Length)
+
+	payload, err := ioutil.ReadAll(ps.Payload)
+	assert.NoError(t, err)
+	assert.Equal(t, large.Message.Payload, payload)
+
+	// partially read payload
+	pkt, err = dec.Read()
+	assert.NoError(t, err)
+	ps = pkt.(*PublishStream)
+	_, err = ps.Payload.Read(make([]byte, 10))
+	assert.NoError(t, err)
+
+	// remaining payload is discarded
+	pkt, err = dec.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, puback, pkt)
+}
+
+func TestDecoderStreamingReadLimit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+
+	pp := NewPublish()
+	pp.Message.Topic = "foo"
+	pp.Message.Payload = bytes.Repeat([]byte("x"), 1000)
+
+	err := enc.Write(pp, false)
+	assert.NoError(t, err)
+
+	dec := NewDecoder(buf)
+	dec.SetReadLimit(500)
+	dec.SetStreamThreshold(100)
+
+	pkt, err := dec.Read()
+	assert.Equal(t, ErrReadLimitExceeded, err)
+	assert.Nil(t, pkt)
+}
+
+func TestDecoderStreamingErrors(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader([]byte{
+		byte(PUBLISH << 4), 10,
+		0, 20, // < topic longer than remaining length
+		'f', 'o', 'o', 'b', 'a', 'r', 'b', 'a',
+	}))
+	dec.SetStreamThreshold(1)
+
+	pkt, err := dec.Read()
+	assert.Error(t, err)
+	assert.Nil(t, pkt)
+
+	dec = NewDecoder(bytes.NewReader([]byte{
+		byte(PUBLISH << 4), 10,
+		0, 3, 'f', 'o', 'o',
+		'b', 'a', // < missing payload
+	}))
+	dec.SetStreamThreshold(1)
+
+	pkt, err = dec.Read()
+	assert.NoError(t, err)
+
+	_, err = ioutil.ReadAll(pkt.(*PublishStream).Payload)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	pkt, err = dec.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Nil(t, pkt)
+
+	dec = NewDecoder(bytes.NewReader([]byte{
+		byte(PUBLISH << 4), 10,
+		0, 3, 'f', '+', 'o',
+		'b', 'a', 'r', 'b', 'a',
+	}))
+	dec.SetStreamThreshold(1)
+	dec.SetStrictness(Strict)
+
+	pkt, err = dec.Read()
+	assert.Equal(t, "MQTT-3.3.2-2", err.(*ConformanceError).Statement)
+	assert.Nil(t, pkt)
+}
+
+func TestEncoderStreamError(t *testing.T) {
+	enc := NewEncoder(new(bytes.Buffer))
+
+	ps := NewPublishStream()
+	ps.Header.Message.Topic = "foo"
+	ps.Length = 10
+	ps.Payload = bytes.NewReader([]byte("bar"))
+
+	err := enc.Write(ps, false)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	ps.Header.Message.Topic = ""
+	err = enc.Write(ps, false)
+	assert.Error(t, err)
+}
+
 func TestStream(t *testing.T) {
 	in := new(bytes.Buffer)
 	out := new(bytes.Buffer)
@@ -164,3 +558,131 @@ func TestStream(t *testing.T) {
 	assert.NotNil(t, pkt)
 	assert.NoError(t, err)
 }
+
+func benchmarkDecoder(b *testing.B, pooled bool) {
+	pkt := NewPublish()
+	pkt.ID = 1
+	pkt.Message = Message{
+		Topic:   "foo/bar/baz",
+		Payload: make([]byte, 256),
+		QOS:     1,
+	}
+
+	buf := make([]byte, pkt.Len())
+	_, err := pkt.Encode(buf)
+	if err != nil {
+		panic(err)
+	}
+
+	dec := NewDecoder(&repeatReader{data: buf})
+	dec.SetPooling(pooled)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		pkt, err := dec.Read()
+		if err != nil {
+			panic(err)
+		}
+
+		pkt.(*Publish).Release()
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	benchmarkDecoder(b, false)
+}
+
+func BenchmarkDecoderPooled(b *testing.B) {
+	benchmarkDecoder(b, true)
+}
+
+func benchmarkEncoder(b *testing.B, payload int, async bool) {
+	pp := NewPublish()
+	pp.Message.Topic = "foo/bar/baz"
+	pp.Message.Payload = make([]byte, payload)
+
+	enc := NewEncoder(ioutil.Discard)
+	enc.SetMaxWriteDelay(time.Millisecond)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(pp.Len()))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := enc.Write(pp, async)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	benchmarkEncoder(b, 10, false)
+}
+
+func BenchmarkEncoderAsync(b *testing.B) {
+	benchmarkEncoder(b, 10, true)
+}
+
+func BenchmarkEncoderLarge(b *testing.B) {
+	benchmarkEncoder(b, 64<<10, false)
+}
+
+func BenchmarkEncoderVectored(b *testing.B) {
+	// write to a loopback connection to use vectored I/O
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	for _, payload := range []int{4 << 10, 64 << 10, 1 << 20} {
+		for _, vectored := range []bool{true, false} {
+			name := fmt.Sprintf("%dKB/copied", payload>>10)
+			if vectored {
+				name = fmt.Sprintf("%dKB/vectored", payload>>10)
+			}
+
+			b.Run(name, func(b *testing.B) {
+				pp := NewPublish()
+				pp.Message.Topic = "foo/bar/baz"
+				pp.Message.Payload = make([]byte, payload)
+
+				conn, err := net.Dial("tcp", listener.Addr().String())
+				if err != nil {
+					panic(err)
+				}
+				defer conn.Close()
+
+				enc := NewEncoder(conn)
+				if !vectored {
+					enc.SetVectoredThreshold(0)
+				}
+
+				b.ReportAllocs()
+				b.SetBytes(int64(pp.Len()))
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					err := enc.Write(pp, false)
+					if err != nil {
+						panic(err)
+					}
+				}
+			})
+		}
+	}
+}
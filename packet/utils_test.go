@@ -1,6 +1,11 @@
 package packet
 
-import "io"
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
 
 type errorWriter struct {
 	writer io.Writer
@@ -30,3 +35,50 @@ func (r *errorReader) Read(p []byte) (int, error) {
 	r.after--
 	return r.reader.Read(p)
 }
+
+type repeatReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.pos:])
+		r.pos = (r.pos + c) % len(r.data)
+		n += c
+	}
+
+	return n, nil
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	mutex  sync.Mutex
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func (w *countingWriter) Writes() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writes
+}
+
+func encode(t *testing.T, pkt Generic) []byte {
+	buf := make([]byte, pkt.Len())
+	_, err := pkt.Encode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf
+}
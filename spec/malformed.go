@@ -0,0 +1,71 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/256dpi/gomqtt/transport/flow"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a packet that is encoded as the provided bytes
+type rawPacket []byte
+
+func (p rawPacket) Type() packet.Type {
+	return packet.Type(p[0] >> 4)
+}
+
+func (p rawPacket) Len() int {
+	return len(p)
+}
+
+func (p rawPacket) Decode([]byte) (int, error) {
+	return 0, errors.New("not supported")
+}
+
+func (p rawPacket) Encode(dst []byte) (int, error) {
+	return copy(dst, p), nil
+}
+
+func (p rawPacket) String() string {
+	return fmt.Sprintf("<Raw Data=%x>", []byte(p))
+}
+
+// returns an encoded qos 0 publish packet with the provided topic
+func rawPublish(topic string) rawPacket {
+	// prepare variable header
+	body := []byte{byte(len(topic) >> 8), byte(len(topic))}
+	body = append(body, topic...)
+	body = append(body, testPayload...)
+
+	return append(rawPacket{0x30, byte(len(body))}, body...)
+}
+
+// MalformedPacketTest tests the broker for closing the connection when
+// receiving a packet that violates the specification.
+func MalformedPacketTest(t *testing.T, config *Config, pkt packet.Generic) {
+	username, password := config.usernamePassword()
+
+	connect := packet.NewConnect()
+	connect.Username = username
+	connect.Password = password
+
+	connack := packet.NewConnack()
+
+	c := flow.New().
+		Send(connect).
+		Receive(connack).
+		Send(pkt).
+		End()
+
+	conn, err := transport.Dial(config.URL)
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+
+	err = c.Test(conn)
+	assert.NoError(t, err)
+}
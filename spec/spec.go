@@ -24,6 +24,11 @@ type Config struct {
 	UniqueClientIDs      bool
 	RootSlashDistinction bool
 
+	// MalformedPackets enables tests that expect the broker to close the
+	// connection of clients that send malformed packets. It requires a strict
+	// broker and is therefore not enabled by AllFeatures.
+	MalformedPackets bool
+
 	// ProcessWait defines the time some tests should wait and let the broker
 	// finish processing (e.g. properly terminating a connection)
 	ProcessWait time.Duration
@@ -39,7 +44,8 @@ type Config struct {
 	counter int
 }
 
-// AllFeatures returns a config that enables all features.
+// AllFeatures returns a config that enables all features except the
+// malformed packet tests.
 func AllFeatures() *Config {
 	return &Config{
 		RetainedMessages:     true,
@@ -349,4 +355,26 @@ func Run(t *testing.T, config *Config) {
 			RootSlashDistinctionTest(t, config, "rootslash")
 		})
 	}
+
+	if config.MalformedPackets {
+		t.Run("MalformedInvalidUTF8", func(t *testing.T) {
+			MalformedPacketTest(t, config, rawPublish("malformed/\xc3\x28"))
+		})
+
+		t.Run("MalformedNullCharacter", func(t *testing.T) {
+			MalformedPacketTest(t, config, rawPublish("malformed/\x00"))
+		})
+
+		t.Run("MalformedWildcardTopic", func(t *testing.T) {
+			MalformedPacketTest(t, config, rawPublish("malformed/+"))
+		})
+
+		t.Run("MalformedZeroLengthTopic", func(t *testing.T) {
+			MalformedPacketTest(t, config, rawPublish(""))
+		})
+
+		t.Run("MalformedReservedFlags", func(t *testing.T) {
+			MalformedPacketTest(t, config, rawPacket{0xc1, 0x00})
+		})
+	}
 }
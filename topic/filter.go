@@ -0,0 +1,217 @@
+package topic
+
+import "strings"
+
+// Canonical parses the supplied filter using Parse and returns its canonical
+// form. In addition to Parse, filters that are equivalent to a single
+// multi-level wildcard e.g. "+/#" are reduced to "#". Filters that match the
+// same set of topics will therefore yield the same canonical form.
+func Canonical(filter string) (string, error) {
+	// parse filter
+	filter, err := Parse(filter, true)
+	if err != nil {
+		return "", err
+	}
+
+	return reduce(filter), nil
+}
+
+// Reserved returns whether the supplied topic or filter begins with a "$"
+// character. Topics beginning with a "$" are reserved for server specific
+// purposes and are not matched by filters that begin with a wildcard.
+func Reserved(topic string) bool {
+	return strings.HasPrefix(topic, "$")
+}
+
+// Matches returns whether the supplied filter matches the supplied topic. In
+// addition to the semantics of Tree, filters that begin with a wildcard will
+// not match reserved topics.
+//
+// Note: The filter and topic are expected to be tested and normalized using
+// Parse beforehand.
+func Matches(filter, topic string) bool {
+	// check reserved topics
+	if Reserved(topic) && startsWithWildcard(filter) {
+		return false
+	}
+
+	return matches(strings.Split(filter, "/"), strings.Split(topic, "/"))
+}
+
+func matches(filter, topic []string) bool {
+	for i, segment := range filter {
+		// multi-level wildcards match the parent and all descendants
+		if segment == "#" {
+			return true
+		}
+
+		// check remaining topic
+		if i >= len(topic) {
+			return false
+		}
+
+		// check segment
+		if segment != "+" && segment != topic[i] {
+			return false
+		}
+	}
+
+	return len(filter) == len(topic)
+}
+
+// Covers returns whether filter a matches all topics that are matched by
+// filter b.
+//
+// Note: The filters are expected to be tested and normalized using Parse
+// beforehand.
+func Covers(a, b string) bool {
+	// reduce filters
+	a, b = reduce(a), reduce(b)
+
+	// check reserved filters
+	if startsWithWildcard(a) && !startsWithWildcard(b) && Reserved(b) {
+		return false
+	}
+
+	return covers(strings.Split(a, "/"), strings.Split(b, "/"))
+}
+
+func covers(a, b []string) bool {
+	for i, segment := range a {
+		// multi-level wildcards cover the parent and all descendants
+		if segment == "#" {
+			return true
+		}
+
+		// check remaining filter
+		if i >= len(b) || b[i] == "#" {
+			return false
+		}
+
+		// single-level wildcards cover any segment
+		if segment == "+" {
+			continue
+		}
+
+		// check segment
+		if segment != b[i] {
+			return false
+		}
+	}
+
+	return len(a) == len(b)
+}
+
+// Intersects returns whether there is at least one topic that is matched by
+// both filters.
+//
+// Note: The filters are expected to be tested and normalized using Parse
+// beforehand.
+func Intersects(a, b string) bool {
+	_, ok := Intersection(a, b)
+	return ok
+}
+
+// Intersection returns the filter that matches exactly the topics that are
+// matched by both filters. The second return value is false if the filters do
+// not intersect.
+//
+// Note: The filters are expected to be tested and normalized using Parse
+// beforehand.
+func Intersection(a, b string) (string, bool) {
+	// reduce filters
+	a, b = reduce(a), reduce(b)
+
+	// check reserved filters
+	if (startsWithWildcard(a) && !startsWithWildcard(b) && Reserved(b)) ||
+		(startsWithWildcard(b) && !startsWithWildcard(a) && Reserved(a)) {
+		return "", false
+	}
+
+	// intersect segments
+	segments, ok := intersection(strings.Split(a, "/"), strings.Split(b, "/"))
+	if !ok {
+		return "", false
+	}
+
+	return reduce(strings.Join(segments, "/")), true
+}
+
+func intersection(a, b []string) ([]string, bool) {
+	// prepare result
+	result := make([]string, 0, len(a)+len(b))
+
+	for i := 0; ; i++ {
+		// multi-level wildcards intersect with the remaining other filter
+		if i < len(a) && a[i] == "#" {
+			return append(result, b[i:]...), true
+		} else if i < len(b) && b[i] == "#" {
+			return append(result, a[i:]...), true
+		}
+
+		// check if both filters are finished
+		if i >= len(a) || i >= len(b) {
+			return result, len(a) == len(b)
+		}
+
+		// intersect segments
+		switch {
+		case a[i] == "+":
+			result = append(result, b[i])
+		case b[i] == "+" || a[i] == b[i]:
+			result = append(result, a[i])
+		default:
+			return nil, false
+		}
+	}
+}
+
+func reduce(filter string) string {
+	// a wildcard followed by a multi-level wildcard at the root matches the
+	// same topics as a single multi-level wildcard
+	if filter == "+/#" {
+		return "#"
+	}
+
+	return filter
+}
+
+func startsWithWildcard(filter string) bool {
+	return strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")
+}
+
+// MoreSpecific returns whether filter a is more specific than filter b. The
+// filters are compared level by level and the first level that differs in its
+// kind decides. Literal levels are more specific than single-level wildcards,
+// which are more specific than the end of a filter, which in turn is more
+// specific than a multi-level wildcard. Filters of the same shape are ordered
+// lexically to yield a stable result.
+//
+// The function can be used to select the most specific filter among a set of
+// filters that match the same topic.
+func MoreSpecific(a, b string) bool {
+	// split filters
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+
+	// compare levels
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if ra, rb := rank(as, i), rank(bs, i); ra != rb {
+			return ra > rb
+		}
+	}
+
+	return a < b
+}
+
+func rank(levels []string, i int) int {
+	switch {
+	case i >= len(levels):
+		return 1
+	case levels[i] == "#":
+		return 0
+	case levels[i] == "+":
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,251 @@
+package topic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := map[string]string{
+		"foo/bar":   "foo/bar",
+		"foo//bar/": "foo/bar",
+		"foo/+/#":   "foo/+/#",
+		"+/#":       "#",
+		"+//#/":     "#",
+		"+/+/#":     "+/+/#",
+		"/#":        "/#",
+	}
+
+	for filter, result := range tests {
+		str, err := Canonical(filter)
+		assert.NoError(t, err, filter)
+		assert.Equal(t, result, str, filter)
+	}
+
+	_, err := Canonical("")
+	assert.Equal(t, ErrZeroLength, err)
+
+	_, err = Canonical("foo/#/bar")
+	assert.Equal(t, ErrWildcards, err)
+}
+
+func TestReserved(t *testing.T) {
+	assert.True(t, Reserved("$SYS/foo"))
+	assert.True(t, Reserved("$"))
+	assert.False(t, Reserved("foo/$bar"))
+	assert.False(t, Reserved("#"))
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		result bool
+	}{
+		{"foo/bar", "foo/bar", true},
+		{"foo/bar", "foo/baz", false},
+		{"foo/+", "foo/bar", true},
+		{"foo/+", "foo", false},
+		{"foo/+", "foo/bar/baz", false},
+		{"foo/#", "foo", true},
+		{"foo/#", "foo/bar/baz", true},
+		{"foo/+/#", "foo/bar", true},
+		{"+/+", "/foo", true},
+		{"#", "foo", true},
+		{"#", "$SYS/foo", false},
+		{"+/foo", "$SYS/foo", false},
+		{"$SYS/#", "$SYS/foo", true},
+		{"$SYS/+", "$SYS/foo", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.result, Matches(test.filter, test.topic), test)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		a      string
+		b      string
+		result bool
+	}{
+		{"foo/bar", "foo/bar", true},
+		{"foo/bar", "foo/baz", false},
+		{"foo/+", "foo/bar", true},
+		{"foo/bar", "foo/+", false},
+		{"foo/#", "foo", true},
+		{"foo/#", "foo/+/bar", true},
+		{"foo/+", "foo/#", false},
+		{"foo/+/#", "foo/#", false},
+		{"+/#", "#", true},
+		{"#", "+/#", true},
+		{"+", "#", false},
+		{"#", "$SYS/foo", false},
+		{"+/foo", "$SYS/foo", false},
+		{"$SYS/#", "$SYS/foo", true},
+		{"$SYS/foo", "#", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.result, Covers(test.a, test.b), test)
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	tests := []struct {
+		a      string
+		b      string
+		result string
+		ok     bool
+	}{
+		{"foo/bar", "foo/bar", "foo/bar", true},
+		{"foo/bar", "foo/baz", "", false},
+		{"foo/+", "foo/bar", "foo/bar", true},
+		{"foo/+", "+/bar", "foo/bar", true},
+		{"foo/#", "foo", "foo", true},
+		{"foo/#", "+/+/#", "foo/+/#", true},
+		{"foo/+", "foo", "", false},
+		{"foo/+", "foo/bar/baz", "", false},
+		{"#", "+/#", "#", true},
+		{"+/#", "+/#", "#", true},
+		{"#", "$SYS/foo", "", false},
+		{"$SYS/+", "+/foo", "", false},
+		{"$SYS/+", "$SYS/#", "$SYS/+", true},
+	}
+
+	for _, test := range tests {
+		result, ok := Intersection(test.a, test.b)
+		assert.Equal(t, test.result, result, test)
+		assert.Equal(t, test.ok, ok, test)
+		assert.Equal(t, test.ok, Intersects(test.a, test.b), test)
+
+		result, ok = Intersection(test.b, test.a)
+		assert.Equal(t, test.result, result, test)
+		assert.Equal(t, test.ok, ok, test)
+	}
+}
+
+func TestFilterAlgebraAgainstTree(t *testing.T) {
+	// generate filters
+	filters := []string{"#"}
+	for _, filter := range combinations([]string{"a", "b", "+"}, 3) {
+		filters = append(filters, filter, filter+"/#", "$a/"+filter)
+	}
+
+	// generate topics
+	topics := combinations([]string{"a", "b", "c"}, 4)
+	for _, topic := range combinations([]string{"a", "b", "c"}, 3) {
+		topics = append(topics, "$a/"+topic)
+	}
+
+	// compute matches using tree semantics and reserved topic rules
+	matches := make(map[string]map[string]bool)
+	for _, filter := range filters {
+		tree := NewStandardTree()
+		tree.Add(filter, 1)
+
+		matches[filter] = make(map[string]bool)
+		for _, topic := range topics {
+			ok := tree.MatchFirst(topic) != nil
+			if Reserved(topic) && strings.IndexAny(filter[:1], "+#") == 0 {
+				ok = false
+			}
+
+			matches[filter][topic] = ok
+			assert.Equal(t, ok, Matches(filter, topic), "%s %s", filter, topic)
+		}
+	}
+
+	for _, a := range filters {
+		for _, b := range filters {
+			// compute expected relations
+			covers := true
+			intersects := false
+			for _, topic := range topics {
+				if matches[b][topic] && !matches[a][topic] {
+					covers = false
+				}
+				if matches[a][topic] && matches[b][topic] {
+					intersects = true
+				}
+			}
+
+			assert.Equal(t, covers, Covers(a, b), "%s %s", a, b)
+			assert.Equal(t, intersects, Intersects(a, b), "%s %s", a, b)
+
+			// check intersection
+			result, ok := Intersection(a, b)
+			if ok {
+				for _, topic := range topics {
+					assert.Equal(t, matches[a][topic] && matches[b][topic], Matches(result, topic), "%s %s %s", a, b, topic)
+				}
+			}
+		}
+	}
+}
+
+func combinations(segments []string, depth int) []string {
+	// prepare list
+	list := make([]string, 0)
+
+	// add single segments
+	last := append([]string{}, segments...)
+	list = append(list, last...)
+
+	// add deeper combinations
+	for i := 1; i < depth; i++ {
+		var next []string
+		for _, prefix := range last {
+			for _, segment := range segments {
+				next = append(next, prefix+"/"+segment)
+			}
+		}
+
+		list = append(list, next...)
+		last = next
+	}
+
+	return list
+}
+
+func BenchmarkCovers(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		Covers("foo/+/baz/#", "foo/bar/baz/qux")
+	}
+}
+
+func BenchmarkIntersection(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		Intersection("foo/+/baz/#", "+/bar/+/qux")
+	}
+}
+
+func TestMoreSpecific(t *testing.T) {
+	tests := []struct {
+		a      string
+		b      string
+		result bool
+	}{
+		{"foo/bar", "foo/+", true},
+		{"foo/+", "foo/#", true},
+		{"foo", "foo/#", true},
+		{"sensors/+/temp", "#", true},
+		{"sensors/#", "+/+/temp", true},
+		{"+/bar", "foo/+", false},
+		{"foo/bar", "foo/bar", false},
+		{"foo/bar", "foo/baz", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.result, MoreSpecific(test.a, test.b), "%s %s", test.a, test.b)
+		if test.a != test.b {
+			assert.Equal(t, !test.result, MoreSpecific(test.b, test.a), "%s %s", test.b, test.a)
+		}
+	}
+}
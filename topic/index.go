@@ -0,0 +1,182 @@
+package topic
+
+import "sync"
+
+type subscription struct {
+	subscriber interface{}
+	qos        byte
+}
+
+// An Index maps topic filters to subscribers and their QOS levels. In contrast
+// to a tree per subscriber, a single index can match a topic against the
+// filters of all subscribers in one pass.
+//
+// The index uses a SnapshotTree internally and matching will therefore not
+// block on concurrent updates. Subscribers are used as map keys and must be
+// comparable.
+type Index struct {
+	tree          *SnapshotTree
+	subscriptions map[interface{}]map[string]*subscription
+	mutex         sync.Mutex
+}
+
+// NewIndex returns a new Index using the specified separator and wildcards.
+func NewIndex(separator, wildcardOne, wildcardSome string) *Index {
+	return &Index{
+		tree:          NewSnapshotTree(separator, wildcardOne, wildcardSome),
+		subscriptions: make(map[interface{}]map[string]*subscription),
+	}
+}
+
+// NewStandardIndex returns a new Index using the standard MQTT separator and
+// wildcards.
+func NewStandardIndex() *Index {
+	return NewIndex("/", "+", "#")
+}
+
+// Add registers the subscriber for the supplied filter using the specified QOS
+// level. An existing subscription of the subscriber for the same filter will be
+// replaced.
+func (i *Index) Add(filter string, subscriber interface{}, qos byte) {
+	// acquire mutex
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// get subscriptions
+	subs, ok := i.subscriptions[subscriber]
+	if !ok {
+		subs = make(map[string]*subscription)
+		i.subscriptions[subscriber] = subs
+	}
+
+	// check existing subscription
+	existing := subs[filter]
+	if existing != nil && existing.qos == qos {
+		return
+	}
+
+	// prepare subscription
+	sub := &subscription{
+		subscriber: subscriber,
+		qos:        qos,
+	}
+
+	// replace subscription in a single update
+	i.tree.update(func(root *node) *node {
+		// remove existing subscription
+		if existing != nil {
+			root = i.tree.remove(existing, filter, root)
+			if root == nil {
+				root = newNode()
+			}
+		}
+
+		return i.tree.add(sub, filter, root)
+	})
+
+	// save subscription
+	subs[filter] = sub
+}
+
+// Get returns the QOS level of the subscribers subscription for the supplied
+// filter and whether the subscription exists.
+func (i *Index) Get(filter string, subscriber interface{}) (byte, bool) {
+	// acquire mutex
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// get subscription
+	sub, ok := i.subscriptions[subscriber][filter]
+	if !ok {
+		return 0, false
+	}
+
+	return sub.qos, true
+}
+
+// Remove un-registers the subscriber from the supplied filter. It returns
+// whether a subscription has been removed.
+func (i *Index) Remove(filter string, subscriber interface{}) bool {
+	// acquire mutex
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// get subscription
+	subs := i.subscriptions[subscriber]
+	sub, ok := subs[filter]
+	if !ok {
+		return false
+	}
+
+	// remove subscription
+	i.tree.Remove(filter, sub)
+	delete(subs, filter)
+
+	// remove subscriber if empty
+	if len(subs) == 0 {
+		delete(i.subscriptions, subscriber)
+	}
+
+	return true
+}
+
+// Clear will un-register the subscriber from all filters.
+func (i *Index) Clear(subscriber interface{}) {
+	// acquire mutex
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// get subscriptions
+	subs, ok := i.subscriptions[subscriber]
+	if !ok {
+		return
+	}
+
+	// remove all subscriptions in a single update
+	i.tree.update(func(root *node) *node {
+		for filter, sub := range subs {
+			root = i.tree.remove(sub, filter, root)
+			if root == nil {
+				root = newNode()
+			}
+		}
+
+		return root
+	})
+
+	// remove subscriber
+	delete(i.subscriptions, subscriber)
+}
+
+// Match returns all subscribers that have a filter matching the supplied topic.
+// If multiple filters of a subscriber overlap, the maximum QOS level of the
+// matching subscriptions is returned.
+func (i *Index) Match(topic string) map[interface{}]byte {
+	// prepare result
+	var result map[interface{}]byte
+
+	// match subscriptions
+	i.tree.match(topic, i.tree.load(), func(values []interface{}) bool {
+		// prepare map
+		if result == nil {
+			result = make(map[interface{}]byte, len(values))
+		}
+
+		// merge subscriptions
+		for _, value := range values {
+			sub := value.(*subscription)
+			if qos, ok := result[sub.subscriber]; !ok || sub.qos > qos {
+				result[sub.subscriber] = sub.qos
+			}
+		}
+
+		return true
+	})
+
+	return result
+}
+
+// Count returns the number of stored subscriptions.
+func (i *Index) Count() int {
+	return i.tree.Count()
+}
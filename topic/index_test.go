@@ -0,0 +1,146 @@
+package topic
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexAdd(t *testing.T) {
+	index := NewStandardIndex()
+
+	index.Add("foo/bar", "a", 1)
+	index.Add("foo/bar", "a", 1)
+	index.Add("foo/bar", "b", 0)
+
+	assert.Equal(t, 2, index.Count())
+
+	qos, ok := index.Get("foo/bar", "a")
+	assert.True(t, ok)
+	assert.Equal(t, byte(1), qos)
+
+	index.Add("foo/bar", "a", 2)
+	assert.Equal(t, 2, index.Count())
+
+	qos, ok = index.Get("foo/bar", "a")
+	assert.True(t, ok)
+	assert.Equal(t, byte(2), qos)
+
+	_, ok = index.Get("foo/baz", "a")
+	assert.False(t, ok)
+}
+
+func TestIndexRemove(t *testing.T) {
+	index := NewStandardIndex()
+
+	index.Add("foo/bar", "a", 1)
+	index.Add("foo/+", "a", 1)
+	index.Add("foo/bar", "b", 1)
+
+	assert.False(t, index.Remove("foo/baz", "a"))
+	assert.False(t, index.Remove("foo/bar", "c"))
+	assert.True(t, index.Remove("foo/bar", "a"))
+	assert.Equal(t, 2, index.Count())
+
+	assert.Equal(t, map[interface{}]byte{"a": 1, "b": 1}, index.Match("foo/bar"))
+
+	assert.True(t, index.Remove("foo/+", "a"))
+	assert.True(t, index.Remove("foo/bar", "b"))
+	assert.Equal(t, 0, index.Count())
+	assert.Empty(t, index.subscriptions)
+	assert.Empty(t, index.tree.load().children)
+}
+
+func TestIndexClear(t *testing.T) {
+	index := NewStandardIndex()
+
+	index.Add("foo/bar", "a", 1)
+	index.Add("foo/#", "a", 1)
+	index.Add("bar", "a", 0)
+	index.Add("foo/bar", "b", 2)
+	index.Clear("a")
+	index.Clear("c")
+
+	assert.Equal(t, 1, index.Count())
+	assert.Equal(t, map[interface{}]byte{"b": 2}, index.Match("foo/bar"))
+	assert.Nil(t, index.Match("bar"))
+
+	index.Clear("b")
+	assert.Equal(t, 0, index.Count())
+	assert.Empty(t, index.tree.load().children)
+}
+
+func TestIndexMatch(t *testing.T) {
+	index := NewStandardIndex()
+
+	index.Add("foo/bar", "a", 0)
+	index.Add("foo/+", "a", 2)
+	index.Add("foo/#", "a", 1)
+	index.Add("foo/#", "b", 1)
+	index.Add("#", "b", 0)
+	index.Add("bar", "c", 2)
+
+	assert.Equal(t, map[interface{}]byte{"a": 2, "b": 1}, index.Match("foo/bar"))
+	assert.Equal(t, map[interface{}]byte{"a": 1, "b": 1}, index.Match("foo/bar/baz"))
+	assert.Equal(t, map[interface{}]byte{"b": 0, "c": 2}, index.Match("bar"))
+	assert.Equal(t, map[interface{}]byte{"b": 0}, index.Match("baz"))
+
+	index.Clear("b")
+	assert.Nil(t, index.Match("baz"))
+}
+
+func TestIndexConcurrency(t *testing.T) {
+	index := NewStandardIndex()
+	index.Add("foo/#", "main", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 500; j++ {
+				filter := fmt.Sprintf("foo/%d", j%10)
+				index.Add(filter, i, byte(j%3))
+				assert.Contains(t, index.Match(filter), "main")
+				index.Remove(filter, i)
+			}
+
+			index.Clear(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 1, index.Count())
+	assert.Equal(t, map[interface{}]byte{"main": 0}, index.Match("foo/bar"))
+}
+
+func BenchmarkIndexMatch(b *testing.B) {
+	index := NewStandardIndex()
+
+	for i := 0; i < 1000; i++ {
+		index.Add(fmt.Sprintf("devices/%d/+", i), i, 1)
+		index.Add("devices/#", i, 0)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		index.Match("devices/42/temp")
+	}
+}
+
+func BenchmarkIndexAdd(b *testing.B) {
+	index := NewStandardIndex()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		index.Add(fmt.Sprintf("devices/%d/+", i%1000), i%100, byte(i%3))
+	}
+}
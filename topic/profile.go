@@ -0,0 +1,112 @@
+package topic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooLong is returned by Profile.Parse if a topic exceeds the maximum length.
+var ErrTooLong = errors.New("topic too long")
+
+// ErrTooManyLevels is returned by Profile.Parse if a topic exceeds the maximum
+// number of levels.
+var ErrTooManyLevels = errors.New("too many topic levels")
+
+// ErrInvalidCharacter is returned by Profile.Parse if a topic contains a
+// character that is not allowed.
+var ErrInvalidCharacter = errors.New("invalid character")
+
+// ErrReservedPrefix is returned by Profile.Parse if a topic begins with a
+// reserved prefix.
+var ErrReservedPrefix = errors.New("reserved prefix")
+
+// ErrEmptyLevel is returned by Profile.Parse if a topic contains an empty level.
+var ErrEmptyLevel = errors.New("empty topic level")
+
+// A Profile defines additional rules that are enforced when parsing topics and
+// filters. The zero value and a nil profile enforce the same rules as Parse.
+type Profile struct {
+	// MaxLength limits the length of topics and filters in bytes.
+	MaxLength int
+
+	// MaxLevels limits the number of levels of topics and filters.
+	MaxLevels int
+
+	// ValidRune can be set to restrict the characters that are allowed in
+	// topic levels. Separators and wildcards are always allowed.
+	ValidRune func(rune) bool
+
+	// ReservedPrefixes lists prefixes e.g. "$SYS" that topics may not begin
+	// with. Filters are not checked to still allow subscribing to reserved
+	// topics.
+	ReservedPrefixes []string
+
+	// RejectEmptyLevels will reject topics and filters with empty levels
+	// instead of collapsing them.
+	RejectEmptyLevels bool
+}
+
+// Parse will check the supplied topic or filter using Parse and the rules of
+// the profile and returns the normalized topic. Wildcards are only allowed if
+// allowWildcards is true, while reserved prefixes are only checked if it is
+// false.
+func (p *Profile) Parse(topic string, allowWildcards bool) (string, error) {
+	// use default rules if missing
+	if p == nil {
+		return Parse(topic, allowWildcards)
+	}
+
+	// check length
+	if p.MaxLength > 0 && len(topic) > p.MaxLength {
+		return "", fmt.Errorf("%w: %d bytes exceed maximum of %d", ErrTooLong, len(topic), p.MaxLength)
+	}
+
+	// check empty levels
+	if p.RejectEmptyLevels && topic != "" {
+		for i, level := range strings.Split(topic, "/") {
+			if level == "" {
+				return "", fmt.Errorf("%w: level %d of %q", ErrEmptyLevel, i+1, topic)
+			}
+		}
+	}
+
+	// parse topic
+	topic, err := Parse(topic, allowWildcards)
+	if err != nil {
+		return "", err
+	}
+
+	// check levels
+	if p.MaxLevels > 0 {
+		if levels := strings.Count(topic, "/") + 1; levels > p.MaxLevels {
+			return "", fmt.Errorf("%w: %d levels exceed maximum of %d", ErrTooManyLevels, levels, p.MaxLevels)
+		}
+	}
+
+	// check characters
+	if p.ValidRune != nil {
+		for _, r := range topic {
+			if r != '/' && r != '+' && r != '#' && !p.ValidRune(r) {
+				return "", fmt.Errorf("%w: %q in %q", ErrInvalidCharacter, r, topic)
+			}
+		}
+	}
+
+	// check reserved prefixes
+	if !allowWildcards {
+		for _, prefix := range p.ReservedPrefixes {
+			if strings.HasPrefix(topic, prefix) {
+				return "", fmt.Errorf("%w: %q begins with %q", ErrReservedPrefix, topic, prefix)
+			}
+		}
+	}
+
+	return topic, nil
+}
+
+// PrintableASCII returns whether the supplied rune is a printable ASCII
+// character. It can be used as a Profile.ValidRune function.
+func PrintableASCII(r rune) bool {
+	return r >= 0x20 && r <= 0x7E
+}
@@ -0,0 +1,100 @@
+package topic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileParseNil(t *testing.T) {
+	var profile *Profile
+
+	str, err := profile.Parse("foo//bar/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", str)
+
+	_, err = profile.Parse("foo/#", false)
+	assert.Equal(t, ErrWildcards, err)
+}
+
+func TestProfileParse(t *testing.T) {
+	profile := &Profile{
+		MaxLength:         16,
+		MaxLevels:         3,
+		ValidRune:         PrintableASCII,
+		ReservedPrefixes:  []string{"$SYS"},
+		RejectEmptyLevels: true,
+	}
+
+	tests := []struct {
+		topic     string
+		wildcards bool
+		err       error
+		message   string
+	}{
+		{"foo/bar", false, nil, ""},
+		{"foo/+/#", true, nil, ""},
+		{"$SYS/#", true, nil, ""},
+		{"", false, ErrZeroLength, "zero length topic"},
+		{"foo/#", false, ErrWildcards, "invalid use of wildcards"},
+		{"foo/bar/baz/qux/quux", false, ErrTooLong, "topic too long: 20 bytes exceed maximum of 16"},
+		{"foo//bar", false, ErrEmptyLevel, `empty topic level: level 2 of "foo//bar"`},
+		{"/foo", true, ErrEmptyLevel, `empty topic level: level 1 of "/foo"`},
+		{"foo/", false, ErrEmptyLevel, `empty topic level: level 2 of "foo/"`},
+		{"a/b/c/d", false, ErrTooManyLevels, "too many topic levels: 4 levels exceed maximum of 3"},
+		{"foo/bär", false, ErrInvalidCharacter, `invalid character: 'ä' in "foo/bär"`},
+		{"foo/\x00", false, ErrInvalidCharacter, `invalid character: '\x00' in "foo/\x00"`},
+		{"$SYS/foo", false, ErrReservedPrefix, `reserved prefix: "$SYS/foo" begins with "$SYS"`},
+	}
+
+	for _, test := range tests {
+		str, err := profile.Parse(test.topic, test.wildcards)
+		if test.err == nil {
+			assert.NoError(t, err, test.topic)
+			assert.Equal(t, test.topic, str)
+		} else {
+			assert.True(t, errors.Is(err, test.err), test.topic)
+			assert.EqualError(t, err, test.message, test.topic)
+			assert.Empty(t, str)
+		}
+	}
+}
+
+func TestProfileParseCollapse(t *testing.T) {
+	profile := &Profile{
+		MaxLevels: 2,
+	}
+
+	str, err := profile.Parse("foo//bar/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", str)
+}
+
+func TestPrintableASCII(t *testing.T) {
+	assert.True(t, PrintableASCII('a'))
+	assert.True(t, PrintableASCII(' '))
+	assert.True(t, PrintableASCII('~'))
+	assert.False(t, PrintableASCII('\n'))
+	assert.False(t, PrintableASCII(0x7F))
+	assert.False(t, PrintableASCII('ä'))
+}
+
+func BenchmarkProfileParse(b *testing.B) {
+	profile := &Profile{
+		MaxLength:         256,
+		MaxLevels:         8,
+		ValidRune:         PrintableASCII,
+		ReservedPrefixes:  []string{"$SYS"},
+		RejectEmptyLevels: true,
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, err := profile.Parse("foo/bar/baz", false)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package topic
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// returns a shallow copy of the node that can be modified
+func (n *node) copy() *node {
+	// copy children
+	children := make(map[string]*node, len(n.children))
+	for segment, child := range n.children {
+		children[segment] = child
+	}
+
+	// copy values
+	var values []interface{}
+	if len(n.values) > 0 {
+		values = append(make([]interface{}, 0, len(n.values)+1), n.values...)
+	}
+
+	return &node{
+		children: children,
+		values:   values,
+	}
+}
+
+// returns whether the node has no values and no children
+func (n *node) empty() bool {
+	return len(n.values) == 0 && len(n.children) == 0
+}
+
+// A SnapshotTree implements a thread-safe topic tree with the same API as
+// Tree. In contrast to Tree, reads do not acquire any locks. Instead, every
+// write copies the modified path and atomically publishes a new immutable
+// snapshot of the tree. Reads operate on the snapshot that is current when
+// they start and never block writes.
+//
+// The tree is therefore well suited for workloads where reads vastly
+// outnumber writes, e.g. matching messages against subscriptions. Writes are
+// serialized and more expensive than with Tree as they allocate a copy of
+// every node on the modified path.
+//
+// Note: Slices returned by Get share memory with the snapshot and must not be
+// modified.
+type SnapshotTree struct {
+	syntax
+	root  atomic.Value
+	mutex sync.Mutex
+}
+
+// NewSnapshotTree returns a new SnapshotTree using the specified separator and
+// wildcards.
+func NewSnapshotTree(separator, wildcardOne, wildcardSome string) *SnapshotTree {
+	// prepare tree
+	t := &SnapshotTree{
+		syntax: syntax{
+			separator:    separator,
+			wildcardOne:  wildcardOne,
+			wildcardSome: wildcardSome,
+		},
+	}
+
+	// set root
+	t.root.Store(newNode())
+
+	return t
+}
+
+// NewStandardSnapshotTree returns a new SnapshotTree using the standard MQTT
+// separator and wildcards.
+func NewStandardSnapshotTree() *SnapshotTree {
+	return NewSnapshotTree("/", "+", "#")
+}
+
+func (t *SnapshotTree) load() *node {
+	return t.root.Load().(*node)
+}
+
+// updates the tree using the provided function
+func (t *SnapshotTree) update(fn func(root *node) *node) {
+	// acquire mutex
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get current root
+	root := t.load()
+
+	// compute new root
+	newRoot := fn(root)
+	if newRoot == nil {
+		newRoot = newNode()
+	}
+
+	// publish new root if changed
+	if newRoot != root {
+		t.root.Store(newRoot)
+	}
+}
+
+// Add registers the value for the supplied topic. This function will
+// automatically grow the tree. If value already exists for the given topic it
+// will not be added again.
+func (t *SnapshotTree) Add(topic string, value interface{}) {
+	t.update(func(root *node) *node {
+		return t.add(value, topic, root)
+	})
+}
+
+func (t *SnapshotTree) add(value interface{}, topic string, n *node) *node {
+	// add value to leaf
+	if topic == topicEnd {
+		// check if duplicate
+		if contains(n.values, value) {
+			return n
+		}
+
+		// add value
+		c := n.copy()
+		c.values = append(c.values, value)
+
+		return c
+	}
+
+	// get segment
+	segment := topicSegment(topic, t.separator)
+
+	// get child
+	child, ok := n.children[segment]
+	if !ok {
+		child = newNode()
+	}
+
+	// descend
+	newChild := t.add(value, topicShorten(topic, t.separator), child)
+	if newChild == child && ok {
+		return n
+	}
+
+	// replace child
+	c := n.copy()
+	c.children[segment] = newChild
+
+	return c
+}
+
+// Set sets the supplied value as the only value for the supplied topic. This
+// function will automatically grow the tree.
+func (t *SnapshotTree) Set(topic string, value interface{}) {
+	t.update(func(root *node) *node {
+		return t.set(value, topic, root)
+	})
+}
+
+func (t *SnapshotTree) set(value interface{}, topic string, n *node) *node {
+	// set value on leaf
+	if topic == topicEnd {
+		c := n.copy()
+		c.values = []interface{}{value}
+		return c
+	}
+
+	// get segment
+	segment := topicSegment(topic, t.separator)
+
+	// get child
+	child, ok := n.children[segment]
+	if !ok {
+		child = newNode()
+	}
+
+	// descend and replace child
+	c := n.copy()
+	c.children[segment] = t.set(value, topicShorten(topic, t.separator), child)
+
+	return c
+}
+
+// Get gets the values from the topic that exactly matches the supplied topics.
+func (t *SnapshotTree) Get(topic string) []interface{} {
+	return t.get(topic, t.load())
+}
+
+// Remove un-registers the value from the supplied topic. This function will
+// automatically shrink the tree.
+func (t *SnapshotTree) Remove(topic string, value interface{}) {
+	t.update(func(root *node) *node {
+		return t.remove(value, topic, root)
+	})
+}
+
+// Empty will unregister all values from the supplied topic. This function will
+// automatically shrink the tree.
+func (t *SnapshotTree) Empty(topic string) {
+	t.update(func(root *node) *node {
+		return t.remove(nil, topic, root)
+	})
+}
+
+// removes the value or all values if nil and returns the new node or nil if
+// the node became empty
+func (t *SnapshotTree) remove(value interface{}, topic string, n *node) *node {
+	// clear or remove value from leaf node
+	if topic == topicEnd {
+		// check values
+		if len(n.values) == 0 || (value != nil && !contains(n.values, value)) {
+			return n
+		}
+
+		// update values
+		c := n.copy()
+		if value == nil {
+			c.clearValues()
+		} else {
+			c.removeValue(value)
+		}
+
+		return t.prune(c)
+	}
+
+	// get segment
+	segment := topicSegment(topic, t.separator)
+
+	// get child
+	child, ok := n.children[segment]
+	if !ok {
+		return n
+	}
+
+	// descend
+	newChild := t.remove(value, topicShorten(topic, t.separator), child)
+	if newChild == child {
+		return n
+	}
+
+	// replace or remove child
+	c := n.copy()
+	if newChild == nil {
+		delete(c.children, segment)
+	} else {
+		c.children[segment] = newChild
+	}
+
+	return t.prune(c)
+}
+
+// Clear will unregister the supplied value from all topics. This function will
+// automatically shrink the tree.
+func (t *SnapshotTree) Clear(value interface{}) {
+	t.update(func(root *node) *node {
+		return t.clear(value, root)
+	})
+}
+
+// removes the value from all nodes and returns the new node or nil if the
+// node became empty
+func (t *SnapshotTree) clear(value interface{}, n *node) *node {
+	// prepare copy
+	var c *node
+
+	// remove value
+	if contains(n.values, value) {
+		c = n.copy()
+		c.removeValue(value)
+	}
+
+	// remove value from all children
+	for segment, child := range n.children {
+		// clear child
+		newChild := t.clear(value, child)
+		if newChild == child {
+			continue
+		}
+
+		// copy node if needed
+		if c == nil {
+			c = n.copy()
+		}
+
+		// replace or remove child
+		if newChild == nil {
+			delete(c.children, segment)
+		} else {
+			c.children[segment] = newChild
+		}
+	}
+
+	// check if unchanged
+	if c == nil {
+		return n
+	}
+
+	return t.prune(c)
+}
+
+// returns nil if the node is empty
+func (t *SnapshotTree) prune(n *node) *node {
+	if n.empty() {
+		return nil
+	}
+
+	return n
+}
+
+// Match will return a set of values from topics that match the supplied topic.
+// The result set will be cleared from duplicate values.
+//
+// Note: In contrast to Search, Match does not respect wildcards in the query but
+// in the stored tree.
+func (t *SnapshotTree) Match(topic string) []interface{} {
+	// match values
+	var list []interface{}
+	t.match(topic, t.load(), func(values []interface{}) bool {
+		list = append(list, values...)
+		return true
+	})
+
+	return t.clean(list)
+}
+
+// MatchFirst behaves similar to Match but only returns the first found value.
+func (t *SnapshotTree) MatchFirst(topic string) interface{} {
+	// match values
+	var value interface{}
+	t.match(topic, t.load(), func(values []interface{}) bool {
+		value = values[0]
+		return false
+	})
+
+	return value
+}
+
+// Search will return a set of values from topics that match the supplied topic.
+// The result set will be cleared from duplicate values.
+//
+// Note: In contrast to Match, Search respects wildcards in the query but not in
+// the stored tree.
+func (t *SnapshotTree) Search(topic string) []interface{} {
+	// search values
+	var list []interface{}
+	t.search(topic, t.load(), func(values []interface{}) bool {
+		list = append(list, values...)
+		return true
+	})
+
+	return t.clean(list)
+}
+
+// SearchFirst behaves similar to Search but only returns the first found value.
+func (t *SnapshotTree) SearchFirst(topic string) interface{} {
+	// search values
+	var value interface{}
+	t.search(topic, t.load(), func(values []interface{}) bool {
+		value = values[0]
+		return false
+	})
+
+	return value
+}
+
+// Count will count all stored values in the tree. It will not filter out
+// duplicate values and thus might return a different result to `len(All())`.
+func (t *SnapshotTree) Count() int {
+	return t.count(t.load())
+}
+
+// All will return all stored values in the tree.
+func (t *SnapshotTree) All() []interface{} {
+	return t.clean(t.all([]interface{}{}, t.load()))
+}
+
+// Reset will completely clear the tree.
+func (t *SnapshotTree) Reset() {
+	t.update(func(*node) *node {
+		return newNode()
+	})
+}
+
+// String will return a string representation of the tree structure. The number
+// following the nodes show the number of stored values at that level.
+func (t *SnapshotTree) String() string {
+	return fmt.Sprintf("topic.SnapshotTree:%s", t.load().string(0))
+}
@@ -0,0 +1,355 @@
+package topic
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotTreeAdd(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 1)
+
+	assert.Equal(t, []interface{}{1}, tree.load().children["foo"].children["bar"].values)
+}
+
+func TestSnapshotTreeSet(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Set("foo/bar", 1)
+	tree.Set("foo/bar", 2)
+
+	assert.Equal(t, []interface{}{2}, tree.load().children["foo"].children["bar"].values)
+}
+
+func TestSnapshotTreeGet(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Set("foo/#", 1)
+
+	assert.Equal(t, 1, tree.Get("foo/#")[0])
+	assert.Nil(t, tree.Get("foo/bar"))
+}
+
+func TestSnapshotTreeRemove(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+	tree.Remove("bar/baz", 1)
+	tree.Remove("foo/bar", 3)
+	tree.Remove("foo/bar", 1)
+
+	assert.Equal(t, []interface{}{2}, tree.Get("foo/bar"))
+
+	tree.Remove("foo/bar", 2)
+
+	assert.Equal(t, 0, len(tree.load().children))
+}
+
+func TestSnapshotTreeEmpty(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo", 3)
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar", 2)
+	tree.Empty("foo/bar")
+
+	assert.Equal(t, 1, len(tree.load().children))
+	assert.Equal(t, 0, len(tree.load().children["foo"].children))
+
+	tree.Empty("foo")
+
+	assert.Equal(t, 0, len(tree.load().children))
+}
+
+func TestSnapshotTreeClear(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar/baz", 1)
+	tree.Add("foo/baz", 2)
+	tree.Clear(1)
+
+	assert.Equal(t, 1, len(tree.load().children["foo"].children))
+	assert.Equal(t, []interface{}{2}, tree.All())
+
+	tree.Clear(2)
+
+	assert.Equal(t, 0, len(tree.load().children))
+}
+
+func TestSnapshotTreeMatch(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/+", 2)
+	tree.Add("foo/#", 3)
+	tree.Add("foo/bar/#", 4)
+	tree.Add("foo/+", 1)
+
+	assert.Equal(t, []interface{}{3, 2, 1, 4}, tree.Match("foo/bar"))
+	assert.Equal(t, []interface{}{3, 4}, tree.Match("foo/bar/baz"))
+	assert.Equal(t, 3, tree.MatchFirst("foo/bar"))
+	assert.Nil(t, tree.MatchFirst("baz/qux"))
+}
+
+func TestSnapshotTreeSearch(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo", 1)
+	tree.Add("foo/bar", 2)
+	tree.Add("foo/bar/baz", 3)
+	tree.Add("foo/qux", 2)
+
+	assert.Equal(t, []interface{}{2}, tree.Search("foo/qux"))
+	assert.Len(t, tree.Search("foo/#"), 3)
+	assert.Equal(t, 3, tree.SearchFirst("foo/bar/baz"))
+	assert.Nil(t, tree.SearchFirst("baz/qux"))
+}
+
+func TestSnapshotTreeCountAndAll(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo", 1)
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/bar/baz", 3)
+
+	assert.Equal(t, 3, tree.Count())
+	assert.Equal(t, 2, len(tree.All()))
+}
+
+func TestSnapshotTreeReset(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Reset()
+
+	assert.Equal(t, 0, len(tree.load().children))
+}
+
+func TestSnapshotTreeString(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("", 1)
+	tree.Add("/foo", 7)
+	tree.Add("/foo/bar", 42)
+
+	assert.Equal(t, "topic.SnapshotTree:\n| '' => 1\n|   'foo' => 1\n|     'bar' => 1", tree.String())
+}
+
+func TestSnapshotTreeImmutable(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+
+	tree.Add("foo/bar", 1)
+	tree.Add("foo/baz", 2)
+	snapshot := tree.load()
+	count := tree.Count()
+
+	tree.Add("foo/bar", 3)
+	tree.Set("foo/baz", 4)
+	tree.Add("foo/qux", 5)
+	tree.Remove("foo/bar", 1)
+	tree.Clear(4)
+
+	assert.Equal(t, count, tree.count(snapshot))
+	assert.Equal(t, 2, len(snapshot.children["foo"].children))
+	assert.Equal(t, []interface{}{1}, snapshot.children["foo"].children["bar"].values)
+	assert.Equal(t, []interface{}{2}, snapshot.children["foo"].children["baz"].values)
+
+	before := tree.load()
+	tree.Add("foo/bar", 3)
+	tree.Remove("bar", 1)
+	tree.Empty("bar")
+	tree.Clear(6)
+	assert.True(t, before == tree.load())
+}
+
+func TestSnapshotTreeEquivalence(t *testing.T) {
+	tree := NewStandardTree()
+	snapshotTree := NewStandardSnapshotTree()
+
+	topics := []string{"a", "a/b", "a/+", "a/#", "+/b", "#", "a/b/c", "a/+/c", "b"}
+	rnd := rand.New(rand.NewSource(1))
+
+	sorted := func(values []interface{}) []interface{} {
+		sort.Slice(values, func(i, j int) bool {
+			return values[i].(int) < values[j].(int)
+		})
+		return values
+	}
+
+	for i := 0; i < 5000; i++ {
+		topic := topics[rnd.Intn(len(topics))]
+		value := rnd.Intn(5)
+
+		switch rnd.Intn(6) {
+		case 0, 1:
+			tree.Add(topic, value)
+			snapshotTree.Add(topic, value)
+		case 2:
+			tree.Set(topic, value)
+			snapshotTree.Set(topic, value)
+		case 3:
+			tree.Remove(topic, value)
+			snapshotTree.Remove(topic, value)
+		case 4:
+			tree.Empty(topic)
+			snapshotTree.Empty(topic)
+		case 5:
+			tree.Clear(value)
+			snapshotTree.Clear(value)
+		}
+
+		assert.Equal(t, tree.Count(), snapshotTree.Count())
+		assert.Equal(t, sorted(tree.All()), sorted(snapshotTree.All()))
+		assert.Equal(t, sorted(tree.Match("a/b/c")), sorted(snapshotTree.Match("a/b/c")))
+		assert.Equal(t, sorted(tree.Search("a/#")), sorted(snapshotTree.Search("a/#")))
+		assert.Equal(t, len(tree.root.children), len(snapshotTree.load().children))
+	}
+}
+
+func TestSnapshotTreeConcurrency(t *testing.T) {
+	tree := NewStandardSnapshotTree()
+	tree.Add("foo/#", 0)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				topic := fmt.Sprintf("foo/%d", j%10)
+				tree.Add(topic, i)
+				tree.Remove(topic, i)
+			}
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if tree.MatchFirst("foo/1") == nil {
+					panic("missing value")
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+
+	assert.Equal(t, []interface{}{0}, tree.All())
+}
+
+type matchTree interface {
+	Add(topic string, value interface{})
+	Remove(topic string, value interface{})
+	Match(topic string) []interface{}
+}
+
+func benchmarkMatchParallel(b *testing.B, tree matchTree, writes bool) {
+	// add subscriptions
+	for i := 0; i < 1000; i++ {
+		tree.Add(fmt.Sprintf("devices/%d/+", i), i)
+	}
+	tree.Add("devices/#", -1)
+
+	// run writer
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				topic := fmt.Sprintf("devices/%d/#", i%1000)
+				tree.Add(topic, i)
+				tree.Remove(topic, i)
+			}
+		}()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			tree.Match(fmt.Sprintf("devices/%d/temp", i%1000))
+			i++
+		}
+	})
+
+	b.StopTimer()
+
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkTreeMatchParallel(b *testing.B) {
+	benchmarkMatchParallel(b, NewStandardTree(), false)
+}
+
+func BenchmarkSnapshotTreeMatchParallel(b *testing.B) {
+	benchmarkMatchParallel(b, NewStandardSnapshotTree(), false)
+}
+
+func BenchmarkTreeMatchConcurrentWrites(b *testing.B) {
+	benchmarkMatchParallel(b, NewStandardTree(), true)
+}
+
+func BenchmarkSnapshotTreeMatchConcurrentWrites(b *testing.B) {
+	benchmarkMatchParallel(b, NewStandardSnapshotTree(), true)
+}
+
+func BenchmarkSnapshotTreeAddUnique(b *testing.B) {
+	tree := NewStandardSnapshotTree()
+
+	strings := make([]string, 0, b.N)
+
+	for i := 0; i < b.N; i++ {
+		strings = append(strings, fmt.Sprintf("foo/%d", i%1000))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Add(strings[i], i)
+	}
+}
+
+func BenchmarkSnapshotTreeMatchExact(b *testing.B) {
+	tree := NewStandardSnapshotTree()
+	tree.Add("foo/bar", 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tree.Match("foo/bar")
+	}
+}
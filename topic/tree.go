@@ -48,22 +48,29 @@ func (n *node) string(level int) string {
 	return str
 }
 
-// A Tree implements a thread-safe topic tree.
-type Tree struct {
+// the syntax and the read algorithms shared by all trees
+type syntax struct {
 	separator    string
 	wildcardOne  string
 	wildcardSome string
-	root         *node
-	mutex        sync.RWMutex
+}
+
+// A Tree implements a thread-safe topic tree.
+type Tree struct {
+	syntax
+	root  *node
+	mutex sync.RWMutex
 }
 
 // NewTree returns a new Tree using the specified separator and wildcards.
 func NewTree(separator, wildcardOne, wildcardSome string) *Tree {
 	return &Tree{
-		separator:    separator,
-		wildcardOne:  wildcardOne,
-		wildcardSome: wildcardSome,
-		root:         newNode(),
+		syntax: syntax{
+			separator:    separator,
+			wildcardOne:  wildcardOne,
+			wildcardSome: wildcardSome,
+		},
+		root: newNode(),
 	}
 }
 
@@ -154,7 +161,7 @@ func (t *Tree) Get(topic string) []interface{} {
 	return t.get(topic, t.root)
 }
 
-func (t *Tree) get(topic string, node *node) []interface{} {
+func (t *syntax) get(topic string, node *node) []interface{} {
 	// set value on leaf
 	if topic == topicEnd {
 		return node.values
@@ -280,7 +287,7 @@ func (t *Tree) MatchFirst(topic string) interface{} {
 	return value
 }
 
-func (t *Tree) match(topic string, node *node, fn func([]interface{}) bool) {
+func (t *syntax) match(topic string, node *node, fn func([]interface{}) bool) {
 	// add all values to the result set that match multiple levels
 	if child, ok := node.children[t.wildcardSome]; ok && len(child.values) > 0 {
 		if !fn(child.values) {
@@ -347,7 +354,7 @@ func (t *Tree) SearchFirst(topic string) interface{} {
 	return value
 }
 
-func (t *Tree) search(topic string, node *node, fn func([]interface{}) bool) {
+func (t *syntax) search(topic string, node *node, fn func([]interface{}) bool) {
 	// when finished add all values to the result set
 	if topic == topicEnd {
 		if len(node.values) > 0 {
@@ -395,7 +402,7 @@ func (t *Tree) search(topic string, node *node, fn func([]interface{}) bool) {
 }
 
 // clean will remove duplicates
-func (t *Tree) clean(values []interface{}) []interface{} {
+func (t *syntax) clean(values []interface{}) []interface{} {
 	result := values[:0]
 
 	for _, v := range values {
@@ -418,7 +425,7 @@ func (t *Tree) Count() int {
 	return t.count(t.root)
 }
 
-func (t *Tree) count(node *node) int {
+func (t *syntax) count(node *node) int {
 	// prepare total
 	total := 0
 
@@ -439,7 +446,7 @@ func (t *Tree) All() []interface{} {
 	return t.clean(t.all([]interface{}{}, t.root))
 }
 
-func (t *Tree) all(result []interface{}, node *node) []interface{} {
+func (t *syntax) all(result []interface{}, node *node) []interface{} {
 	// add children to results
 	for _, child := range node.children {
 		result = t.all(result, child)
@@ -2,6 +2,7 @@ package transport
 
 import (
 	"io"
+	"io/ioutil"
 	"sync"
 	"time"
 
@@ -17,11 +18,19 @@ type Carrier interface {
 // A BaseConn manages the low-level plumbing between the Carrier and the packet
 // Stream.
 type BaseConn struct {
-	carrier      Carrier
-	stream       *packet.Stream
-	sendMutex    sync.Mutex
-	receiveMutex sync.Mutex
-	readTimeout  time.Duration
+	sent           counters
+	received       counters
+	carrier        Carrier
+	stream         *packet.Stream
+	sendMutex      sync.Mutex
+	receiveMutex   sync.Mutex
+	readTimeout    time.Duration
+	payload        *timeoutReader
+	limitMutex     sync.Mutex
+	sendLimiter    *rateLimiter
+	receiveLimiter *rateLimiter
+	done           chan struct{}
+	doneOnce       sync.Once
 }
 
 // NewBaseConn creates a new BaseConn using the specified Carrier.
@@ -29,6 +38,7 @@ func NewBaseConn(c Carrier) *BaseConn {
 	return &BaseConn{
 		carrier: c,
 		stream:  packet.NewStream(c, c),
+		done:    make(chan struct{}),
 	}
 }
 
@@ -39,12 +49,24 @@ func NewBaseConn(c Carrier) *BaseConn {
 //
 // Note: Only one goroutine can send at the same time.
 func (c *BaseConn) Send(pkt packet.Generic, async bool) error {
+	// get size
+	size := pkt.Len()
+
+	// apply rate limit
+	err := c.limit(&c.sendLimiter, size)
+	if err != nil {
+		// ensure carrier gets closed
+		_ = c.carrier.Close()
+
+		return err
+	}
+
 	// acquire mutex
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
 
 	// write packet
-	err := c.stream.Write(pkt, async)
+	err = c.stream.Write(pkt, async)
 	if err != nil {
 		// ensure carrier gets closed
 		_ = c.carrier.Close()
@@ -52,6 +74,9 @@ func (c *BaseConn) Send(pkt packet.Generic, async bool) error {
 		return err
 	}
 
+	// count packet
+	c.sent.count(pkt, size)
+
 	return nil
 }
 
@@ -63,17 +88,54 @@ func (c *BaseConn) Send(pkt packet.Generic, async bool) error {
 func (c *BaseConn) Receive() (packet.Generic, error) {
 	// acquire mutex
 	c.receiveMutex.Lock()
-	defer c.receiveMutex.Unlock()
+
+	// discard the unread payload of a previously streamed packet
+	if c.payload != nil {
+		_, err := io.Copy(ioutil.Discard, c.payload)
+		c.payload = nil
+		if err != nil {
+			// release mutex
+			c.receiveMutex.Unlock()
+
+			// ensure carrier gets closed
+			_ = c.carrier.Close()
+
+			return nil, err
+		}
+	}
 
 	// read next packet
 	pkt, err := c.stream.Read()
 	if err != nil {
+		// release mutex
+		c.receiveMutex.Unlock()
+
 		// ensure carrier gets closed
 		_ = c.carrier.Close()
 
 		return nil, err
 	}
 
+	// count packet
+	size := pkt.Len()
+	c.received.count(pkt, size)
+
+	// release mutex
+	c.receiveMutex.Unlock()
+
+	// apply rate limit
+	err = c.limit(&c.receiveLimiter, size)
+	if err != nil {
+		// ensure carrier gets closed
+		_ = c.carrier.Close()
+
+		return nil, err
+	}
+
+	// acquire mutex
+	c.receiveMutex.Lock()
+	defer c.receiveMutex.Unlock()
+
 	// reset timeout
 	err = c.resetTimeout()
 	if err != nil {
@@ -83,12 +145,27 @@ func (c *BaseConn) Receive() (packet.Generic, error) {
 		return nil, err
 	}
 
+	// extend timeout while the payload of a streamed packet is read
+	if ps, ok := pkt.(*packet.PublishStream); ok {
+		c.payload = &timeoutReader{
+			reader:  ps.Payload,
+			carrier: c.carrier,
+			timeout: c.readTimeout,
+		}
+		ps.Payload = c.payload
+	}
+
 	return pkt, nil
 }
 
 // Close will close the underlying connection and cleanup resources. It will
 // return any error encountered while closing the underlying connection.
 func (c *BaseConn) Close() error {
+	// cancel delayed sends and receives
+	c.doneOnce.Do(func() {
+		close(c.done)
+	})
+
 	// acquire mutex
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
@@ -116,6 +193,36 @@ func (c *BaseConn) SetReadLimit(limit int64) {
 	c.stream.SetReadLimit(limit)
 }
 
+// SetStrictness sets the level of protocol conformance that is enforced when
+// decoding received packets. Receive will close the connection and return a
+// packet.ConformanceError if a received packet violates the specification.
+func (c *BaseConn) SetStrictness(level packet.Strictness) {
+	c.stream.SetStrictness(level)
+}
+
+// SetStreamThreshold sets the length above which received publish packets are
+// returned as a packet.PublishStream. The payload must be read before the next
+// call to Receive, which will otherwise discard it. The read timeout applies
+// to every read of the payload. A threshold of zero disables streaming.
+func (c *BaseConn) SetStreamThreshold(threshold int64) {
+	c.stream.SetStreamThreshold(threshold)
+}
+
+// SetPooling enables or disables pooled decoding of received publish packets.
+// Pooled packets should be returned by calling Release once the packet and its
+// payload are not used anymore.
+func (c *BaseConn) SetPooling(enabled bool) {
+	c.stream.SetPooling(enabled)
+}
+
+// SetVectoredThreshold sets the payload length from which sent publish packets
+// are written without copying the payload. The payload must not be modified
+// until the packet has been flushed. A threshold of zero disables vectored
+// writes.
+func (c *BaseConn) SetVectoredThreshold(threshold int64) {
+	c.stream.SetVectoredThreshold(threshold)
+}
+
 // SetReadTimeout sets the maximum time that can pass between reads.
 // If no data is received in the set duration the connection will be closed
 // and Read returns an error.
@@ -137,6 +244,70 @@ func (c *BaseConn) SetMaxWriteDelay(delay time.Duration) {
 	c.stream.SetMaxWriteDelay(delay)
 }
 
+// SetSendRate sets the rate limit for sent packets. If the limit is exceeded,
+// Send will either delay the write or close the connection and return
+// ErrRateLimitExceeded.
+func (c *BaseConn) SetSendRate(limit RateLimit) {
+	// acquire mutex
+	c.limitMutex.Lock()
+	defer c.limitMutex.Unlock()
+
+	// set limiter
+	c.sendLimiter = newRateLimiter(limit)
+}
+
+// SetReceiveRate sets the rate limit for received packets. If the limit is
+// exceeded, Receive will either delay the next read or close the connection
+// and return ErrRateLimitExceeded.
+func (c *BaseConn) SetReceiveRate(limit RateLimit) {
+	// acquire mutex
+	c.limitMutex.Lock()
+	defer c.limitMutex.Unlock()
+
+	// set limiter
+	c.receiveLimiter = newRateLimiter(limit)
+}
+
+// Stats returns the traffic counters of the connection.
+func (c *BaseConn) Stats() Stats {
+	// prepare stats
+	var stats Stats
+
+	// load counters
+	stats.BytesSent, stats.PacketsSent, stats.LastSent = c.sent.load()
+	stats.BytesReceived, stats.PacketsReceived, stats.LastReceived = c.received.load()
+
+	return stats
+}
+
+// limit will take the tokens for a packet from the limiter while holding the
+// limit mutex and then wait without holding any mutex
+func (c *BaseConn) limit(limiter **rateLimiter, size int) error {
+	// take tokens
+	var wait time.Duration
+	var err error
+	c.limitMutex.Lock()
+	if *limiter != nil {
+		wait, err = (*limiter).take(size)
+	}
+	c.limitMutex.Unlock()
+	if err != nil {
+		return err
+	} else if wait <= 0 {
+		return nil
+	}
+
+	// wait but return on close
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.done:
+		return ErrConnClosed
+	}
+}
+
 func (c *BaseConn) resetTimeout() error {
 	// check timeout
 	if c.readTimeout > 0 {
@@ -145,3 +316,22 @@ func (c *BaseConn) resetTimeout() error {
 
 	return c.carrier.SetReadDeadline(time.Time{})
 }
+
+// a reader that extends the read deadline of the carrier before every read
+type timeoutReader struct {
+	reader  io.Reader
+	carrier Carrier
+	timeout time.Duration
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	// extend deadline
+	if r.timeout > 0 {
+		err := r.carrier.SetReadDeadline(time.Now().Add(r.timeout))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return r.reader.Read(p)
+}
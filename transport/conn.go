@@ -34,6 +34,30 @@ type Conn interface {
 	// return an error if receiving the next packet will exceed the limit.
 	SetReadLimit(limit int64)
 
+	// SetStrictness sets the level of protocol conformance that is enforced
+	// when decoding received packets. Receive will close the connection and
+	// return a packet.ConformanceError if a received packet violates the
+	// specification.
+	SetStrictness(level packet.Strictness)
+
+	// SetStreamThreshold sets the length above which received publish packets
+	// are returned as a packet.PublishStream. The payload must be read before
+	// the next call to Receive, which will otherwise discard it. The read
+	// timeout applies to every read of the payload. A threshold of zero
+	// disables streaming.
+	SetStreamThreshold(threshold int64)
+
+	// SetPooling enables or disables pooled decoding of received publish
+	// packets. Pooled packets should be returned by calling Release once the
+	// packet and its payload are not used anymore.
+	SetPooling(enabled bool)
+
+	// SetVectoredThreshold sets the payload length from which sent publish
+	// packets are written without copying the payload. The payload must not be
+	// modified until the packet has been flushed. A threshold of zero disables
+	// vectored writes.
+	SetVectoredThreshold(threshold int64)
+
 	// SetReadTimeout sets the maximum time that can pass between reads.
 	// If no data is received in the set duration the connection will be closed
 	// and Read returns an error.
@@ -43,9 +67,26 @@ type Conn interface {
 	// an asynchronous write is flushed.
 	SetMaxWriteDelay(delay time.Duration)
 
+	// SetSendRate sets the rate limit for sent packets. If the limit is
+	// exceeded, Send will either delay the write or close the connection and
+	// return ErrRateLimitExceeded.
+	SetSendRate(limit RateLimit)
+
+	// SetReceiveRate sets the rate limit for received packets. If the limit is
+	// exceeded, Receive will either delay the next read or close the
+	// connection and return ErrRateLimitExceeded.
+	SetReceiveRate(limit RateLimit)
+
+	// Stats will return the traffic counters of the connection.
+	Stats() Stats
+
 	// LocalAddr will return the underlying connection's local net address.
 	LocalAddr() net.Addr
 
 	// RemoteAddr will return the underlying connection's remote net address.
 	RemoteAddr() net.Addr
+
+	// Metadata will return additional information about the connection like
+	// the TLS connection state and web socket request headers.
+	Metadata() Metadata
 }
@@ -1,7 +1,9 @@
 package transport
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -12,7 +14,7 @@ import (
 )
 
 func abstractConnConnectTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		pkt, err := conn1.Receive()
 		assert.NoError(t, err)
 		assert.Equal(t, pkt.Type(), packet.CONNECT)
@@ -39,7 +41,7 @@ func abstractConnConnectTest(t *testing.T, protocol string) {
 }
 
 func abstractConnCloseTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Close()
 		assert.NoError(t, err)
 	})
@@ -52,7 +54,7 @@ func abstractConnCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractConnEncodeErrorTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		pkt := packet.NewConnack()
 		pkt.ReturnCode = 11 // < invalid return code
 
@@ -68,7 +70,7 @@ func abstractConnEncodeErrorTest(t *testing.T, protocol string) {
 }
 
 func abstractConnDecodeErrorTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		buf := []byte{0x00, 0x00} // < too small
 
 		if netConn, ok := conn1.(*NetConn); ok {
@@ -77,6 +79,9 @@ func abstractConnDecodeErrorTest(t *testing.T, protocol string) {
 		} else if webSocketConn, ok := conn1.(*WebSocketConn); ok {
 			err := webSocketConn.conn.WriteMessage(websocket.BinaryMessage, buf)
 			assert.NoError(t, err)
+		} else if memoryConn, ok := conn1.(*MemoryConn); ok {
+			_, err := memoryConn.carrier.Write(buf)
+			assert.NoError(t, err)
 		}
 
 		pkt, err := conn1.Receive()
@@ -92,7 +97,7 @@ func abstractConnDecodeErrorTest(t *testing.T, protocol string) {
 }
 
 func abstractConnSendAfterCloseTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Close()
 		assert.NoError(t, err)
 	})
@@ -108,7 +113,7 @@ func abstractConnSendAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractConnCloseWhileSendTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Send(packet.NewConnect(), false)
 		assert.NoError(t, err)
 
@@ -135,7 +140,7 @@ func abstractConnCloseWhileSendTest(t *testing.T, protocol string) {
 func abstractConnSendAndCloseTest(t *testing.T, protocol string) {
 	wait := make(chan struct{})
 
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Send(packet.NewConnect(), false)
 		assert.NoError(t, err)
 
@@ -159,7 +164,7 @@ func abstractConnSendAndCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractConnReadLimitTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		conn1.SetReadLimit(1)
 
 		pkt, err := conn1.Receive()
@@ -178,8 +183,182 @@ func abstractConnReadLimitTest(t *testing.T, protocol string) {
 	safeReceive(done)
 }
 
+func abstractConnStreamTest(t *testing.T, protocol string) {
+	// 4MB payload
+	const size = 4 << 20
+
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		conn1.SetReadLimit(size + 100)
+		conn1.SetStreamThreshold(1024)
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+
+		ps, ok := pkt.(*packet.PublishStream)
+		assert.True(t, ok)
+		assert.Equal(t, "firmware", ps.Header.Message.Topic)
+		assert.Equal(t, size, ps.Length)
+
+		n, err := io.Copy(ioutil.Discard, ps.Payload)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(size), n)
+
+		err = conn1.Send(packet.NewPingresp(), false)
+		assert.NoError(t, err)
+	})
+
+	ps := packet.NewPublishStream()
+	ps.Header.Message.Topic = "firmware"
+	ps.Length = size
+	ps.Payload = io.LimitReader(zeroReader{}, size)
+
+	err := conn2.Send(ps, false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGRESP, pkt.Type())
+
+	safeReceive(done)
+}
+
+func abstractConnPoolingTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		conn1.SetPooling(true)
+
+		for _, payload := range []string{"foo", "bar"} {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+
+			publish, ok := pkt.(*packet.Publish)
+			assert.True(t, ok)
+			assert.Equal(t, "test", publish.Message.Topic)
+			assert.Equal(t, []byte(payload), publish.Message.Payload)
+
+			publish.Release()
+		}
+
+		err := conn1.Send(packet.NewPingresp(), false)
+		assert.NoError(t, err)
+	})
+
+	for _, payload := range []string{"foo", "bar"} {
+		publish := packet.NewPublish()
+		publish.Message.Topic = "test"
+		publish.Message.Payload = []byte(payload)
+
+		err := conn2.Send(publish, false)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGRESP, pkt.Type())
+
+	safeReceive(done)
+}
+
+func abstractConnVectoredTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		for _, size := range []int{10, 1000, 10000} {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+
+			publish, ok := pkt.(*packet.Publish)
+			assert.True(t, ok)
+			assert.Equal(t, "test", publish.Message.Topic)
+			assert.Equal(t, bytes.Repeat([]byte("x"), size), publish.Message.Payload)
+		}
+
+		err := conn1.Send(packet.NewPingresp(), false)
+		assert.NoError(t, err)
+	})
+
+	conn2.SetVectoredThreshold(100)
+	conn2.SetMaxWriteDelay(10 * time.Millisecond)
+
+	for _, size := range []int{10, 1000, 10000} {
+		publish := packet.NewPublish()
+		publish.Message.Topic = "test"
+		publish.Message.Payload = bytes.Repeat([]byte("x"), size)
+
+		err := conn2.Send(publish, true)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGRESP, pkt.Type())
+
+	safeReceive(done)
+}
+
+// a reader that slowly returns zeros
+type slowReader struct {
+	delay time.Duration
+}
+
+func (r slowReader) Read(p []byte) (int, error) {
+	// wait
+	time.Sleep(r.delay)
+
+	// limit chunk
+	if len(p) > 100 {
+		p = p[:100]
+	}
+
+	return zeroReader{}.Read(p)
+}
+
+func abstractConnStreamTimeoutTest(t *testing.T, protocol string) {
+	const size = 1000
+
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		conn1.SetStreamThreshold(100)
+		conn1.SetReadTimeout(100 * time.Millisecond)
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+
+		ps, ok := pkt.(*packet.PublishStream)
+		assert.True(t, ok)
+
+		n, err := io.Copy(ioutil.Discard, ps.Payload)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(size), n)
+
+		err = conn1.Send(packet.NewPingresp(), false)
+		assert.NoError(t, err)
+	})
+
+	ps := packet.NewPublishStream()
+	ps.Header.Message.Topic = "slow"
+	ps.Length = size
+	ps.Payload = io.LimitReader(slowReader{delay: 30 * time.Millisecond}, size)
+
+	err := conn2.Send(ps, false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGRESP, pkt.Type())
+
+	safeReceive(done)
+}
+
+// a reader that returns an infinite stream of zeros
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
+
 func abstractConnReadTimeoutTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		conn1.SetReadTimeout(10 * time.Millisecond)
 
 		pkt, err := conn1.Receive()
@@ -195,7 +374,7 @@ func abstractConnReadTimeoutTest(t *testing.T, protocol string) {
 }
 
 func abstractConnCloseAfterCloseTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Close()
 		assert.NoError(t, err)
 
@@ -211,7 +390,7 @@ func abstractConnCloseAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractConnAddrTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		assert.NotEmpty(t, conn1.LocalAddr().String())
 		assert.NotEmpty(t, conn1.RemoteAddr().String())
 
@@ -230,7 +409,7 @@ func abstractConnAddrTest(t *testing.T, protocol string) {
 }
 
 func abstractConnAsyncSendTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		pkt, err := conn1.Receive()
 		assert.NoError(t, err)
 		assert.Equal(t, pkt.Type(), packet.CONNECT)
@@ -257,7 +436,7 @@ func abstractConnAsyncSendTest(t *testing.T, protocol string) {
 }
 
 func abstractConnSendAfterAsyncSendTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		pkt, err := conn1.Receive()
 		assert.NoError(t, err)
 		assert.Equal(t, pkt.Type(), packet.CONNECT)
@@ -291,7 +470,7 @@ func abstractConnSendAfterAsyncSendTest(t *testing.T, protocol string) {
 }
 
 func abstractConnAsyncSendAfterCloseTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Close()
 		assert.NoError(t, err)
 	})
@@ -312,7 +491,7 @@ func abstractConnAsyncSendAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractConnCloseAfterAsyncSendTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		pkt, err := conn1.Receive()
 		assert.NoError(t, err)
 		assert.Equal(t, pkt.Type(), packet.CONNECT)
@@ -332,7 +511,7 @@ func abstractConnCloseAfterAsyncSendTest(t *testing.T, protocol string) {
 }
 
 func abstractConnBigAsyncSendAfterCloseTest(t *testing.T, protocol string) {
-	conn2, done := connectionPair(protocol, func(conn1 Conn) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
 		err := conn1.Close()
 		assert.NoError(t, err)
 	})
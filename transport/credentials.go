@@ -0,0 +1,15 @@
+package transport
+
+import "errors"
+
+// ErrCredentialsUnavailable is returned if the peer credentials of a
+// connection cannot be obtained.
+var ErrCredentialsUnavailable = errors.New("credentials unavailable")
+
+// Credentials are the credentials of the process on the other end of a unix
+// domain socket connection.
+type Credentials struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
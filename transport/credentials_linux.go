@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net"
+	"syscall"
+)
+
+func peerCredentials(conn net.Conn) (*Credentials, error) {
+	// unwrap buffered connection
+	if bufConn, ok := conn.(*bufferedConn); ok {
+		conn = bufConn.Conn
+	}
+
+	// unwrap tls connection
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	// unwrap proxy connection
+	if proxyConn, ok := conn.(*ProxyConn); ok {
+		conn = proxyConn.Conn
+	}
+
+	// check connection
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, ErrCredentialsUnavailable
+	}
+
+	// get raw connection
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	// get credentials
+	var ucred *syscall.Ucred
+	var credErr error
+	err = rawConn.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	} else if credErr != nil {
+		return nil, credErr
+	}
+
+	return &Credentials{
+		UID: ucred.Uid,
+		GID: ucred.Gid,
+		PID: ucred.Pid,
+	}, nil
+}
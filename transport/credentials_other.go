@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package transport
+
+import "net"
+
+func peerCredentials(conn net.Conn) (*Credentials, error) {
+	return nil, ErrCredentialsUnavailable
+}
@@ -19,6 +19,17 @@ type DialConfig struct {
 	// The additional request headers for web socket connections.
 	RequestHeader http.Header
 
+	// The URL of a proxy that is used for all connections. Supported are HTTP
+	// proxies using the "http" scheme and SOCKS5 proxies using the "socks5"
+	// scheme. Credentials may be provided as user info.
+	ProxyURL string
+
+	// Whether the proxy should be read from the HTTPS_PROXY and NO_PROXY
+	// environment variables if no proxy URL is configured. If neither is
+	// configured, web socket connections still use the proxy returned by
+	// http.ProxyFromEnvironment while other connections are dialed directly.
+	ProxyFromEnvironment bool
+
 	// The time after which a dial attempt is cancelled.
 	//
 	// Default: No timeout.
@@ -61,18 +72,28 @@ func NewDialer(config DialConfig) *Dialer {
 	// ensure defaults
 	config.ensureDefaults()
 
-	return &Dialer{
+	// create dialer
+	d := &Dialer{
 		config: config,
 		netDialer: net.Dialer{
 			Timeout: config.Timeout,
 		},
 		wsDialer: websocket.Dialer{
-			Proxy:            http.ProxyFromEnvironment,
 			TLSClientConfig:  config.TLSConfig,
 			HandshakeTimeout: config.Timeout,
 			Subprotocols:     []string{"mqtt"},
 		},
 	}
+
+	// dial web socket connections using the proxy aware dialer if a proxy is
+	// configured and keep using the standard environment otherwise
+	if config.ProxyURL != "" || config.ProxyFromEnvironment {
+		d.wsDialer.NetDial = d.dial
+	} else {
+		d.wsDialer.Proxy = http.ProxyFromEnvironment
+	}
+
+	return d
 }
 
 var sharedDialer = NewDialer(DialConfig{})
@@ -106,7 +127,7 @@ func (d *Dialer) Dial(address string) (Conn, error) {
 		}
 
 		// make connection
-		conn, err := d.netDialer.Dial("tcp", net.JoinHostPort(host, port))
+		conn, err := d.dial("tcp", net.JoinHostPort(host, port))
 		if err != nil {
 			return nil, err
 		}
@@ -119,12 +140,19 @@ func (d *Dialer) Dial(address string) (Conn, error) {
 		}
 
 		// make connection
-		conn, err := tls.DialWithDialer(&d.netDialer, "tcp", net.JoinHostPort(host, port), d.config.TLSConfig)
+		conn, err := d.dial("tcp", net.JoinHostPort(host, port))
 		if err != nil {
 			return nil, err
 		}
 
-		return NewNetConn(conn), nil
+		// perform handshake
+		tlsConn, err := d.handshake(conn, host)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+
+		return NewNetConn(tlsConn), nil
 	case "ws":
 		// set default port
 		if port == "" {
@@ -157,7 +185,53 @@ func (d *Dialer) Dial(address string) (Conn, error) {
 		}
 
 		return NewWebSocketConn(conn), nil
+	case "mem":
+		return DialMemory(addr.Host)
+	case "unix":
+		// make connection
+		conn, err := d.netDialer.Dial("unix", socketPath(addr))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewNetConn(conn), nil
 	default:
 		return nil, ErrUnsupportedProtocol
 	}
 }
+
+func (d *Dialer) handshake(conn net.Conn, host string) (*tls.Conn, error) {
+	// prepare config
+	config := &tls.Config{}
+	if d.config.TLSConfig != nil {
+		config = d.config.TLSConfig.Clone()
+	}
+
+	// set server name
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+
+	// set deadline
+	if d.config.Timeout > 0 {
+		err := conn.SetDeadline(time.Now().Add(d.config.Timeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// perform handshake
+	tlsConn := tls.Client(conn, config)
+	err := tlsConn.Handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	// reset deadline
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
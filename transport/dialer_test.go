@@ -73,6 +73,12 @@ func TestDialerWSSError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDialerUnixError(t *testing.T) {
+	conn, err := Dial("unix://" + socketFile(t))
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
+
 func abstractDefaultPortTest(t *testing.T, protocol string) {
 	server, err := testLauncher.Launch(protocol + "://localhost:0")
 	require.NoError(t, err)
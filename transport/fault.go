@@ -0,0 +1,343 @@
+package transport
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// ErrConnCut is returned by Send and Receive of a FaultConn if the connection
+// has been cut.
+var ErrConnCut = errors.New("connection cut")
+
+// FaultConfig describes the faults injected by a FaultConn. The zero value
+// does not inject any faults.
+type FaultConfig struct {
+	// The latency added before a packet is sent.
+	Latency time.Duration
+
+	// The maximum random jitter added to the latency.
+	Jitter time.Duration
+
+	// The types of packets that are silently dropped instead of sent.
+	Drop []packet.Type
+
+	// The types of packets that are sent with a corrupted frame that cannot
+	// be decoded by the receiver.
+	Corrupt []packet.Type
+
+	// The number of sent and received packets after which the connection is
+	// cut. Zero disables the limit.
+	CutAfterPackets int
+
+	// The number of sent and received bytes after which the connection is
+	// cut. Zero disables the limit.
+	CutAfterBytes int
+
+	// Whether writes should stall until the faults are changed or the
+	// connection is closed.
+	StallWrites bool
+}
+
+// Faults holds the faults injected by a set of connections and allows changing
+// them at runtime.
+type Faults struct {
+	config  FaultConfig
+	changed chan struct{}
+	conns   map[*FaultConn]struct{}
+	mutex   sync.Mutex
+}
+
+// NewFaults creates and returns new faults.
+func NewFaults(config FaultConfig) *Faults {
+	return &Faults{
+		config:  config,
+		changed: make(chan struct{}),
+		conns:   map[*FaultConn]struct{}{},
+	}
+}
+
+// Set will change the injected faults. Stalled writes are released if the new
+// config does not stall writes anymore.
+func (f *Faults) Set(config FaultConfig) {
+	// acquire mutex
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// set config
+	f.config = config
+
+	// wake stalled writers
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Get will return the currently injected faults.
+func (f *Faults) Get() FaultConfig {
+	// acquire mutex
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.config
+}
+
+// Cut will immediately close all open connections.
+func (f *Faults) Cut() {
+	// acquire mutex
+	f.mutex.Lock()
+	conns := make([]*FaultConn, 0, len(f.conns))
+	for conn := range f.conns {
+		conns = append(conns, conn)
+	}
+	f.mutex.Unlock()
+
+	// close connections
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+}
+
+func (f *Faults) get() (FaultConfig, chan struct{}) {
+	// acquire mutex
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.config, f.changed
+}
+
+func (f *Faults) add(conn *FaultConn) {
+	// acquire mutex
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// add connection
+	f.conns[conn] = struct{}{}
+}
+
+func (f *Faults) remove(conn *FaultConn) {
+	// acquire mutex
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// remove connection
+	delete(f.conns, conn)
+}
+
+// A FaultConn wraps a connection and injects faults.
+type FaultConn struct {
+	Conn
+
+	faults    *Faults
+	packets   int64
+	bytes     int64
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFaultConn wraps the provided connection and injects the provided faults.
+func NewFaultConn(conn Conn, faults *Faults) *FaultConn {
+	// create connection
+	c := &FaultConn{
+		Conn:   conn,
+		faults: faults,
+		done:   make(chan struct{}),
+	}
+
+	// register connection
+	faults.add(c)
+
+	return c
+}
+
+// Send will send the packet after applying the configured faults.
+func (c *FaultConn) Send(pkt packet.Generic, async bool) error {
+	// get faults
+	config, changed := c.faults.get()
+
+	// stall writes
+	for config.StallWrites {
+		select {
+		case <-changed:
+			config, changed = c.faults.get()
+		case <-c.done:
+			return ErrConnCut
+		}
+	}
+
+	// compute delay
+	delay := config.Latency
+	if config.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(config.Jitter)))
+	}
+
+	// apply delay
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+			return ErrConnCut
+		}
+	}
+
+	// drop packet
+	if hasType(config.Drop, pkt.Type()) {
+		return nil
+	}
+
+	// check limits
+	if c.exceeded(config, pkt.Len()) {
+		_ = c.Close()
+		return ErrConnCut
+	}
+
+	// corrupt packet
+	if hasType(config.Corrupt, pkt.Type()) {
+		pkt = &corruptPacket{Generic: pkt}
+	}
+
+	return c.Conn.Send(pkt, async)
+}
+
+// Receive will receive the next packet and cut the connection if a limit has
+// been exceeded.
+func (c *FaultConn) Receive() (packet.Generic, error) {
+	// receive packet
+	pkt, err := c.Conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	// check limits
+	if c.exceeded(c.faults.Get(), pkt.Len()) {
+		_ = c.Close()
+		return nil, ErrConnCut
+	}
+
+	return pkt, nil
+}
+
+// Close will close the underlying connection.
+func (c *FaultConn) Close() error {
+	// unregister connection
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.faults.remove(c)
+	})
+
+	return c.Conn.Close()
+}
+
+func (c *FaultConn) exceeded(config FaultConfig, size int) bool {
+	// count packet
+	packets := atomic.AddInt64(&c.packets, 1)
+	bytes := atomic.AddInt64(&c.bytes, int64(size))
+
+	// check packets
+	if config.CutAfterPackets > 0 && packets > int64(config.CutAfterPackets) {
+		return true
+	}
+
+	// check bytes
+	if config.CutAfterBytes > 0 && bytes > int64(config.CutAfterBytes) {
+		return true
+	}
+
+	return false
+}
+
+// A FaultServer wraps a server and injects faults into accepted connections.
+type FaultServer struct {
+	Server
+
+	faults *Faults
+}
+
+// NewFaultServer wraps the provided server and injects the provided faults.
+func NewFaultServer(server Server, faults *Faults) *FaultServer {
+	return &FaultServer{
+		Server: server,
+		faults: faults,
+	}
+}
+
+// Accept will return the next available connection wrapped in a FaultConn.
+func (s *FaultServer) Accept() (Conn, error) {
+	// accept connection
+	conn, err := s.Server.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFaultConn(conn, s.faults), nil
+}
+
+// A ConnDialer dials connections to the provided url. It is implemented by
+// Dialer and FaultDialer and matches the client.Dialer interface.
+type ConnDialer interface {
+	Dial(urlString string) (Conn, error)
+}
+
+// A FaultDialer wraps a dialer and injects faults into dialed connections.
+type FaultDialer struct {
+	dialer ConnDialer
+	faults *Faults
+}
+
+// NewFaultDialer wraps the provided dialer and injects the provided faults. If
+// dialer is nil, the default dialer is used.
+func NewFaultDialer(dialer ConnDialer, faults *Faults) *FaultDialer {
+	// set default dialer
+	if dialer == nil {
+		dialer = sharedDialer
+	}
+
+	return &FaultDialer{
+		dialer: dialer,
+		faults: faults,
+	}
+}
+
+// Dial will dial the provided url and return a FaultConn.
+func (d *FaultDialer) Dial(urlString string) (Conn, error) {
+	// dial connection
+	conn, err := d.dialer.Dial(urlString)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFaultConn(conn, d.faults), nil
+}
+
+// a packet that is encoded with an invalid type
+type corruptPacket struct {
+	packet.Generic
+}
+
+func (p *corruptPacket) Encode(dst []byte) (int, error) {
+	// encode packet
+	n, err := p.Generic.Encode(dst)
+	if err != nil {
+		return n, err
+	}
+
+	// clear type
+	dst[0] &= 0x0F
+
+	return n, nil
+}
+
+func hasType(types []packet.Type, typ packet.Type) bool {
+	for _, t := range types {
+		if t == typ {
+			return true
+		}
+	}
+
+	return false
+}
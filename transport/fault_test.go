@@ -0,0 +1,290 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func faultConnPair(config FaultConfig) (*FaultConn, Conn, *Faults) {
+	conn1, conn2 := NewMemoryConnPair("")
+	faults := NewFaults(config)
+	return NewFaultConn(conn1, faults), conn2, faults
+}
+
+func TestFaultConnLatency(t *testing.T) {
+	conn, peer, _ := faultConnPair(FaultConfig{
+		Latency: 50 * time.Millisecond,
+		Jitter:  10 * time.Millisecond,
+	})
+
+	start := time.Now()
+
+	err := conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := peer.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+}
+
+func TestFaultConnDrop(t *testing.T) {
+	conn, peer, _ := faultConnPair(FaultConfig{
+		Drop: []packet.Type{packet.PINGREQ},
+	})
+
+	err := conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewDisconnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := peer.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.DISCONNECT, pkt.Type())
+
+	err = conn.Close()
+	assert.NoError(t, err)
+}
+
+func TestFaultConnCorrupt(t *testing.T) {
+	conn, peer, _ := faultConnPair(FaultConfig{
+		Corrupt: []packet.Type{packet.PINGREQ},
+	})
+
+	err := conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	pkt, err := peer.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+}
+
+func TestFaultConnCutAfterPackets(t *testing.T) {
+	conn, peer, _ := faultConnPair(FaultConfig{
+		CutAfterPackets: 2,
+	})
+
+	err := conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	err = peer.Send(packet.NewPingresp(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGRESP, pkt.Type())
+
+	err = conn.Send(packet.NewPingreq(), false)
+	assert.Equal(t, ErrConnCut, err)
+
+	pkt, err = peer.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+	pkt, err = peer.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+}
+
+func TestFaultConnCutAfterBytes(t *testing.T) {
+	conn, peer, _ := faultConnPair(FaultConfig{
+		CutAfterBytes: 3,
+	})
+
+	err := conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	err = peer.Send(packet.NewPingresp(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Equal(t, ErrConnCut, err)
+
+	pkt, err = peer.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+	pkt, err = peer.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+}
+
+func TestFaultConnStallWrites(t *testing.T) {
+	conn, peer, faults := faultConnPair(FaultConfig{
+		StallWrites: true,
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		err := conn.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write not stalled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	faults.Set(FaultConfig{})
+	safeReceive(done)
+
+	pkt, err := peer.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+	faults.Set(FaultConfig{
+		StallWrites: true,
+	})
+
+	done = make(chan struct{})
+
+	go func() {
+		err := conn.Send(packet.NewPingreq(), false)
+		assert.Equal(t, ErrConnCut, err)
+
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+}
+
+func TestFaultsCut(t *testing.T) {
+	conn, peer, faults := faultConnPair(FaultConfig{})
+
+	faults.Cut()
+
+	err := conn.Send(packet.NewPingreq(), false)
+	assert.Error(t, err)
+
+	pkt, err := peer.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	assert.Len(t, faults.conns, 0)
+}
+
+func TestFaultServerAndDialer(t *testing.T) {
+	faults := NewFaults(FaultConfig{
+		Drop: []packet.Type{packet.PINGREQ},
+	})
+
+	server, err := testLauncher.Launch("mem://localhost:0")
+	require.NoError(t, err)
+
+	server = NewFaultServer(server, faults)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+		assert.IsType(t, &FaultConn{}, conn)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		err = conn.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+
+		err = conn.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	conn, err := NewFaultDialer(nil, faults).Dial(getURL(server, "mem"))
+	require.NoError(t, err)
+	assert.IsType(t, &FaultConn{}, conn)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	safeReceive(done)
+
+	faults.Cut()
+
+	pkt, err = conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestFaultDialerChain(t *testing.T) {
+	inner := NewFaults(FaultConfig{
+		Drop: []packet.Type{packet.PINGREQ},
+	})
+
+	outer := NewFaults(FaultConfig{
+		Drop: []packet.Type{packet.PINGRESP},
+	})
+
+	server, err := testLauncher.Launch("mem://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		close(done)
+	}()
+
+	dialer := NewFaultDialer(NewFaultDialer(nil, inner), outer)
+
+	conn, err := dialer.Dial(getURL(server, "mem"))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewPingreq(), false)
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewPingresp(), false)
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	inner.Cut()
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
@@ -2,8 +2,10 @@ package transport
 
 import (
 	"crypto/tls"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 )
 
 // LaunchConfig is used to configure a launcher.
@@ -13,6 +15,16 @@ type LaunchConfig struct {
 
 	// The fallback to be used id a request is not a web socket upgrade.
 	WebSocketFallback http.Handler
+
+	// The PROXY protocol configuration. If set, tcp, tls, ws, wss, mux and
+	// muxs servers will parse PROXY protocol headers sent by trusted sources.
+	// Other schemes are not affected.
+	ProxyProtocol *ProxyConfig
+
+	// The file mode to be set on unix domain socket files.
+	//
+	// Default: Permissions are not changed.
+	SocketMode os.FileMode
 }
 
 // The Launcher helps with launching a server and accepting connections.
@@ -42,6 +54,14 @@ func (l *Launcher) Launch(address string) (Server, error) {
 		return nil, err
 	}
 
+	// launch proxy server if configured
+	switch addr.Scheme {
+	case "tcp", "mqtt", "tls", "ssl", "mqtts", "ws", "wss", "mux", "muxs":
+		if l.config.ProxyProtocol != nil {
+			return l.launchProxy(addr)
+		}
+	}
+
 	// check scheme
 	switch addr.Scheme {
 	case "tcp", "mqtt":
@@ -52,7 +72,72 @@ func (l *Launcher) Launch(address string) (Server, error) {
 		return CreateWebSocketServer(addr.Host, l.config.WebSocketFallback)
 	case "wss":
 		return CreateSecureWebSocketServer(addr.Host, l.config.TLSConfig, l.config.WebSocketFallback)
+	case "mux":
+		return CreateMultiplexServer(addr.Host, l.config.WebSocketFallback)
+	case "muxs":
+		return CreateSecureMultiplexServer(addr.Host, l.config.TLSConfig, l.config.WebSocketFallback)
+	case "mem":
+		return CreateMemoryServer(addr.Host)
+	case "unix":
+		return CreateUnixServer(socketPath(addr), l.config.SocketMode)
+	default:
+		return nil, ErrUnsupportedProtocol
+	}
+}
+
+func (l *Launcher) launchProxy(addr *url.URL) (Server, error) {
+	// check scheme
+	var secure, webSocket, multiplex bool
+	switch addr.Scheme {
+	case "tcp", "mqtt":
+	case "tls", "ssl", "mqtts":
+		secure = true
+	case "ws":
+		webSocket = true
+	case "wss":
+		secure = true
+		webSocket = true
+	case "mux":
+		multiplex = true
+	case "muxs":
+		secure = true
+		multiplex = true
 	default:
 		return nil, ErrUnsupportedProtocol
 	}
+
+	// create listener
+	tcpListener, err := net.Listen("tcp", addr.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// wrap listener
+	listener, err := NewProxyListener(tcpListener, *l.config.ProxyProtocol)
+	if err != nil {
+		_ = tcpListener.Close()
+		return nil, err
+	}
+
+	// prepare server listener
+	var serverListener net.Listener = listener
+	if secure && multiplex {
+		serverListener = tls.NewListener(listener, multiplexTLSConfig(l.config.TLSConfig))
+	} else if secure {
+		serverListener = tls.NewListener(listener, l.config.TLSConfig)
+	}
+
+	// create server
+	if multiplex {
+		return NewMultiplexServer(serverListener, l.config.WebSocketFallback), nil
+	} else if webSocket {
+		return NewWebSocketServer(serverListener, l.config.WebSocketFallback), nil
+	}
+
+	return NewNetServer(serverListener), nil
+}
+
+// returns the socket path of a unix url
+func socketPath(addr *url.URL) string {
+	return addr.Host + addr.Path
 }
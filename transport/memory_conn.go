@@ -0,0 +1,246 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrConnClosed is returned when reading or writing from an already closed
+// memory connection.
+var ErrConnClosed = errors.New("connection closed")
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type memoryAddr string
+
+func (a memoryAddr) Network() string {
+	return "mem"
+}
+
+func (a memoryAddr) String() string {
+	return string(a)
+}
+
+// a unidirectional unbounded byte buffer
+type memoryBuffer struct {
+	data   []byte
+	closed bool
+	notify chan struct{}
+	mutex  sync.Mutex
+}
+
+func newMemoryBuffer() *memoryBuffer {
+	return &memoryBuffer{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (b *memoryBuffer) write(p []byte) (int, error) {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// check if closed
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	// append data
+	b.data = append(b.data, p...)
+
+	// notify reader
+	b.signal()
+
+	return len(p), nil
+}
+
+func (b *memoryBuffer) read(p []byte) (int, bool, error) {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// read available data
+	if len(b.data) > 0 {
+		n := copy(p, b.data)
+		b.data = b.data[n:]
+		return n, true, nil
+	}
+
+	// return EOF if closed
+	if b.closed {
+		return 0, true, io.EOF
+	}
+
+	return 0, false, nil
+}
+
+func (b *memoryBuffer) close() {
+	// acquire mutex
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// set flag
+	b.closed = true
+
+	// notify reader
+	b.signal()
+}
+
+func (b *memoryBuffer) signal() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// one end of an in-memory connection
+type memoryCarrier struct {
+	in       *memoryBuffer
+	out      *memoryBuffer
+	local    net.Addr
+	remote   net.Addr
+	deadline time.Time
+	closed   bool
+	mutex    sync.Mutex
+}
+
+func (c *memoryCarrier) Read(p []byte) (int, error) {
+	for {
+		// get state
+		c.mutex.Lock()
+		closed := c.closed
+		deadline := c.deadline
+		c.mutex.Unlock()
+
+		// check if closed
+		if closed {
+			return 0, ErrConnClosed
+		}
+
+		// attempt read
+		n, ok, err := c.in.read(p)
+		if ok {
+			return n, err
+		}
+
+		// wait for data if no deadline is set
+		if deadline.IsZero() {
+			<-c.in.notify
+			continue
+		}
+
+		// check deadline
+		d := time.Until(deadline)
+		if d <= 0 {
+			return 0, timeoutError{}
+		}
+
+		// wait for data or deadline
+		timer := time.NewTimer(d)
+		select {
+		case <-c.in.notify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (c *memoryCarrier) Write(p []byte) (int, error) {
+	// check if closed
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+	if closed {
+		return 0, ErrConnClosed
+	}
+
+	return c.out.write(p)
+}
+
+func (c *memoryCarrier) Close() error {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// check if closed
+	if c.closed {
+		return ErrConnClosed
+	}
+
+	// set flag
+	c.closed = true
+
+	// close buffers
+	c.out.close()
+	c.in.close()
+
+	return nil
+}
+
+func (c *memoryCarrier) SetReadDeadline(t time.Time) error {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// set deadline
+	c.deadline = t
+
+	// wake up reader
+	c.in.signal()
+
+	return nil
+}
+
+// A MemoryConn is an in-process connection that does not use any sockets. It
+// is returned by a MemoryServer and DialMemory.
+//
+// Note: Data written to the connection is buffered without limit until it is
+// read by the other end.
+type MemoryConn struct {
+	*BaseConn
+
+	carrier *memoryCarrier
+}
+
+// NewMemoryConnPair returns a pair of connected memory connections.
+func NewMemoryConnPair(addr string) (*MemoryConn, *MemoryConn) {
+	// prepare buffers
+	a := newMemoryBuffer()
+	b := newMemoryBuffer()
+
+	// prepare carriers
+	c1 := &memoryCarrier{in: a, out: b, local: memoryAddr(addr), remote: memoryAddr(addr)}
+	c2 := &memoryCarrier{in: b, out: a, local: memoryAddr(addr), remote: memoryAddr(addr)}
+
+	return newMemoryConn(c1), newMemoryConn(c2)
+}
+
+func newMemoryConn(carrier *memoryCarrier) *MemoryConn {
+	return &MemoryConn{
+		BaseConn: NewBaseConn(carrier),
+		carrier:  carrier,
+	}
+}
+
+// LocalAddr returns the local network address.
+func (c *MemoryConn) LocalAddr() net.Addr {
+	return c.carrier.local
+}
+
+// RemoteAddr returns the remote network address.
+func (c *MemoryConn) RemoteAddr() net.Addr {
+	return c.carrier.remote
+}
+
+// Metadata returns additional information about the connection. Memory
+// connections do not provide any metadata.
+func (c *MemoryConn) Metadata() Metadata {
+	return Metadata{}
+}
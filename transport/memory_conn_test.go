@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryConnConnection(t *testing.T) {
+	abstractConnConnectTest(t, "mem")
+}
+
+func TestMemoryConnClose(t *testing.T) {
+	abstractConnCloseTest(t, "mem")
+}
+
+func TestMemoryConnEncodeError(t *testing.T) {
+	abstractConnEncodeErrorTest(t, "mem")
+}
+
+func TestMemoryConnDecodeError(t *testing.T) {
+	abstractConnDecodeErrorTest(t, "mem")
+}
+
+func TestMemoryConnSendAfterClose(t *testing.T) {
+	abstractConnSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnCloseWhileSend(t *testing.T) {
+	abstractConnCloseWhileSendTest(t, "mem")
+}
+
+func TestMemoryConnSendAndClose(t *testing.T) {
+	abstractConnSendAndCloseTest(t, "mem")
+}
+
+func TestMemoryConnReadLimit(t *testing.T) {
+	abstractConnReadLimitTest(t, "mem")
+}
+
+func TestMemoryConnStream(t *testing.T) {
+	abstractConnStreamTest(t, "mem")
+}
+
+func TestMemoryConnStreamTimeout(t *testing.T) {
+	abstractConnStreamTimeoutTest(t, "mem")
+}
+
+func TestMemoryConnPooling(t *testing.T) {
+	abstractConnPoolingTest(t, "mem")
+}
+
+func TestMemoryConnVectored(t *testing.T) {
+	abstractConnVectoredTest(t, "mem")
+}
+
+func TestMemoryConnReadTimeout(t *testing.T) {
+	abstractConnReadTimeoutTest(t, "mem")
+}
+
+func TestMemoryConnCloseAfterClose(t *testing.T) {
+	abstractConnCloseAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnAddr(t *testing.T) {
+	abstractConnAddrTest(t, "mem")
+}
+
+func TestMemoryConnAsyncSend(t *testing.T) {
+	abstractConnAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnSendAfterAsyncSend(t *testing.T) {
+	abstractConnSendAfterAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnAsyncSendAfterClose(t *testing.T) {
+	abstractConnAsyncSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnCloseAfterAsyncSend(t *testing.T) {
+	abstractConnCloseAfterAsyncSendTest(t, "mem")
+}
+
+func TestMemoryConnBigAsyncSendAfterClose(t *testing.T) {
+	abstractConnBigAsyncSendAfterCloseTest(t, "mem")
+}
+
+func TestMemoryConnPair(t *testing.T) {
+	conn1, conn2 := NewMemoryConnPair("test")
+	assert.Equal(t, "test", conn1.LocalAddr().String())
+	assert.Equal(t, "mem", conn1.RemoteAddr().Network())
+
+	err := conn1.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	err = conn2.Send(packet.NewConnack(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+
+	pkt, err = conn1.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	conn2.SetReadTimeout(10 * time.Millisecond)
+
+	pkt, err = conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	err = conn1.Close()
+	assert.NoError(t, err)
+
+	err = conn1.Close()
+	assert.Error(t, err)
+}
+
+func BenchmarkMemoryConn(b *testing.B) {
+	pkt := packet.NewPublish()
+	pkt.Message.Topic = "foo/bar/baz"
+
+	conn2, done := connectionPair(b, "mem", func(conn1 Conn) {
+		for i := 0; i < b.N; i++ {
+			err := conn1.Send(pkt, false)
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	for i := 0; i < b.N; i++ {
+		_, err := conn2.Receive()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	b.SetBytes(int64(pkt.Len() * 2))
+
+	safeReceive(done)
+}
@@ -0,0 +1,158 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// ErrAddressInUse is returned when a memory server is created with an address
+// that is already used by another memory server.
+var ErrAddressInUse = errors.New("address in use")
+
+// ErrServerNotFound is returned when dialing a memory server that does not
+// exist.
+var ErrServerNotFound = errors.New("server not found")
+
+// ErrServerClosed is returned when accepting from or dialing an already closed
+// memory server.
+var ErrServerClosed = errors.New("server closed")
+
+var memoryServers = map[string]*MemoryServer{}
+var memoryServersMutex sync.Mutex
+var memoryServersPort = 0
+
+// A MemoryServer accepts in-process connections that are created using
+// DialMemory or the "mem" scheme of the Dialer.
+type MemoryServer struct {
+	addr   memoryAddr
+	conns  chan *MemoryConn
+	closed chan struct{}
+	once   sync.Once
+}
+
+// CreateMemoryServer creates a new memory server that is registered with the
+// provided address. If the address has the form "host:0", a free port is
+// assigned similar to a TCP server.
+func CreateMemoryServer(address string) (*MemoryServer, error) {
+	// acquire mutex
+	memoryServersMutex.Lock()
+	defer memoryServersMutex.Unlock()
+
+	// assign port if requested
+	host, port, err := net.SplitHostPort(address)
+	if err == nil && port == "0" {
+		for {
+			memoryServersPort++
+			address = net.JoinHostPort(host, strconv.Itoa(memoryServersPort))
+			if memoryServers[address] == nil {
+				break
+			}
+		}
+	}
+
+	// check address
+	if memoryServers[address] != nil {
+		return nil, ErrAddressInUse
+	}
+
+	// create server
+	server := &MemoryServer{
+		addr:   memoryAddr(address),
+		conns:  make(chan *MemoryConn, 64),
+		closed: make(chan struct{}),
+	}
+
+	// register server
+	memoryServers[address] = server
+
+	return server, nil
+}
+
+// DialMemory will create a new connection to the memory server registered
+// with the provided address.
+func DialMemory(address string) (*MemoryConn, error) {
+	// get server
+	memoryServersMutex.Lock()
+	server := memoryServers[address]
+	memoryServersMutex.Unlock()
+
+	// check server
+	if server == nil {
+		return nil, ErrServerNotFound
+	}
+
+	// create pair
+	conn1, conn2 := NewMemoryConnPair(address)
+
+	// queue connection
+	select {
+	case server.conns <- conn2:
+	case <-server.closed:
+		return nil, ErrServerClosed
+	}
+
+	// check if server has been closed concurrently
+	select {
+	case <-server.closed:
+		_ = conn1.Close()
+		return nil, ErrServerClosed
+	default:
+	}
+
+	return conn1, nil
+}
+
+// Accept will return the next available connection or block until a
+// connection becomes available, otherwise returns an error.
+func (s *MemoryServer) Accept() (Conn, error) {
+	// check if closed
+	select {
+	case <-s.closed:
+		return nil, ErrServerClosed
+	default:
+	}
+
+	// wait for next connection
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-s.closed:
+		return nil, ErrServerClosed
+	}
+}
+
+// Close will close the server and unregister its address. Connections that
+// have not yet been accepted are closed.
+func (s *MemoryServer) Close() error {
+	// close server once
+	err := ErrServerClosed
+	s.once.Do(func() {
+		// unregister server
+		memoryServersMutex.Lock()
+		delete(memoryServers, string(s.addr))
+		memoryServersMutex.Unlock()
+
+		// close channel
+		close(s.closed)
+
+		// close pending connections
+		for {
+			select {
+			case conn := <-s.conns:
+				_ = conn.Close()
+			default:
+				err = nil
+				return
+			}
+		}
+	})
+
+	return err
+}
+
+// Addr returns the server's network address.
+func (s *MemoryServer) Addr() net.Addr {
+	return s.addr
+}
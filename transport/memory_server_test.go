@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryServer(t *testing.T) {
+	abstractServerTest(t, "mem")
+}
+
+func TestMemoryServerAcceptAfterClose(t *testing.T) {
+	abstractServerAcceptAfterCloseTest(t, "mem")
+}
+
+func TestMemoryServerCloseAfterClose(t *testing.T) {
+	abstractServerCloseAfterCloseTest(t, "mem")
+}
+
+func TestMemoryServerAddr(t *testing.T) {
+	server, err := testLauncher.Launch("mem://foo")
+	require.NoError(t, err)
+
+	assert.Equal(t, "foo", server.Addr().String())
+	assert.Equal(t, "mem", server.Addr().Network())
+
+	server2, err := testLauncher.Launch("mem://foo")
+	assert.Nil(t, server2)
+	assert.Equal(t, ErrAddressInUse, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	conn, err := Dial("mem://foo")
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrServerNotFound, err)
+}
+
+func TestMemoryServerRandomPort(t *testing.T) {
+	server1, err := testLauncher.Launch("mem://localhost:0")
+	require.NoError(t, err)
+
+	server2, err := testLauncher.Launch("mem://localhost:0")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, server1.Addr().String(), server2.Addr().String())
+	assert.NotEqual(t, "0", getPort(server1))
+
+	err = server1.Close()
+	assert.NoError(t, err)
+
+	err = server2.Close()
+	assert.NoError(t, err)
+}
+
+func TestMemoryServerClosePending(t *testing.T) {
+	server, err := testLauncher.Launch("mem://pending")
+	require.NoError(t, err)
+
+	conn, err := Dial("mem://pending")
+	require.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+)
+
+// Metadata contains information about a connection that is available in
+// addition to the exchanged packets.
+type Metadata struct {
+	// The TLS connection state if the connection is secured and the handshake
+	// has been completed. On the server side the handshake is completed at the
+	// latest after the first packet has been received.
+	TLS *tls.ConnectionState
+
+	// The HTTP request headers of a web socket connection.
+	RequestHeader http.Header
+
+	// The PROXY protocol header if the connection has been accepted from a
+	// trusted proxy.
+	Proxy *ProxyHeader
+
+	// The credentials of the peer process if the connection is a unix domain
+	// socket connection and the platform supports obtaining them.
+	Credentials *Credentials
+}
+
+// ServerName returns the server name that has been requested by the client
+// using SNI. If the connection is not secured, the authority announced by a
+// trusted proxy is returned.
+func (m Metadata) ServerName() string {
+	// check tls
+	if m.TLS != nil {
+		return m.TLS.ServerName
+	}
+
+	// check proxy
+	if m.Proxy != nil {
+		return m.Proxy.Authority()
+	}
+
+	return ""
+}
+
+// VerifiedChains returns the verified certificate chains of the peer. The
+// first element of each chain is the peer certificate.
+func (m Metadata) VerifiedChains() [][]*x509.Certificate {
+	// check tls
+	if m.TLS == nil {
+		return nil
+	}
+
+	return m.TLS.VerifiedChains
+}
+
+// returns the metadata of a net.Conn
+func netConnMetadata(conn net.Conn) Metadata {
+	// prepare metadata
+	var metadata Metadata
+
+	// unwrap buffered connection
+	if bufConn, ok := conn.(*bufferedConn); ok {
+		conn = bufConn.Conn
+	}
+
+	// get tls state
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		if state.HandshakeComplete {
+			metadata.TLS = &state
+		}
+	}
+
+	// get proxy header
+	metadata.Proxy = proxyHeader(conn)
+
+	// get peer credentials
+	if credentials, err := peerCredentials(conn); err == nil {
+		metadata.Credentials = credentials
+	}
+
+	return metadata
+}
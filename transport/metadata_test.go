@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func abstractMetadataTest(t *testing.T, protocol string, dialer *Dialer, check func(Metadata)) {
+	server, err := testLauncher.Launch(launchURL(t, protocol))
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		check(conn.Metadata())
+
+		err = conn.Close()
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	conn, err := dialer.Dial(protocol + "://localhost:" + getPort(server))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestTCPMetadata(t *testing.T) {
+	abstractMetadataTest(t, "tcp", testDialer, func(metadata Metadata) {
+		assert.Equal(t, Metadata{}, metadata)
+		assert.Empty(t, metadata.ServerName())
+		assert.Nil(t, metadata.VerifiedChains())
+	})
+}
+
+func TestUnixMetadata(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only available on linux")
+	}
+
+	conn2, done := connectionPair(t, "unix", func(conn1 Conn) {
+		assert.Equal(t, &Credentials{
+			UID: uint32(os.Getuid()),
+			GID: uint32(os.Getgid()),
+			PID: int32(os.Getpid()),
+		}, conn1.Metadata().Credentials)
+
+		err := conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	assert.NotNil(t, conn2.Metadata().Credentials)
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func TestTLSMetadata(t *testing.T) {
+	abstractMetadataTest(t, "tls", testDialer, func(metadata Metadata) {
+		assert.NotNil(t, metadata.TLS)
+		assert.Equal(t, uint16(tls.VersionTLS13), metadata.TLS.Version)
+		assert.Equal(t, "localhost", metadata.ServerName())
+		assert.Empty(t, metadata.VerifiedChains())
+		assert.Nil(t, metadata.RequestHeader)
+	})
+}
+
+func TestWSMetadata(t *testing.T) {
+	dialer := NewDialer(DialConfig{
+		RequestHeader: http.Header{
+			"X-Foo": []string{"bar"},
+		},
+	})
+
+	abstractMetadataTest(t, "ws", dialer, func(metadata Metadata) {
+		assert.Nil(t, metadata.TLS)
+		assert.Equal(t, "bar", metadata.RequestHeader.Get("X-Foo"))
+	})
+}
+
+func TestWSSMetadata(t *testing.T) {
+	abstractMetadataTest(t, "wss", testDialer, func(metadata Metadata) {
+		assert.NotNil(t, metadata.TLS)
+		assert.Equal(t, "localhost", metadata.ServerName())
+		assert.Equal(t, "websocket", metadata.RequestHeader.Get("Upgrade"))
+	})
+}
+
+func TestMemoryMetadata(t *testing.T) {
+	abstractMetadataTest(t, "mem", testDialer, func(metadata Metadata) {
+		assert.Equal(t, Metadata{}, metadata)
+	})
+}
+
+func TestMetadataServerName(t *testing.T) {
+	metadata := Metadata{
+		Proxy: &ProxyHeader{
+			TLVs: []ProxyTLV{
+				{Type: ProxyTLVAuthority, Value: []byte("example.com")},
+			},
+		},
+	}
+
+	assert.Equal(t, "example.com", metadata.ServerName())
+
+	metadata.TLS = &tls.ConnectionState{
+		ServerName: "localhost",
+	}
+
+	assert.Equal(t, "localhost", metadata.ServerName())
+}
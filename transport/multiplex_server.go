@@ -0,0 +1,294 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"gopkg.in/tomb.v2"
+)
+
+// the time after which a connection is closed if the protocol has not been
+// detected
+const sniffTimeout = 5 * time.Second
+
+// the maximum time to wait before accepting again after a temporary error
+const maxAcceptDelay = time.Second
+
+// The MultiplexServer accepts net.Conn and websocket.Conn based connections on
+// the same listener. The protocol is detected using the protocol negotiated
+// with TLS ALPN or by inspecting the first byte of the connection. Connections
+// that start with a CONNECT packet are accepted as MQTT connections while
+// HTTP requests are upgraded to web socket connections or passed to the
+// fallback handler.
+type MultiplexServer struct {
+	listener  net.Listener
+	http      *connListener
+	webSocket *WebSocketServer
+	incoming  chan Conn
+	tomb      tomb.Tomb
+}
+
+// NewMultiplexServer wraps the provided listener.
+func NewMultiplexServer(listener net.Listener, fallback http.Handler) *MultiplexServer {
+	// create server
+	s := &MultiplexServer{
+		listener: listener,
+		http:     newConnListener(listener.Addr()),
+		incoming: make(chan Conn),
+	}
+
+	// create web socket server
+	s.webSocket = NewWebSocketServer(s.http, fallback)
+
+	// accept connections in background
+	s.tomb.Go(s.accept)
+	s.tomb.Go(s.forward)
+
+	return s
+}
+
+// CreateMultiplexServer creates a new multiplexed TCP and WS server that
+// listens on the provided address.
+func CreateMultiplexServer(address string, fallback http.Handler) (*MultiplexServer, error) {
+	// create listener
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMultiplexServer(listener, fallback), nil
+}
+
+// CreateSecureMultiplexServer creates a new multiplexed TLS and WSS server that
+// listens on the provided address. The "mqtt" and "http/1.1" protocols are
+// offered to clients using ALPN.
+func CreateSecureMultiplexServer(address string, config *tls.Config, fallback http.Handler) (*MultiplexServer, error) {
+	// create listener
+	listener, err := tls.Listen("tcp", address, multiplexTLSConfig(config))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMultiplexServer(listener, fallback), nil
+}
+
+// returns a copy of the config that offers the multiplexed protocols
+func multiplexTLSConfig(config *tls.Config) *tls.Config {
+	// check config
+	if config == nil {
+		return nil
+	}
+
+	// set protocols
+	config = config.Clone()
+	config.NextProtos = []string{"mqtt", "http/1.1"}
+
+	return config
+}
+
+func (s *MultiplexServer) accept() error {
+	// prepare delay
+	var delay time.Duration
+
+	for {
+		// accept next connection
+		conn, err := s.listener.Accept()
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			// increase delay
+			delay *= 2
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+
+			// retry after delay but return on close
+			select {
+			case <-time.After(delay):
+				continue
+			case <-s.tomb.Dying():
+				return nil
+			}
+		} else if err != nil {
+			return err
+		}
+
+		// reset delay
+		delay = 0
+
+		// detect protocol in background
+		go s.sniff(conn)
+	}
+}
+
+func (s *MultiplexServer) sniff(conn net.Conn) {
+	// limit detection time
+	_ = conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+
+	// get negotiated protocol
+	var protocol string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		err := tlsConn.Handshake()
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		protocol = tlsConn.ConnectionState().NegotiatedProtocol
+	}
+
+	// inspect first byte if no protocol has been negotiated
+	if protocol != "mqtt" && protocol != "http/1.1" {
+		// peek first byte
+		reader := bufio.NewReader(conn)
+		first, err := reader.Peek(1)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		// check byte
+		switch {
+		case first[0] == byte(packet.CONNECT)<<4:
+			protocol = "mqtt"
+		case first[0] >= 'A' && first[0] <= 'Z':
+			protocol = "http/1.1"
+		default:
+			_ = conn.Close()
+			return
+		}
+
+		// keep peeked data
+		conn = &bufferedConn{Conn: conn, reader: reader}
+	}
+
+	// reset deadline
+	_ = conn.SetReadDeadline(time.Time{})
+
+	// hand over http connections
+	if protocol == "http/1.1" {
+		if !s.http.push(conn) {
+			_ = conn.Close()
+		}
+
+		return
+	}
+
+	// forward to accept
+	select {
+	case s.incoming <- NewNetConn(conn):
+	case <-s.tomb.Dying():
+		_ = conn.Close()
+	}
+}
+
+func (s *MultiplexServer) forward() error {
+	for {
+		// accept next web socket connection
+		conn, err := s.webSocket.Accept()
+		if err != nil {
+			return err
+		}
+
+		// forward to accept
+		select {
+		case s.incoming <- conn:
+		case <-s.tomb.Dying():
+			_ = conn.Close()
+			return nil
+		}
+	}
+}
+
+// Accept will return the next available connection or block until a
+// connection becomes available, otherwise returns an error.
+func (s *MultiplexServer) Accept() (Conn, error) {
+	// await next connection
+	select {
+	case conn := <-s.incoming:
+		return conn, nil
+	case <-s.tomb.Dying():
+		return nil, s.tomb.Err()
+	}
+}
+
+// Close will close the underlying listener and cleanup resources. It will
+// return an error if the underlying listener didn't close cleanly.
+func (s *MultiplexServer) Close() error {
+	// kill tomb
+	s.tomb.Kill(fmt.Errorf("closed"))
+
+	// close web socket server
+	_ = s.webSocket.Close()
+
+	// close listener
+	err := s.listener.Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Addr returns the server's network address.
+func (s *MultiplexServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Upgrader returns the used WebSocketUpgrader.
+func (s *MultiplexServer) Upgrader() *WebSocketUpgrader {
+	return s.webSocket.Upgrader()
+}
+
+// a listener that returns connections handed over by the multiplexer
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *connListener) push(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,247 @@
+package transport
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func abstractMultiplexServerTest(t *testing.T, server Server, dialer *Dialer, protocol string) Metadata {
+	var metadata Metadata
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		metadata = conn.Metadata()
+
+		err = conn.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		pkt, err = conn.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, io.EOF, err)
+
+		close(done)
+	}()
+
+	conn, err := dialer.Dial(protocol + "://localhost:" + getPort(server))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	return metadata
+}
+
+func TestMultiplexServer(t *testing.T) {
+	server, err := testLauncher.Launch("mux://localhost:0")
+	require.NoError(t, err)
+
+	metadata := abstractMultiplexServerTest(t, server, testDialer, "tcp")
+	assert.Nil(t, metadata.RequestHeader)
+
+	metadata = abstractMultiplexServerTest(t, server, testDialer, "ws")
+	assert.NotNil(t, metadata.RequestHeader)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+type flakyListener struct {
+	net.Listener
+	errors int
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	// return temporary errors first
+	if l.errors > 0 {
+		l.errors--
+		return nil, timeoutError{}
+	}
+
+	return l.Listener.Accept()
+}
+
+func TestMultiplexServerTemporaryError(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	server := NewMultiplexServer(&flakyListener{Listener: listener, errors: 3}, nil)
+
+	abstractMultiplexServerTest(t, server, testDialer, "tcp")
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestSecureMultiplexServer(t *testing.T) {
+	server, err := testLauncher.Launch("muxs://localhost:0")
+	require.NoError(t, err)
+
+	metadata := abstractMultiplexServerTest(t, server, testDialer, "tls")
+	require.NotNil(t, metadata.TLS)
+	assert.Equal(t, "", metadata.TLS.NegotiatedProtocol)
+	assert.Equal(t, "localhost", metadata.ServerName())
+
+	metadata = abstractMultiplexServerTest(t, server, testDialer, "wss")
+	require.NotNil(t, metadata.TLS)
+	assert.NotNil(t, metadata.RequestHeader)
+
+	for _, protocol := range []string{"mqtt", "http/1.1"} {
+		dialer := NewDialer(DialConfig{
+			TLSConfig: &tls.Config{
+				NextProtos: []string{protocol},
+			},
+		})
+
+		scheme := "tls"
+		if protocol == "http/1.1" {
+			scheme = "wss"
+		}
+
+		metadata = abstractMultiplexServerTest(t, server, dialer, scheme)
+		require.NotNil(t, metadata.TLS)
+		assert.Equal(t, protocol, metadata.TLS.NegotiatedProtocol)
+	}
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestMultiplexServerFallback(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		TLSConfig: testTLSConfig,
+		WebSocketFallback: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("Hello world!"))
+		}),
+	})
+
+	for _, item := range []struct{ server, client string }{
+		{"mux", "http"},
+		{"muxs", "https"},
+	} {
+		server, err := launcher.Launch(item.server + "://localhost:0")
+		require.NoError(t, err)
+
+		resp, err := http.Get(item.client + "://localhost:" + getPort(server))
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", resp.Proto)
+
+		bytes, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello world!"), bytes)
+
+		err = resp.Body.Close()
+		assert.NoError(t, err)
+
+		err = server.Close()
+		assert.NoError(t, err)
+	}
+}
+
+func TestMultiplexServerUnknownProtocol(t *testing.T) {
+	server, err := testLauncher.Launch("mux://localhost:0")
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte{0x30, 0x00})
+	assert.NoError(t, err)
+
+	n, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestMultiplexServerClose(t *testing.T) {
+	server, err := testLauncher.Launch("mux://localhost:0")
+	require.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	conn, err := server.Accept()
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
+
+func TestMultiplexServerWithProxyProtocol(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		TLSConfig: testTLSConfig,
+		ProxyProtocol: &ProxyConfig{
+			TrustedSources: []string{"127.0.0.1", "::1"},
+		},
+	})
+
+	server, err := launcher.Launch("mux://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		header := conn.Metadata().Proxy
+		require.NotNil(t, header)
+		assert.Equal(t, "192.168.0.1:56324", header.Source.String())
+
+		close(done)
+	}()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	assert.NoError(t, err)
+
+	connect := packet.NewConnect()
+	buf := make([]byte, connect.Len())
+	_, err = connect.Encode(buf)
+	assert.NoError(t, err)
+
+	_, err = conn.Write(buf)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
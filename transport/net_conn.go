@@ -29,7 +29,28 @@ func (c *NetConn) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// Metadata returns additional information about the connection.
+func (c *NetConn) Metadata() Metadata {
+	return netConnMetadata(c.conn)
+}
+
 // UnderlyingConn returns the underlying net.Conn.
 func (c *NetConn) UnderlyingConn() net.Conn {
 	return c.conn
 }
+
+// PeerCredentials returns the credentials of the peer process if the
+// connection is a unix domain socket connection. It returns
+// ErrCredentialsUnavailable if the credentials cannot be obtained on this
+// connection or platform. The credentials are also available from Metadata,
+// which works for all connection types.
+func (c *NetConn) PeerCredentials() (*Credentials, error) {
+	return peerCredentials(c.conn)
+}
+
+// ProxyHeader returns the PROXY protocol header of the connection. It returns
+// nil if the connection has not been accepted from a ProxyListener or the
+// source is not trusted.
+func (c *NetConn) ProxyHeader() *ProxyHeader {
+	return proxyHeader(c.conn)
+}
@@ -1,6 +1,8 @@
 package transport
 
 import (
+	"os"
+	"runtime"
 	"testing"
 	"time"
 
@@ -41,6 +43,22 @@ func TestNetConnReadLimit(t *testing.T) {
 	abstractConnReadLimitTest(t, "tcp")
 }
 
+func TestNetConnStream(t *testing.T) {
+	abstractConnStreamTest(t, "tcp")
+}
+
+func TestNetConnStreamTimeout(t *testing.T) {
+	abstractConnStreamTimeoutTest(t, "tcp")
+}
+
+func TestNetConnPooling(t *testing.T) {
+	abstractConnPoolingTest(t, "tcp")
+}
+
+func TestNetConnVectored(t *testing.T) {
+	abstractConnVectoredTest(t, "tcp")
+}
+
 func TestNetConnReadTimeout(t *testing.T) {
 	abstractConnReadTimeoutTest(t, "tcp")
 }
@@ -74,7 +92,7 @@ func TestNetConnBigAsyncSendAfterClose(t *testing.T) {
 }
 
 func TestNetConnCloseWhileReadError(t *testing.T) {
-	conn2, done := connectionPair("tcp", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
 		buf := make([]byte, pkt.Len())
@@ -97,7 +115,7 @@ func TestNetConnCloseWhileReadError(t *testing.T) {
 }
 
 func TestNetConnCloseWhileDetectError(t *testing.T) {
-	conn2, done := connectionPair("tcp", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
 		buf := make([]byte, pkt.Len())
@@ -120,7 +138,7 @@ func TestNetConnCloseWhileDetectError(t *testing.T) {
 }
 
 func TestNetConnReadTimeoutAfterDetect(t *testing.T) {
-	conn2, done := connectionPair("tcp", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "tcp", func(conn1 Conn) {
 		pkt := packet.NewPublish()
 		pkt.Message.Topic = "foo/bar/baz"
 		buf := make([]byte, pkt.Len())
@@ -141,11 +159,89 @@ func TestNetConnReadTimeoutAfterDetect(t *testing.T) {
 	safeReceive(done)
 }
 
+func TestUnixConnConnection(t *testing.T) {
+	abstractConnConnectTest(t, "unix")
+}
+
+func TestUnixConnClose(t *testing.T) {
+	abstractConnCloseTest(t, "unix")
+}
+
+func TestUnixConnReadLimit(t *testing.T) {
+	abstractConnReadLimitTest(t, "unix")
+}
+
+func TestUnixConnStream(t *testing.T) {
+	abstractConnStreamTest(t, "unix")
+}
+
+func TestUnixConnPooling(t *testing.T) {
+	abstractConnPoolingTest(t, "unix")
+}
+
+func TestUnixConnVectored(t *testing.T) {
+	abstractConnVectoredTest(t, "unix")
+}
+
+func TestUnixConnReadTimeout(t *testing.T) {
+	abstractConnReadTimeoutTest(t, "unix")
+}
+
+func TestUnixConnAddr(t *testing.T) {
+	abstractConnAddrTest(t, "unix")
+}
+
+func TestUnixConnAsyncSend(t *testing.T) {
+	abstractConnAsyncSendTest(t, "unix")
+}
+
+func TestUnixConnPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only available on linux")
+	}
+
+	conn2, done := connectionPair(t, "unix", func(conn1 Conn) {
+		cred, err := conn1.(*NetConn).PeerCredentials()
+		assert.NoError(t, err)
+		assert.Equal(t, &Credentials{
+			UID: uint32(os.Getuid()),
+			GID: uint32(os.Getgid()),
+			PID: int32(os.Getpid()),
+		}, cred)
+
+		err = conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func TestNetConnPeerCredentials(t *testing.T) {
+	conn2, done := connectionPair(t, "tcp", func(conn1 Conn) {
+		cred, err := conn1.(*NetConn).PeerCredentials()
+		assert.Nil(t, cred)
+		assert.Equal(t, ErrCredentialsUnavailable, err)
+
+		err = conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
 func BenchmarkNetConn(b *testing.B) {
 	pkt := packet.NewPublish()
 	pkt.Message.Topic = "foo/bar/baz"
 
-	conn2, done := connectionPair("tcp", func(conn1 Conn) {
+	conn2, done := connectionPair(b, "tcp", func(conn1 Conn) {
 		for i := 0; i < b.N; i++ {
 			err := conn1.Send(pkt, false)
 			if err != nil {
@@ -170,7 +266,7 @@ func BenchmarkNetConnAsync(b *testing.B) {
 	pkt := packet.NewPublish()
 	pkt.Message.Topic = "foo/bar/baz"
 
-	conn2, done := connectionPair("tcp", func(conn1 Conn) {
+	conn2, done := connectionPair(b, "tcp", func(conn1 Conn) {
 		for i := 0; i < b.N; i++ {
 			err := conn1.Send(pkt, true)
 			if err != nil {
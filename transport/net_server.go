@@ -2,9 +2,19 @@ package transport
 
 import (
 	"crypto/tls"
+	"errors"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
 )
 
+// ErrSocketInUse is returned when a unix socket is created on a path that is
+// already in use by another server.
+var ErrSocketInUse = errors.New("socket in use")
+
 // A NetServer accepts net.Conn based connections.
 type NetServer struct {
 	listener net.Listener
@@ -39,6 +49,32 @@ func CreateSecureNetServer(address string, config *tls.Config) (*NetServer, erro
 	return NewNetServer(listener), nil
 }
 
+// CreateUnixServer creates a new unix domain socket server that listens on the
+// provided path. A stale socket file left behind by a previous server is
+// removed before listening. If mode is not zero, the socket is created in a
+// private directory and only linked to the provided path once its permissions
+// have been changed to the provided mode.
+func CreateUnixServer(path string, mode os.FileMode) (*NetServer, error) {
+	// remove stale socket
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// create listener
+	var listener net.Listener
+	if mode != 0 {
+		listener, err = listenPrivateUnix(path, mode)
+	} else {
+		listener, err = net.Listen("unix", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNetServer(listener), nil
+}
+
 // Accept will return the next available connection or block until a
 // connection becomes available, otherwise returns an error.
 func (s *NetServer) Accept() (Conn, error) {
@@ -61,3 +97,95 @@ func (s *NetServer) Close() error {
 func (s *NetServer) Addr() net.Addr {
 	return s.listener.Addr()
 }
+
+// a unix listener whose socket file has been moved to the provided path
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l *unixListener) Close() error {
+	// close listener
+	err := l.UnixListener.Close()
+
+	// remove socket
+	_ = os.Remove(l.path)
+
+	return err
+}
+
+// creates a unix listener in a private directory next to the provided path
+// and links the socket to the path once its permissions have been set
+func listenPrivateUnix(path string, mode os.FileMode) (net.Listener, error) {
+	// create private directory
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".gomqtt")
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure directory is removed
+	defer os.RemoveAll(dir)
+
+	// create listener
+	tmp := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the socket is removed from its final path when closing
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	// set permissions
+	err = os.Chmod(tmp, mode)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	// link socket without replacing an existing file
+	err = os.Link(tmp, path)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return &unixListener{
+		UnixListener: listener.(*net.UnixListener),
+		path:         path,
+	}, nil
+}
+
+// removes the socket at the provided path if no server is listening on it
+func removeStaleSocket(path string) error {
+	// get file info
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// leave other files alone and let listen fail
+	if info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	// check if a server is listening
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return ErrSocketInUse
+	}
+
+	// leave socket alone if the error is not a refused connection
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -1,7 +1,14 @@
 package transport
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTCPServer(t *testing.T) {
@@ -31,3 +38,96 @@ func TestNetServerCloseAfterClose(t *testing.T) {
 func TestNetServerAddr(t *testing.T) {
 	abstractServerAddrTest(t, "tcp")
 }
+
+func TestUnixServer(t *testing.T) {
+	abstractServerTest(t, "unix")
+}
+
+func TestUnixServerAcceptAfterClose(t *testing.T) {
+	abstractServerAcceptAfterCloseTest(t, "unix")
+}
+
+func TestUnixServerCloseAfterClose(t *testing.T) {
+	abstractServerCloseAfterCloseTest(t, "unix")
+}
+
+func TestUnixServerAddr(t *testing.T) {
+	path := socketFile(t)
+
+	server, err := testLauncher.Launch("unix://" + path)
+	require.NoError(t, err)
+
+	assert.Equal(t, path, server.Addr().String())
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUnixServerMode(t *testing.T) {
+	path := socketFile(t)
+
+	launcher := NewLauncher(LaunchConfig{
+		SocketMode: 0600,
+	})
+
+	server, err := launcher.Launch("unix://" + path)
+	require.NoError(t, err)
+
+	assert.Equal(t, path, server.Addr().String())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	conn, err := testDialer.Dial("unix://" + path)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	err = server.Close()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUnixServerStaleSocket(t *testing.T) {
+	path := socketFile(t)
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = listener.Close()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+
+	server, err := testLauncher.Launch("unix://" + path)
+	require.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestUnixServerSocketInUse(t *testing.T) {
+	path := socketFile(t)
+
+	server1, err := testLauncher.Launch("unix://" + path)
+	require.NoError(t, err)
+
+	server2, err := testLauncher.Launch("unix://" + path)
+	assert.Nil(t, server2)
+	assert.Equal(t, ErrSocketInUse, err)
+
+	err = server1.Close()
+	assert.NoError(t, err)
+}
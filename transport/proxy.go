@@ -0,0 +1,454 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidProxyHeader is returned if a PROXY protocol header is malformed.
+var ErrInvalidProxyHeader = errors.New("invalid proxy header")
+
+// ErrMissingProxyHeader is returned if a connection from a trusted source does
+// not start with a PROXY protocol header.
+var ErrMissingProxyHeader = errors.New("missing proxy header")
+
+// ErrMissingTrustedSources is returned if the PROXY protocol is configured
+// without any trusted sources.
+var ErrMissingTrustedSources = errors.New("missing trusted sources")
+
+var proxyV1Prefix = []byte("PROXY ")
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// The PROXY protocol v2 TLV types.
+const (
+	ProxyTLVALPN      byte = 0x01
+	ProxyTLVAuthority byte = 0x02
+	ProxyTLVCRC32C    byte = 0x03
+	ProxyTLVNoop      byte = 0x04
+	ProxyTLVUniqueID  byte = 0x05
+	ProxyTLVSSL       byte = 0x20
+	ProxyTLVNetNS     byte = 0x30
+)
+
+// ProxyTLV is a type-length-value entry of a PROXY protocol v2 header.
+type ProxyTLV struct {
+	Type  byte
+	Value []byte
+}
+
+// ProxyHeader is a parsed PROXY protocol header.
+type ProxyHeader struct {
+	// The protocol version (1 or 2).
+	Version int
+
+	// Whether the header does not carry addresses. This is the case for the
+	// v1 UNKNOWN protocol and the v2 LOCAL command.
+	Local bool
+
+	// The original source and destination addresses.
+	Source      net.Addr
+	Destination net.Addr
+
+	// The TLVs of a v2 header.
+	TLVs []ProxyTLV
+}
+
+// TLV returns the value of the first TLV with the specified type.
+func (h *ProxyHeader) TLV(typ byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == typ {
+			return tlv.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Authority returns the authority TLV which is usually the TLS SNI sent by the
+// client to the proxy.
+func (h *ProxyHeader) Authority() string {
+	value, _ := h.TLV(ProxyTLVAuthority)
+	return string(value)
+}
+
+// ALPN returns the ALPN TLV which is the protocol negotiated by the proxy.
+func (h *ProxyHeader) ALPN() string {
+	value, _ := h.TLV(ProxyTLVALPN)
+	return string(value)
+}
+
+// ProxyConfig is used to configure the PROXY protocol handling.
+type ProxyConfig struct {
+	// The IPs or CIDR networks of the proxies that are allowed to send
+	// PROXY protocol headers. Connections from other sources are passed
+	// through unmodified. At least one source must be configured. The networks
+	// "0.0.0.0/0" and "::/0" can be used to trust all sources.
+	TrustedSources []string
+
+	// The time after which a connection is closed if the header has not been
+	// received.
+	//
+	// Default: 5s.
+	HeaderTimeout time.Duration
+}
+
+// A ProxyListener wraps a listener and parses PROXY protocol headers sent by
+// trusted sources.
+type ProxyListener struct {
+	net.Listener
+
+	trusted []*net.IPNet
+	timeout time.Duration
+}
+
+// NewProxyListener wraps the provided listener using the provided config.
+func NewProxyListener(listener net.Listener, config ProxyConfig) (*ProxyListener, error) {
+	// check trusted sources
+	if len(config.TrustedSources) == 0 {
+		return nil, ErrMissingTrustedSources
+	}
+
+	// parse trusted sources
+	var trusted []*net.IPNet
+	for _, source := range config.TrustedSources {
+		// parse network
+		if strings.Contains(source, "/") {
+			_, network, err := net.ParseCIDR(source)
+			if err != nil {
+				return nil, err
+			}
+
+			trusted = append(trusted, network)
+			continue
+		}
+
+		// parse ip
+		ip := net.ParseIP(source)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: source}
+		}
+
+		// prepare mask
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+
+		trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	// set default timeout
+	timeout := config.HeaderTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &ProxyListener{
+		Listener: listener,
+		trusted:  trusted,
+		timeout:  timeout,
+	}, nil
+}
+
+// Accept will return the next connection. The header is parsed lazily on the
+// first read or call to RemoteAddr.
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	// accept connection
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	// pass through untrusted connections
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	return &ProxyConn{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: l.timeout,
+	}, nil
+}
+
+func (l *ProxyListener) isTrusted(addr net.Addr) bool {
+	// get ip
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	// check networks
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A ProxyConn is a connection from a trusted source that starts with a PROXY
+// protocol header.
+type ProxyConn struct {
+	net.Conn
+
+	reader  *bufio.Reader
+	timeout time.Duration
+	header  *ProxyHeader
+	err     error
+	once    sync.Once
+}
+
+// Read will read from the connection after the header has been parsed.
+func (c *ProxyConn) Read(p []byte) (int, error) {
+	// parse header
+	c.parse()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the original source address announced by the proxy.
+//
+// Note: The call will block until the header has been received.
+func (c *ProxyConn) RemoteAddr() net.Addr {
+	// parse header
+	c.parse()
+	if c.header != nil && !c.header.Local {
+		return c.header.Source
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// Header returns the parsed header and any error encountered while parsing.
+//
+// Note: The call will block until the header has been received.
+func (c *ProxyConn) Header() (*ProxyHeader, error) {
+	// parse header
+	c.parse()
+
+	return c.header, c.err
+}
+
+func (c *ProxyConn) parse() {
+	c.once.Do(func() {
+		// close connection if the header is not received in time
+		timer := time.AfterFunc(c.timeout, func() {
+			_ = c.Conn.Close()
+		})
+		defer timer.Stop()
+
+		// read header
+		c.header, c.err = readProxyHeader(c.reader)
+		if c.err != nil {
+			_ = c.Conn.Close()
+		}
+	})
+}
+
+func readProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+	// peek first byte
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	// check version
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		return readProxyHeaderV1(r)
+	case proxyV2Signature[0]:
+		return readProxyHeaderV2(r)
+	default:
+		return nil, ErrMissingProxyHeader
+	}
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (*ProxyHeader, error) {
+	// read line (at most 107 bytes)
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	// check prefix and suffix
+	if !bytes.HasPrefix(line, proxyV1Prefix) || !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// split fields
+	fields := strings.Split(string(line[len(proxyV1Prefix):len(line)-2]), " ")
+
+	// handle unknown
+	if fields[0] == "UNKNOWN" {
+		return &ProxyHeader{Version: 1, Local: true}, nil
+	}
+
+	// check fields
+	if len(fields) != 5 || (fields[0] != "TCP4" && fields[0] != "TCP6") {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// parse addresses
+	src, err := parseProxyAddr(fields[0], fields[1], fields[3])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseProxyAddr(fields[0], fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProxyHeader{
+		Version:     1,
+		Source:      src,
+		Destination: dst,
+	}, nil
+}
+
+func parseProxyAddr(protocol, host, port string) (*net.TCPAddr, error) {
+	// parse ip
+	ip := net.ParseIP(host)
+	if ip == nil || (protocol == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// parse port
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (*ProxyHeader, error) {
+	// read fixed header
+	fixed := make([]byte, 16)
+	_, err := io.ReadFull(r, fixed)
+	if err != nil {
+		return nil, err
+	}
+
+	// check signature and version
+	if !bytes.Equal(fixed[:12], proxyV2Signature) || fixed[12]>>4 != 2 {
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// read rest
+	rest := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	_, err = io.ReadFull(r, rest)
+	if err != nil {
+		return nil, err
+	}
+
+	// prepare header
+	header := &ProxyHeader{
+		Version: 2,
+	}
+
+	// check command
+	switch fixed[12] & 0x0F {
+	case 0x00:
+		header.Local = true
+	case 0x01:
+	default:
+		return nil, ErrInvalidProxyHeader
+	}
+
+	// parse addresses
+	var addrLen int
+	switch fixed[13] {
+	case 0x11: // TCP over IPv4
+		addrLen = 12
+		if len(rest) < addrLen {
+			return nil, ErrInvalidProxyHeader
+		}
+
+		header.Source = &net.TCPAddr{IP: net.IP(rest[0:4]), Port: int(binary.BigEndian.Uint16(rest[8:]))}
+		header.Destination = &net.TCPAddr{IP: net.IP(rest[4:8]), Port: int(binary.BigEndian.Uint16(rest[10:]))}
+	case 0x21: // TCP over IPv6
+		addrLen = 36
+		if len(rest) < addrLen {
+			return nil, ErrInvalidProxyHeader
+		}
+
+		header.Source = &net.TCPAddr{IP: net.IP(rest[0:16]), Port: int(binary.BigEndian.Uint16(rest[32:]))}
+		header.Destination = &net.TCPAddr{IP: net.IP(rest[16:32]), Port: int(binary.BigEndian.Uint16(rest[34:]))}
+	default:
+		// addresses of other families are ignored
+		header.Local = true
+		addrLen = len(rest)
+	}
+
+	// parse TLVs
+	tlvs := rest[addrLen:]
+	for len(tlvs) > 0 {
+		// check length
+		if len(tlvs) < 3 {
+			return nil, ErrInvalidProxyHeader
+		}
+
+		// get length
+		l := int(binary.BigEndian.Uint16(tlvs[1:]))
+		if len(tlvs) < 3+l {
+			return nil, ErrInvalidProxyHeader
+		}
+
+		// add tlv
+		header.TLVs = append(header.TLVs, ProxyTLV{
+			Type:  tlvs[0],
+			Value: tlvs[3 : 3+l],
+		})
+
+		tlvs = tlvs[3+l:]
+	}
+
+	return header, nil
+}
+
+// returns the proxy header of the connection if available
+func proxyHeader(conn net.Conn) *ProxyHeader {
+	// unwrap buffered connection
+	if bufConn, ok := conn.(*bufferedConn); ok {
+		conn = bufConn.Conn
+	}
+
+	// unwrap tls connection
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	// check connection
+	proxyConn, ok := conn.(*ProxyConn)
+	if !ok {
+		return nil
+	}
+
+	// get header
+	header, _ := proxyConn.Header()
+
+	return header
+}
@@ -0,0 +1,386 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedProxy is returned by the dialer if the scheme of the proxy URL
+// is not supported.
+var ErrUnsupportedProxy = errors.New("unsupported proxy")
+
+// ErrProxyRejected is returned by the dialer if the proxy rejected the
+// connection request.
+var ErrProxyRejected = errors.New("proxy rejected connection")
+
+// ErrProxyFailed is returned by the dialer if the proxy responded with an
+// invalid message.
+var ErrProxyFailed = errors.New("proxy failed")
+
+// dials the provided address using the configured proxy if available
+func (d *Dialer) dial(network, addr string) (net.Conn, error) {
+	// get proxy
+	proxy, err := d.proxyFor(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// dial directly if no proxy is used
+	if proxy == nil {
+		return d.netDialer.Dial(network, addr)
+	}
+
+	// check scheme
+	switch proxy.Scheme {
+	case "http":
+		return d.dialHTTPConnect(proxy, addr)
+	case "socks5", "socks5h":
+		return d.dialSOCKS5(proxy, addr)
+	default:
+		return nil, ErrUnsupportedProxy
+	}
+}
+
+// returns the proxy that should be used for the provided address
+func (d *Dialer) proxyFor(addr string) (*url.URL, error) {
+	// use configured proxy
+	if d.config.ProxyURL != "" {
+		return url.Parse(d.config.ProxyURL)
+	}
+
+	// check environment
+	if !d.config.ProxyFromEnvironment {
+		return nil, nil
+	}
+
+	// get proxy
+	proxy := getEnv("HTTPS_PROXY")
+	if proxy == "" {
+		return nil, nil
+	}
+
+	// check exclusions
+	if noProxy(getEnv("NO_PROXY"), addr) {
+		return nil, nil
+	}
+
+	// assume http if the scheme is missing
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	return url.Parse(proxy)
+}
+
+func (d *Dialer) dialHTTPConnect(proxy *url.URL, addr string) (net.Conn, error) {
+	// connect to proxy
+	conn, err := d.netDialer.Dial("tcp", proxyAddr(proxy, "80"))
+	if err != nil {
+		return nil, err
+	}
+
+	// limit handshake
+	d.setDeadline(conn)
+
+	// prepare request
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+
+	// add authorization
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	// write request
+	err = req.Write(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// read response
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// check status
+	if res.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrProxyRejected, res.Status)
+	}
+
+	// clear deadline
+	_ = conn.SetDeadline(time.Time{})
+
+	// keep data that has already been buffered
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+func (d *Dialer) dialSOCKS5(proxy *url.URL, addr string) (net.Conn, error) {
+	// get host and port
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	// connect to proxy
+	conn, err := d.netDialer.Dial("tcp", proxyAddr(proxy, "1080"))
+	if err != nil {
+		return nil, err
+	}
+
+	// limit handshake
+	d.setDeadline(conn)
+
+	// perform handshake
+	err = socks5Handshake(conn, proxy.User, host, uint16(port))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	// clear deadline
+	_ = conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+func socks5Handshake(conn net.Conn, user *url.Userinfo, host string, port uint16) error {
+	// prepare methods
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x02}
+	}
+
+	// write greeting
+	_, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...))
+	if err != nil {
+		return err
+	}
+
+	// read method
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return err
+	} else if buf[0] != 0x05 {
+		return ErrProxyFailed
+	} else if buf[1] != methods[0] {
+		return ErrProxyRejected
+	}
+
+	// authenticate
+	if user != nil {
+		// get credentials
+		username := user.Username()
+		password, _ := user.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return ErrProxyFailed
+		}
+
+		// write credentials
+		msg := []byte{0x01, byte(len(username))}
+		msg = append(msg, username...)
+		msg = append(msg, byte(len(password)))
+		msg = append(msg, password...)
+		_, err = conn.Write(msg)
+		if err != nil {
+			return err
+		}
+
+		// read status
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			return err
+		} else if buf[1] != 0x00 {
+			return ErrProxyRejected
+		}
+	}
+
+	// prepare request
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(req, 0x01)
+		req = append(req, ip.To4()...)
+	} else if ip != nil {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return ErrProxyFailed
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	// write request
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	// read reply
+	head := make([]byte, 4)
+	_, err = io.ReadFull(conn, head)
+	if err != nil {
+		return err
+	} else if head[0] != 0x05 {
+		return ErrProxyFailed
+	} else if head[1] != 0x00 {
+		return fmt.Errorf("%w: socks code %d", ErrProxyRejected, head[1])
+	}
+
+	// get bound address length
+	var l int
+	switch head[3] {
+	case 0x01:
+		l = net.IPv4len
+	case 0x04:
+		l = net.IPv6len
+	case 0x03:
+		_, err = io.ReadFull(conn, buf[:1])
+		if err != nil {
+			return err
+		}
+		l = int(buf[0])
+	default:
+		return ErrProxyFailed
+	}
+
+	// discard bound address and port
+	_, err = io.ReadFull(conn, make([]byte, l+2))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sets the dial timeout as the deadline of the proxy handshake
+func (d *Dialer) setDeadline(conn net.Conn) {
+	if d.config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(d.config.Timeout))
+	}
+}
+
+// returns the address of the proxy
+func proxyAddr(proxy *url.URL, defaultPort string) string {
+	// get port
+	port := proxy.Port()
+	if port == "" {
+		port = defaultPort
+	}
+
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// returns the upper or lower case environment variable
+func getEnv(name string) string {
+	// get upper case
+	value := os.Getenv(name)
+	if value != "" {
+		return value
+	}
+
+	return os.Getenv(strings.ToLower(name))
+}
+
+// checks whether the address is excluded by the NO_PROXY value
+func noProxy(value, addr string) bool {
+	// get host and port
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	// parse ip
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(value, ",") {
+		// clean entry
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		// check wildcard
+		if entry == "*" {
+			return true
+		}
+
+		// check network
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		// split port
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+
+			entry = h
+		}
+
+		// check ip
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+
+			continue
+		}
+
+		// check domain
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		host := strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// a connection that first returns already buffered data
+type bufferedConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
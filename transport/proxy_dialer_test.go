@@ -0,0 +1,353 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runs a proxy stand-in that records the requested targets
+func proxyStandIn(t *testing.T, handshake func(net.Conn) (string, bool)) (string, chan string) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	targets := make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				target, ok := handshake(conn)
+				if !ok {
+					return
+				}
+
+				targets <- target
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+
+				defer upstream.Close()
+
+				go func() {
+					_, _ = io.Copy(upstream, conn)
+				}()
+
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), targets
+}
+
+func httpProxyStandIn(t *testing.T, auth string) (string, chan string) {
+	return proxyStandIn(t, func(conn net.Conn) (string, bool) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			return "", false
+		}
+
+		if auth != "" && req.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)) {
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return "", false
+		}
+
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+		return req.Host, true
+	})
+}
+
+func socks5ProxyStandIn(t *testing.T, user, password string) (string, chan string) {
+	return proxyStandIn(t, func(conn net.Conn) (string, bool) {
+		buf := make([]byte, 2)
+		_, err := io.ReadFull(conn, buf)
+		if err != nil {
+			return "", false
+		}
+
+		methods := make([]byte, buf[1])
+		_, err = io.ReadFull(conn, methods)
+		if err != nil {
+			return "", false
+		}
+
+		if user != "" {
+			_, _ = conn.Write([]byte{0x05, 0x02})
+
+			_, _ = io.ReadFull(conn, buf)
+			u := make([]byte, buf[1])
+			_, _ = io.ReadFull(conn, u)
+			_, _ = io.ReadFull(conn, buf[:1])
+			p := make([]byte, buf[0])
+			_, _ = io.ReadFull(conn, p)
+
+			if string(u) != user || string(p) != password {
+				_, _ = conn.Write([]byte{0x01, 0x01})
+				return "", false
+			}
+
+			_, _ = conn.Write([]byte{0x01, 0x00})
+		} else {
+			_, _ = conn.Write([]byte{0x05, 0x00})
+		}
+
+		head := make([]byte, 4)
+		_, err = io.ReadFull(conn, head)
+		if err != nil {
+			return "", false
+		}
+
+		var host string
+		switch head[3] {
+		case 0x01:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			_, _ = io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			_, _ = io.ReadFull(conn, name)
+			host = string(name)
+		}
+
+		_, _ = io.ReadFull(conn, buf)
+		port := int(buf[0])<<8 | int(buf[1])
+
+		_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+		return net.JoinHostPort(host, strconv.Itoa(port)), true
+	})
+}
+
+func abstractProxyDialerTest(t *testing.T, protocol, proxyURL string, targets chan string) {
+	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		err = conn.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	dialer := NewDialer(DialConfig{
+		ProxyURL: proxyURL,
+	})
+
+	conn, err := dialer.Dial(getURL(server, protocol))
+	require.NoError(t, err)
+
+	assert.Equal(t, server.Addr().String(), <-targets)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	safeReceive(done)
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestHTTPProxyDialer(t *testing.T) {
+	addr, targets := httpProxyStandIn(t, "foo:bar")
+
+	for _, protocol := range []string{"tcp", "tls", "ws", "wss"} {
+		t.Run(protocol, func(t *testing.T) {
+			abstractProxyDialerTest(t, protocol, "http://foo:bar@"+addr, targets)
+		})
+	}
+}
+
+func TestSOCKS5ProxyDialer(t *testing.T) {
+	addr, targets := socks5ProxyStandIn(t, "foo", "bar")
+
+	for _, protocol := range []string{"tcp", "tls", "ws", "wss"} {
+		t.Run(protocol, func(t *testing.T) {
+			abstractProxyDialerTest(t, protocol, "socks5://foo:bar@"+addr, targets)
+		})
+	}
+}
+
+func TestProxyDialerRejected(t *testing.T) {
+	httpAddr, _ := httpProxyStandIn(t, "foo:bar")
+	socksAddr, _ := socks5ProxyStandIn(t, "foo", "bar")
+
+	for _, proxyURL := range []string{
+		"http://foo:baz@" + httpAddr,
+		"http://" + httpAddr,
+		"socks5://foo:baz@" + socksAddr,
+		"socks5://" + socksAddr,
+	} {
+		dialer := NewDialer(DialConfig{
+			ProxyURL: proxyURL,
+		})
+
+		conn, err := dialer.Dial("tcp://localhost:1883")
+		assert.Nil(t, conn)
+		assert.True(t, errors.Is(err, ErrProxyRejected), proxyURL)
+	}
+}
+
+func TestProxyDialerTimeout(t *testing.T) {
+	addr, _ := proxyStandIn(t, func(conn net.Conn) (string, bool) {
+		_, _ = io.Copy(ioutil.Discard, conn)
+		return "", false
+	})
+
+	for _, proxyURL := range []string{
+		"http://" + addr,
+		"socks5://" + addr,
+	} {
+		dialer := NewDialer(DialConfig{
+			ProxyURL: proxyURL,
+			Timeout:  50 * time.Millisecond,
+		})
+
+		start := time.Now()
+		conn, err := dialer.Dial("tcp://localhost:1883")
+		assert.Nil(t, conn)
+		assert.Error(t, err, proxyURL)
+		assert.True(t, time.Since(start) < time.Second, proxyURL)
+	}
+}
+
+func TestProxyDialerWebSocketEnvironment(t *testing.T) {
+	dialer := NewDialer(DialConfig{})
+	assert.NotNil(t, dialer.wsDialer.Proxy)
+	assert.Nil(t, dialer.wsDialer.NetDial)
+
+	dialer = NewDialer(DialConfig{
+		ProxyURL: "http://localhost:8080",
+	})
+	assert.Nil(t, dialer.wsDialer.Proxy)
+	assert.NotNil(t, dialer.wsDialer.NetDial)
+
+	dialer = NewDialer(DialConfig{
+		ProxyFromEnvironment: true,
+	})
+	assert.Nil(t, dialer.wsDialer.Proxy)
+	assert.NotNil(t, dialer.wsDialer.NetDial)
+}
+
+func TestProxyDialerUnsupported(t *testing.T) {
+	dialer := NewDialer(DialConfig{
+		ProxyURL: "ftp://localhost",
+	})
+
+	conn, err := dialer.Dial("tcp://localhost:1883")
+	assert.Nil(t, conn)
+	assert.Equal(t, ErrUnsupportedProxy, err)
+}
+
+func TestProxyDialerEnvironment(t *testing.T) {
+	server, err := testLauncher.Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		_ = conn.Close()
+	}()
+
+	addr, targets := httpProxyStandIn(t, "")
+
+	t.Setenv("HTTPS_PROXY", addr)
+	t.Setenv("NO_PROXY", "localhost,127.0.0.1")
+
+	dialer := NewDialer(DialConfig{
+		ProxyFromEnvironment: true,
+	})
+
+	conn, err := dialer.Dial(getURL(server, "tcp"))
+	require.NoError(t, err)
+	assert.Len(t, targets, 0)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	conn, err = dialer.Dial("tcp://broker.invalid:1234")
+	require.NoError(t, err)
+	assert.Equal(t, "broker.invalid:1234", <-targets)
+
+	pkt, err = conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	conn, err = NewDialer(DialConfig{}).Dial("tcp://broker.invalid:1234")
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+	assert.Len(t, targets, 0)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestNoProxy(t *testing.T) {
+	table := []struct {
+		value string
+		addr  string
+		match bool
+	}{
+		{"", "example.com:1883", false},
+		{"*", "example.com:1883", true},
+		{"example.com", "example.com:1883", true},
+		{"example.com", "sub.example.com:1883", true},
+		{".example.com", "sub.example.com:1883", true},
+		{"example.com", "notexample.com:1883", false},
+		{"foo, Example.com", "EXAMPLE.com:1883", true},
+		{"example.com:8883", "example.com:1883", false},
+		{"example.com:1883", "example.com:1883", true},
+		{"10.0.0.0/8", "10.1.2.3:1883", true},
+		{"10.0.0.0/8", "11.1.2.3:1883", false},
+		{"::1", "[::1]:1883", true},
+		{"10.1.2.3", "10.1.2.3:1883", true},
+	}
+
+	for _, item := range table {
+		assert.Equal(t, item.match, noProxy(item.value, item.addr), item)
+	}
+}
@@ -0,0 +1,339 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyHeaderV2(cmd, family byte, addresses []byte, tlvs ...ProxyTLV) []byte {
+	var rest []byte
+	rest = append(rest, addresses...)
+	for _, tlv := range tlvs {
+		rest = append(rest, tlv.Type, 0, 0)
+		binary.BigEndian.PutUint16(rest[len(rest)-2:], uint16(len(tlv.Value)))
+		rest = append(rest, tlv.Value...)
+	}
+
+	buf := append([]byte{}, proxyV2Signature...)
+	buf = append(buf, 0x20|cmd, family, 0, 0)
+	binary.BigEndian.PutUint16(buf[14:], uint16(len(rest)))
+
+	return append(buf, rest...)
+}
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	header, err := readProxyHeader(bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.1 192.0.2.2 1234 1883\r\nfoo")))
+	assert.NoError(t, err)
+	assert.Equal(t, &ProxyHeader{
+		Version:     1,
+		Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1234},
+		Destination: &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1883},
+	}, header)
+
+	header, err = readProxyHeader(bufio.NewReader(strings.NewReader("PROXY TCP6 2001:db8::1 2001:db8::2 1234 1883\r\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:1234", header.Source.String())
+
+	header, err = readProxyHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, &ProxyHeader{Version: 1, Local: true}, header)
+
+	for _, str := range []string{
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1234\r\n",
+		"PROXY TCP4 2001:db8::1 192.0.2.2 1234 1883\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1234 123456\r\n",
+		"PROXY UDP4 192.0.2.1 192.0.2.2 1234 1883\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 1234 1883\n",
+		"PROXY " + strings.Repeat("A", 200),
+	} {
+		_, err = readProxyHeader(bufio.NewReader(strings.NewReader(str)))
+		assert.Equal(t, ErrInvalidProxyHeader, err, str)
+	}
+
+	_, err = readProxyHeader(bufio.NewReader(strings.NewReader("\x10foo")))
+	assert.Equal(t, ErrMissingProxyHeader, err)
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	addresses := []byte{192, 0, 2, 1, 192, 0, 2, 2, 0x04, 0xD2, 0x07, 0x5B}
+
+	buf := proxyHeaderV2(0x01, 0x11, addresses, ProxyTLV{
+		Type:  ProxyTLVAuthority,
+		Value: []byte("example.com"),
+	}, ProxyTLV{
+		Type:  ProxyTLVALPN,
+		Value: []byte("mqtt"),
+	})
+
+	header, err := readProxyHeader(bufio.NewReader(bytes.NewReader(buf)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, header.Version)
+	assert.False(t, header.Local)
+	assert.Equal(t, "192.0.2.1:1234", header.Source.String())
+	assert.Equal(t, "192.0.2.2:1883", header.Destination.String())
+	assert.Equal(t, "example.com", header.Authority())
+	assert.Equal(t, "mqtt", header.ALPN())
+
+	value, ok := header.TLV(ProxyTLVUniqueID)
+	assert.False(t, ok)
+	assert.Nil(t, value)
+
+	addresses = make([]byte, 36)
+	addresses[15] = 1
+	addresses[31] = 2
+	buf = proxyHeaderV2(0x01, 0x21, addresses)
+
+	header, err = readProxyHeader(bufio.NewReader(bytes.NewReader(buf)))
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]:0", header.Source.String())
+
+	buf = proxyHeaderV2(0x00, 0x00, nil)
+
+	header, err = readProxyHeader(bufio.NewReader(bytes.NewReader(buf)))
+	assert.NoError(t, err)
+	assert.True(t, header.Local)
+	assert.Nil(t, header.Source)
+
+	for _, buf := range [][]byte{
+		proxyHeaderV2(0x02, 0x11, addresses),
+		proxyHeaderV2(0x01, 0x11, addresses[:4]),
+		proxyHeaderV2(0x01, 0x21, addresses[:12]),
+		append(proxyHeaderV2(0x01, 0x11, nil)[:14], 0, 2, 1, 0),
+	} {
+		_, err = readProxyHeader(bufio.NewReader(bytes.NewReader(buf)))
+		assert.Equal(t, ErrInvalidProxyHeader, err)
+	}
+}
+
+func abstractProxyServerTest(t *testing.T, protocol string) {
+	launcher := NewLauncher(LaunchConfig{
+		TLSConfig: testTLSConfig,
+		ProxyProtocol: &ProxyConfig{
+			TrustedSources: []string{"127.0.0.1", "::1/128"},
+		},
+	})
+
+	server, err := launcher.Launch(protocol + "://localhost:0")
+	require.NoError(t, err)
+
+	header := proxyHeaderV2(0x01, 0x11, []byte{192, 0, 2, 1, 127, 0, 0, 1, 0x04, 0xD2, 0x07, 0x5B}, ProxyTLV{
+		Type:  ProxyTLVAuthority,
+		Value: []byte("example.com"),
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		assert.Equal(t, "192.0.2.1:1234", conn.RemoteAddr().String())
+
+		var header *ProxyHeader
+		switch c := conn.(type) {
+		case *NetConn:
+			header = c.ProxyHeader()
+		case *WebSocketConn:
+			header = c.ProxyHeader()
+		}
+
+		assert.NotNil(t, header)
+		assert.Equal(t, "example.com", header.Authority())
+
+		err = conn.Close()
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	dial := func(network, addr string) (net.Conn, error) {
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = conn.Write(header)
+		if err != nil {
+			return nil, err
+		}
+
+		return conn, nil
+	}
+
+	var conn Conn
+	switch protocol {
+	case "tcp":
+		c, err := dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		conn = NewNetConn(c)
+	case "tls":
+		c, err := dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		conn = NewNetConn(tls.Client(c, &tls.Config{ServerName: "localhost"}))
+	case "ws", "wss":
+		dialer := websocket.Dialer{
+			NetDial:      dial,
+			Subprotocols: []string{"mqtt"},
+		}
+		c, _, err := dialer.Dial(protocol+"://localhost:"+getPort(server), nil)
+		require.NoError(t, err)
+		conn = NewWebSocketConn(c)
+	}
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestTCPProxyServer(t *testing.T) {
+	abstractProxyServerTest(t, "tcp")
+}
+
+func TestTLSProxyServer(t *testing.T) {
+	abstractProxyServerTest(t, "tls")
+}
+
+func TestWSProxyServer(t *testing.T) {
+	abstractProxyServerTest(t, "ws")
+}
+
+func TestWSSProxyServer(t *testing.T) {
+	abstractProxyServerTest(t, "wss")
+}
+
+func TestProxyServerUntrustedSource(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		ProxyProtocol: &ProxyConfig{
+			TrustedSources: []string{"10.0.0.0/8"},
+		},
+	})
+
+	server, err := launcher.Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		assert.Nil(t, conn.(*NetConn).ProxyHeader())
+
+		err = conn.Close()
+		assert.NoError(t, err)
+
+		close(done)
+	}()
+
+	conn, err := testDialer.Dial(getURL(server, "tcp"))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyServerMissingHeader(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		ProxyProtocol: &ProxyConfig{
+			TrustedSources: []string{"127.0.0.1", "::1"},
+		},
+	})
+
+	server, err := launcher.Launch("tcp://localhost:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		conn, err := server.Accept()
+		require.NoError(t, err)
+
+		pkt, err := conn.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, ErrMissingProxyHeader, err)
+
+		close(done)
+	}()
+
+	conn, err := testDialer.Dial(getURL(server, "tcp"))
+	require.NoError(t, err)
+
+	err = conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	safeReceive(done)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
+
+func TestProxyServerInvalidSource(t *testing.T) {
+	for _, source := range []string{"foo", "10.0.0.0/33"} {
+		launcher := NewLauncher(LaunchConfig{
+			ProxyProtocol: &ProxyConfig{
+				TrustedSources: []string{source},
+			},
+		})
+
+		server, err := launcher.Launch("tcp://localhost:0")
+		assert.Error(t, err)
+		assert.Nil(t, server)
+	}
+}
+
+func TestProxyServerMissingTrustedSources(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		ProxyProtocol: &ProxyConfig{},
+	})
+
+	server, err := launcher.Launch("tcp://localhost:0")
+	assert.Equal(t, ErrMissingTrustedSources, err)
+	assert.Nil(t, server)
+}
+
+func TestProxyServerOtherProtocol(t *testing.T) {
+	launcher := NewLauncher(LaunchConfig{
+		ProxyProtocol: &ProxyConfig{
+			TrustedSources: []string{"127.0.0.1", "::1"},
+		},
+	})
+
+	server, err := launcher.Launch("mem://localhost:0")
+	require.NoError(t, err)
+
+	err = server.Close()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRateLimitExceeded is returned by Send and Receive if a rate limit has
+// been exceeded and the limit is configured to close the connection.
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// RateLimit configures the token bucket based rate limiting of a connection in
+// one direction. Zero rates disable the respective limit.
+type RateLimit struct {
+	// The allowed bytes per second.
+	Bytes float64
+
+	// The allowed packets per second.
+	Packets float64
+
+	// The maximum number of bytes and packets that can be transferred at
+	// once. The byte burst should be at least as big as the largest packet.
+	//
+	// Default: One second of the respective rate.
+	BytesBurst   float64
+	PacketsBurst float64
+
+	// Whether the connection should be closed instead of delayed if a limit
+	// is exceeded.
+	Close bool
+}
+
+// a simple token bucket
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	// check rate
+	if rate <= 0 {
+		return nil
+	}
+
+	// set default burst
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	// add tokens
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	// set time
+	b.last = now
+}
+
+func (b *tokenBucket) wait() time.Duration {
+	// check tokens
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// limits bytes and packets in one direction
+type rateLimiter struct {
+	close   bool
+	bytes   *tokenBucket
+	packets *tokenBucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	// get time
+	now := time.Now()
+
+	// prepare limiter
+	limiter := &rateLimiter{
+		close:   limit.Close,
+		bytes:   newTokenBucket(limit.Bytes, limit.BytesBurst, now),
+		packets: newTokenBucket(limit.Packets, limit.PacketsBurst, now),
+	}
+
+	// return nil if there are no limits
+	if limiter.bytes == nil && limiter.packets == nil {
+		return nil
+	}
+
+	return limiter
+}
+
+// take will consume the tokens for a packet of the specified size. It returns
+// the time the caller has to wait or ErrRateLimitExceeded if the limiter is
+// configured to close.
+func (l *rateLimiter) take(size int) (time.Duration, error) {
+	// get time
+	now := time.Now()
+
+	// refill buckets
+	for _, b := range []*tokenBucket{l.bytes, l.packets} {
+		if b != nil {
+			b.refill(now)
+		}
+	}
+
+	// check buckets if closing
+	if l.close {
+		if l.bytes != nil && l.bytes.tokens < float64(size) {
+			return 0, ErrRateLimitExceeded
+		} else if l.packets != nil && l.packets.tokens < 1 {
+			return 0, ErrRateLimitExceeded
+		}
+	}
+
+	// take tokens and compute wait
+	var wait time.Duration
+	if l.bytes != nil {
+		l.bytes.tokens -= float64(size)
+		wait = l.bytes.wait()
+	}
+	if l.packets != nil {
+		l.packets.tokens--
+		if w := l.packets.wait(); w > wait {
+			wait = w
+		}
+	}
+
+	return wait, nil
+}
@@ -0,0 +1,228 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(RateLimit{}))
+	assert.Nil(t, newRateLimiter(RateLimit{Close: true}))
+
+	limiter := newRateLimiter(RateLimit{
+		Bytes:   100,
+		Packets: 10,
+	})
+
+	wait, err := limiter.take(50)
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = limiter.take(100)
+	assert.NoError(t, err)
+	assert.InDelta(t, 500*time.Millisecond, wait, float64(10*time.Millisecond))
+
+	limiter = newRateLimiter(RateLimit{
+		Packets:      10,
+		PacketsBurst: 2,
+	})
+
+	for i := 0; i < 2; i++ {
+		wait, err = limiter.take(1000)
+		assert.NoError(t, err)
+		assert.Zero(t, wait)
+	}
+
+	wait, err = limiter.take(1000)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100*time.Millisecond, wait, float64(10*time.Millisecond))
+
+	limiter = newRateLimiter(RateLimit{
+		Bytes: 100,
+		Close: true,
+	})
+
+	wait, err = limiter.take(100)
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	wait, err = limiter.take(10)
+	assert.Equal(t, ErrRateLimitExceeded, err)
+	assert.Zero(t, wait)
+
+	time.Sleep(150 * time.Millisecond)
+
+	wait, err = limiter.take(10)
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func abstractConnReceiveRateTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		conn1.SetReceiveRate(RateLimit{
+			Packets:      20,
+			PacketsBurst: 1,
+		})
+
+		start := time.Now()
+
+		for i := 0; i < 3; i++ {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, packet.PINGREQ, pkt.Type())
+		}
+
+		assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+		conn1.SetReceiveRate(RateLimit{
+			Packets:      1,
+			PacketsBurst: 1,
+			Close:        true,
+		})
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+		pkt, err = conn1.Receive()
+		assert.Nil(t, pkt)
+		assert.Equal(t, ErrRateLimitExceeded, err)
+	})
+
+	for i := 0; i < 5; i++ {
+		err := conn2.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func abstractConnSendRateTest(t *testing.T, protocol string) {
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		conn1.SetSendRate(RateLimit{
+			Bytes:      100,
+			BytesBurst: 2,
+		})
+
+		start := time.Now()
+
+		for i := 0; i < 3; i++ {
+			err := conn1.Send(packet.NewPingresp(), false)
+			assert.NoError(t, err)
+		}
+
+		assert.True(t, time.Since(start) >= 30*time.Millisecond)
+
+		conn1.SetSendRate(RateLimit{
+			Bytes:      1,
+			BytesBurst: 2,
+		})
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = conn1.Close()
+		}()
+
+		start = time.Now()
+
+		err := conn1.Send(packet.NewPingresp(), false)
+		assert.NoError(t, err)
+
+		err = conn1.Send(packet.NewPingresp(), false)
+		assert.Equal(t, ErrConnClosed, err)
+
+		assert.True(t, time.Since(start) < time.Second)
+	})
+
+	for i := 0; i < 4; i++ {
+		pkt, err := conn2.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.PINGRESP, pkt.Type())
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
+
+func TestNetConnReceiveRate(t *testing.T) {
+	abstractConnReceiveRateTest(t, "tcp")
+}
+
+func TestNetConnSendRate(t *testing.T) {
+	abstractConnSendRateTest(t, "tcp")
+}
+
+func TestWebSocketConnReceiveRate(t *testing.T) {
+	abstractConnReceiveRateTest(t, "ws")
+}
+
+func TestWebSocketConnSendRate(t *testing.T) {
+	abstractConnSendRateTest(t, "ws")
+}
+
+func TestMemoryConnReceiveRate(t *testing.T) {
+	abstractConnReceiveRateTest(t, "mem")
+}
+
+func TestMemoryConnSendRate(t *testing.T) {
+	abstractConnSendRateTest(t, "mem")
+}
+
+func TestRateLimitDelayWithoutMutex(t *testing.T) {
+	conn2, done := connectionPair(t, "mem", func(conn1 Conn) {
+		conn1.SetReceiveRate(RateLimit{
+			Packets:      1,
+			PacketsBurst: 1,
+		})
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+		delayed := make(chan struct{})
+		go func() {
+			pkt, err := conn1.Receive()
+			assert.NoError(t, err)
+			assert.Equal(t, packet.PINGREQ, pkt.Type())
+			close(delayed)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		start := time.Now()
+		conn1.SetReadTimeout(time.Second)
+		assert.True(t, time.Since(start) < 100*time.Millisecond)
+
+		select {
+		case <-delayed:
+			assert.Fail(t, "receive not delayed")
+		default:
+		}
+
+		<-delayed
+
+		_ = conn1.Close()
+	})
+
+	for i := 0; i < 2; i++ {
+		err := conn2.Send(packet.NewPingreq(), false)
+		assert.NoError(t, err)
+	}
+
+	pkt, err := conn2.Receive()
+	assert.Nil(t, pkt)
+	assert.Error(t, err)
+
+	safeReceive(done)
+}
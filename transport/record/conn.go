@@ -0,0 +1,95 @@
+package record
+
+import (
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+)
+
+// A Conn wraps a connection and records all successfully sent and received
+// packets. Streamed publish packets are recorded without their payload.
+type Conn struct {
+	transport.Conn
+
+	writer *Writer
+	err    error
+	mutex  sync.Mutex
+}
+
+// NewConn wraps the provided connection and writes the recorded packets to the
+// provided writer.
+func NewConn(conn transport.Conn, writer *Writer) *Conn {
+	return &Conn{
+		Conn:   conn,
+		writer: writer,
+	}
+}
+
+// Send will send the packet and record it.
+func (c *Conn) Send(pkt packet.Generic, async bool) error {
+	// get time
+	now := time.Now()
+
+	// send packet
+	err := c.Conn.Send(pkt, async)
+	if err != nil {
+		return err
+	}
+
+	// record packet
+	c.record(now, Sent, pkt)
+
+	return nil
+}
+
+// Receive will receive the next packet and record it.
+func (c *Conn) Receive() (packet.Generic, error) {
+	// receive packet
+	pkt, err := c.Conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	// record packet
+	c.record(time.Now(), Received, pkt)
+
+	return pkt, nil
+}
+
+// Err will return the first error encountered while writing entries.
+func (c *Conn) Err() error {
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
+}
+
+func (c *Conn) record(now time.Time, direction Direction, pkt packet.Generic) {
+	// use header of streamed packets
+	if ps, ok := pkt.(*packet.PublishStream); ok {
+		header := ps.Header
+		pkt = &header
+	}
+
+	// write entry
+	err := c.writer.Write(Entry{
+		Time:      now,
+		Direction: direction,
+		Packet:    pkt,
+	})
+	if err == nil {
+		return
+	}
+
+	// acquire mutex
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// keep first error
+	if c.err == nil {
+		c.err = err
+	}
+}
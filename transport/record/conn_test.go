@@ -0,0 +1,79 @@
+package record
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConn(t *testing.T) {
+	conn1, conn2 := transport.NewMemoryConnPair("")
+
+	var buf bytes.Buffer
+	conn := NewConn(conn1, NewWriter(&buf))
+
+	err := conn.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNECT, pkt.Type())
+
+	err = conn2.Send(packet.NewConnack(), false)
+	assert.NoError(t, err)
+
+	pkt, err = conn.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	err = conn.Close()
+	assert.NoError(t, err)
+
+	err = conn.Send(packet.NewDisconnect(), false)
+	assert.Error(t, err)
+	assert.NoError(t, conn.Err())
+
+	entries, err := ReadAll(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, Sent, entries[0].Direction)
+	assert.Equal(t, packet.NewConnect(), entries[0].Packet)
+	assert.Equal(t, Received, entries[1].Direction)
+	assert.Equal(t, packet.NewConnack(), entries[1].Packet)
+}
+
+func TestConnStream(t *testing.T) {
+	conn1, conn2 := transport.NewMemoryConnPair("")
+	conn1.SetStreamThreshold(10)
+
+	var buf bytes.Buffer
+	conn := NewConn(conn1, NewWriter(&buf))
+
+	pp := packet.NewPublish()
+	pp.Message.Topic = "foo"
+	pp.Message.Payload = bytes.Repeat([]byte("x"), 100)
+
+	err := conn2.Send(pp, false)
+	assert.NoError(t, err)
+
+	pkt, err := conn.Receive()
+	assert.NoError(t, err)
+
+	payload, err := ioutil.ReadAll(pkt.(*packet.PublishStream).Payload)
+	assert.NoError(t, err)
+	assert.Equal(t, pp.Message.Payload, payload)
+
+	entries, err := ReadAll(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, &packet.Publish{
+		Message: packet.Message{
+			Topic: "foo",
+		},
+	}, entries[0].Packet)
+}
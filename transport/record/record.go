@@ -0,0 +1,356 @@
+// Package record implements the recording and replaying of MQTT conversations.
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// ErrInvalidRecording is returned by the Reader if the data is not a valid
+// recording.
+var ErrInvalidRecording = errors.New("invalid recording")
+
+// ErrInvalidDirection is returned if an entry has an unknown direction.
+var ErrInvalidDirection = errors.New("invalid direction")
+
+// the maximum length of an encoded packet, which consists of the maximum
+// remaining length and the largest fixed header
+const maxPacketLen = 268435455 + 5
+
+// the magic bytes and version that start a recording
+var header = []byte("GMQR\x01")
+
+// Direction denotes whether a packet has been sent or received.
+type Direction byte
+
+// All available directions.
+const (
+	_ Direction = iota
+	Sent
+	Received
+)
+
+// String returns the name of the direction.
+func (d Direction) String() string {
+	switch d {
+	case Sent:
+		return "sent"
+	case Received:
+		return "received"
+	}
+
+	return "unknown"
+}
+
+// Invert returns the opposite direction.
+func (d Direction) Invert() Direction {
+	switch d {
+	case Sent:
+		return Received
+	case Received:
+		return Sent
+	}
+
+	return d
+}
+
+// An Entry is a single recorded packet.
+type Entry struct {
+	// The time the packet has been sent or received.
+	Time time.Time
+
+	// The direction of the packet.
+	Direction Direction
+
+	// The packet.
+	Packet packet.Generic
+}
+
+type jsonEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Type      string    `json:"type"`
+	Packet    string    `json:"packet"`
+	Data      []byte    `json:"data"`
+}
+
+// MarshalJSON will encode the entry as JSON. The object includes a readable
+// representation of the packet and the encoded packet.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	// encode packet
+	data, err := encodePacket(e.Packet)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonEntry{
+		Time:      e.Time,
+		Direction: e.Direction.String(),
+		Type:      e.Packet.Type().String(),
+		Packet:    e.Packet.String(),
+		Data:      data,
+	})
+}
+
+// UnmarshalJSON will decode the entry from JSON. Only the time, direction and
+// encoded packet are used.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	// decode entry
+	var entry jsonEntry
+	err := json.Unmarshal(data, &entry)
+	if err != nil {
+		return err
+	}
+
+	// check direction
+	switch entry.Direction {
+	case "sent":
+		e.Direction = Sent
+	case "received":
+		e.Direction = Received
+	default:
+		return ErrInvalidDirection
+	}
+
+	// decode packet
+	e.Packet, err = decodePacket(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	// set time
+	e.Time = entry.Time
+
+	return nil
+}
+
+// A Writer writes entries in a compact binary format. Every entry consists of
+// the time since the previous entry in nanoseconds (the first entry is relative
+// to the unix epoch), the direction and the length prefixed encoded packet.
+type Writer struct {
+	writer  io.Writer
+	last    time.Time
+	started bool
+	mutex   sync.Mutex
+}
+
+// NewWriter creates and returns a new Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		writer: w,
+		last:   time.Unix(0, 0),
+	}
+}
+
+// Write will write the provided entry. It is safe to call Write from multiple
+// goroutines.
+func (w *Writer) Write(entry Entry) error {
+	// acquire mutex
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// check direction
+	if entry.Direction != Sent && entry.Direction != Received {
+		return ErrInvalidDirection
+	}
+
+	// encode packet
+	data, err := encodePacket(entry.Packet)
+	if err != nil {
+		return err
+	}
+
+	// prepare buffer
+	buf := make([]byte, 0, len(header)+2*binary.MaxVarintLen64+1+len(data))
+
+	// add header on first write
+	if !w.started {
+		buf = append(buf, header...)
+	}
+
+	// add time, direction, length and packet
+	buf = appendUvarint(buf, uint64(entry.Time.Sub(w.last)))
+	buf = append(buf, byte(entry.Direction))
+	buf = appendUvarint(buf, uint64(len(data)))
+	buf = append(buf, data...)
+
+	// write entry
+	_, err = w.writer.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	// update state
+	w.started = true
+	w.last = entry.Time
+
+	return nil
+}
+
+// A Reader reads entries written by a Writer.
+type Reader struct {
+	reader  *bufio.Reader
+	last    time.Time
+	started bool
+}
+
+// NewReader creates and returns a new Reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		reader: bufio.NewReader(r),
+		last:   time.Unix(0, 0),
+	}
+}
+
+// Read will read the next entry. It will return io.EOF if no entries are left.
+func (r *Reader) Read() (Entry, error) {
+	// check header
+	if !r.started {
+		buf := make([]byte, len(header))
+		_, err := io.ReadFull(r.reader, buf)
+		if err == io.ErrUnexpectedEOF || (err == nil && string(buf) != string(header)) {
+			return Entry{}, ErrInvalidRecording
+		} else if err != nil {
+			return Entry{}, err
+		}
+
+		r.started = true
+	}
+
+	// read time
+	delta, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// read direction
+	direction, err := r.reader.ReadByte()
+	if err != nil {
+		return Entry{}, unexpected(err)
+	}
+
+	// check direction
+	if Direction(direction) != Sent && Direction(direction) != Received {
+		return Entry{}, ErrInvalidDirection
+	}
+
+	// read length
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return Entry{}, unexpected(err)
+	}
+
+	// check length
+	if length > maxPacketLen {
+		return Entry{}, ErrInvalidRecording
+	}
+
+	// read packet
+	data := make([]byte, length)
+	_, err = io.ReadFull(r.reader, data)
+	if err != nil {
+		return Entry{}, unexpected(err)
+	}
+
+	// decode packet
+	pkt, err := decodePacket(data)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	// update time
+	r.last = r.last.Add(time.Duration(delta))
+
+	return Entry{
+		Time:      r.last,
+		Direction: Direction(direction),
+		Packet:    pkt,
+	}, nil
+}
+
+// ReadAll will read all entries from the provided reader.
+func ReadAll(r io.Reader) ([]Entry, error) {
+	// prepare reader
+	reader := NewReader(r)
+
+	// read entries
+	var entries []Entry
+	for {
+		entry, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+}
+
+// Invert will return a copy of the entries with inverted directions. This can
+// be used to replay the client side of a recording made by a broker.
+func Invert(entries []Entry) []Entry {
+	// copy entries
+	inverted := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		entry.Direction = entry.Direction.Invert()
+		inverted = append(inverted, entry)
+	}
+
+	return inverted
+}
+
+func encodePacket(pkt packet.Generic) ([]byte, error) {
+	// encode packet
+	buf := make([]byte, pkt.Len())
+	_, err := pkt.Encode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func decodePacket(buf []byte) (packet.Generic, error) {
+	// detect packet
+	length, typ := packet.DetectPacket(buf)
+	if length != len(buf) {
+		return nil, ErrInvalidRecording
+	}
+
+	// create packet
+	pkt, err := typ.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// decode packet
+	_, err = pkt.Decode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func unexpected(err error) error {
+	// entries are never split
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,117 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntries() []Entry {
+	publish := packet.NewPublish()
+	publish.ID = 1
+	publish.Message = packet.Message{
+		Topic:   "test",
+		Payload: []byte("test"),
+		QOS:     1,
+	}
+
+	puback := packet.NewPuback()
+	puback.ID = 1
+
+	now := time.Now()
+
+	return []Entry{
+		{Time: now, Direction: Sent, Packet: packet.NewConnect()},
+		{Time: now.Add(time.Millisecond), Direction: Received, Packet: packet.NewConnack()},
+		{Time: now.Add(2 * time.Millisecond), Direction: Sent, Packet: publish},
+		{Time: now.Add(3 * time.Millisecond), Direction: Received, Packet: puback},
+	}
+}
+
+func assertEntries(t *testing.T, expected, actual []Entry) {
+	require.Len(t, actual, len(expected))
+
+	for i := range expected {
+		assert.True(t, expected[i].Time.Equal(actual[i].Time))
+		assert.Equal(t, expected[i].Direction, actual[i].Direction)
+		assert.Equal(t, expected[i].Packet, actual[i].Packet)
+	}
+}
+
+func TestWriterReader(t *testing.T) {
+	entries := testEntries()
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+
+	for _, entry := range entries {
+		err := writer.Write(entry)
+		assert.NoError(t, err)
+	}
+
+	read, err := ReadAll(bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assertEntries(t, entries, read)
+}
+
+func TestReaderErrors(t *testing.T) {
+	read, err := ReadAll(bytes.NewReader(nil))
+	assert.NoError(t, err)
+	assert.Empty(t, read)
+
+	read, err = ReadAll(bytes.NewReader([]byte("GMQ")))
+	assert.Equal(t, ErrInvalidRecording, err)
+	assert.Nil(t, read)
+
+	read, err = ReadAll(bytes.NewReader([]byte("foo bar baz")))
+	assert.Equal(t, ErrInvalidRecording, err)
+	assert.Nil(t, read)
+
+	read, err = ReadAll(bytes.NewReader([]byte("GMQR\x01\x00\x03")))
+	assert.Equal(t, ErrInvalidDirection, err)
+	assert.Nil(t, read)
+
+	read, err = ReadAll(bytes.NewReader([]byte("GMQR\x01\x00\x01\x02\xe0")))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Nil(t, read)
+
+	read, err = ReadAll(bytes.NewReader([]byte("GMQR\x01\x00\x01\xff\xff\xff\xff\xff\xff\xff\xff\x7f")))
+	assert.Equal(t, ErrInvalidRecording, err)
+	assert.Nil(t, read)
+
+	err = NewWriter(&bytes.Buffer{}).Write(Entry{Packet: packet.NewConnect()})
+	assert.Equal(t, ErrInvalidDirection, err)
+}
+
+func TestEntryJSON(t *testing.T) {
+	entries := testEntries()
+
+	data, err := json.Marshal(entries[2])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"direction":"sent"`)
+	assert.Contains(t, string(data), `"type":"Publish"`)
+
+	var entry Entry
+	err = json.Unmarshal(data, &entry)
+	assert.NoError(t, err)
+	assertEntries(t, entries[2:3], []Entry{entry})
+
+	err = json.Unmarshal([]byte(`{"direction":"foo"}`), &entry)
+	assert.Equal(t, ErrInvalidDirection, err)
+}
+
+func TestInvert(t *testing.T) {
+	entries := testEntries()
+
+	inverted := Invert(entries)
+	assert.Equal(t, Received, inverted[0].Direction)
+	assert.Equal(t, Sent, inverted[1].Direction)
+	assert.Equal(t, Sent, entries[0].Direction)
+}
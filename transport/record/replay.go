@@ -0,0 +1,202 @@
+package record
+
+import (
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+	"github.com/256dpi/gomqtt/transport/flow"
+)
+
+// A Mismatch describes a difference between the recorded and the replayed
+// conversation.
+type Mismatch struct {
+	// The recorded packet. It is nil if an unexpected packet has been
+	// received.
+	Expected packet.Generic
+
+	// The received packet. It is nil if the expected packet has not been
+	// received.
+	Actual packet.Generic
+}
+
+// ReplayConfig is used to configure a replay.
+type ReplayConfig struct {
+	// Whether the recorded delays between sent packets should be kept.
+	Timing bool
+
+	// The time to wait for the expected packets of a response.
+	//
+	// Default: 1s.
+	Timeout time.Duration
+}
+
+// Replay will send the sent packets of the recording on the provided
+// connection and compare the received packets with the recorded packets.
+// Received packets are matched out of order and may arrive earlier than in the
+// recording. The connection is closed when the replay is finished. Only errors
+// encountered while sending are returned, all other differences are reported
+// as mismatches.
+func Replay(conn transport.Conn, entries []Entry, config ReplayConfig) ([]Mismatch, error) {
+	// set default timeout
+	if config.Timeout == 0 {
+		config.Timeout = time.Second
+	}
+
+	// ensure connection is closed
+	defer conn.Close()
+
+	// prepare channels
+	incoming := make(chan packet.Generic)
+	done := make(chan struct{})
+	defer close(done)
+
+	// receive packets in background
+	go func() {
+		defer close(incoming)
+
+		for {
+			pkt, err := conn.Receive()
+			if err != nil {
+				return
+			}
+
+			select {
+			case incoming <- pkt:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// prepare mismatches and unmatched packets
+	var mismatches []Mismatch
+	var unmatched []packet.Generic
+
+	// handle entries
+	var last time.Time
+	for i := 0; i < len(entries); {
+		// get group
+		j := i + 1
+		for j < len(entries) && entries[j].Direction == entries[i].Direction {
+			j++
+		}
+		group := entries[i:j]
+		i = j
+
+		// send packets
+		if group[0].Direction == Sent {
+			for _, entry := range group {
+				// keep timing
+				if config.Timing && !last.IsZero() {
+					time.Sleep(entry.Time.Sub(last))
+				}
+				last = entry.Time
+
+				// send packet
+				err := conn.Send(entry.Packet, false)
+				if err != nil {
+					return mismatches, err
+				}
+			}
+
+			continue
+		}
+
+		// set last
+		last = group[len(group)-1].Time
+
+		// prepare expected packets
+		var expected []packet.Generic
+		for _, entry := range group {
+			expected = append(expected, entry.Packet)
+		}
+
+		// match packets that have been received early
+		expected, unmatched = match(expected, unmatched)
+
+		// receive packets
+		timeout := time.After(config.Timeout)
+	receive:
+		for len(expected) > 0 {
+			select {
+			case pkt, ok := <-incoming:
+				if !ok {
+					break receive
+				}
+
+				// match packet
+				expected, unmatched = match(expected, append(unmatched, pkt))
+			case <-timeout:
+				break receive
+			}
+		}
+
+		// add missing packets
+		for _, pkt := range expected {
+			mismatches = append(mismatches, Mismatch{Expected: pkt})
+		}
+	}
+
+	// add unexpected packets
+	for _, pkt := range unmatched {
+		mismatches = append(mismatches, Mismatch{Actual: pkt})
+	}
+
+	return mismatches, nil
+}
+
+// removes matching packets from both lists
+func match(expected, received []packet.Generic) ([]packet.Generic, []packet.Generic) {
+	// prepare unmatched
+	var unmatched []packet.Generic
+
+	// match received packets
+	for _, pkt := range received {
+		matched := false
+		for i, e := range expected {
+			if e.String() == pkt.String() {
+				expected = append(expected[:i], expected[i+1:]...)
+				matched = true
+				break
+			}
+		}
+
+		// keep unmatched packet
+		if !matched {
+			unmatched = append(unmatched, pkt)
+		}
+	}
+
+	return expected, unmatched
+}
+
+// Flow will convert the recording into a flow that sends the sent packets and
+// receives the received packets. Consecutive packets of the same direction are
+// combined into a single action.
+func Flow(entries []Entry) *flow.Flow {
+	// prepare flow
+	f := flow.New()
+
+	// add actions
+	for i := 0; i < len(entries); {
+		// get group
+		var pkts []packet.Generic
+		j := i
+		for j < len(entries) && entries[j].Direction == entries[i].Direction {
+			pkts = append(pkts, entries[j].Packet)
+			j++
+		}
+
+		// add action
+		if entries[i].Direction == Sent {
+			f.Send(pkts...)
+		} else {
+			f.Receive(pkts...)
+		}
+
+		i = j
+	}
+
+	return f
+}
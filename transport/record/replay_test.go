@@ -0,0 +1,125 @@
+package record
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/broker"
+	"github.com/256dpi/gomqtt/client"
+	"github.com/256dpi/gomqtt/packet"
+	"github.com/256dpi/gomqtt/transport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dialer func(string) (transport.Conn, error)
+
+func (d dialer) Dial(url string) (transport.Conn, error) {
+	return d(url)
+}
+
+func runBroker() (string, func()) {
+	port, quit, done := broker.Run(broker.NewEngine(broker.NewMemoryBackend()), "mem")
+
+	return "mem://localhost:" + port, func() {
+		close(quit)
+		<-done
+	}
+}
+
+func recordSession(t *testing.T) []Entry {
+	url, stop := runBroker()
+	defer stop()
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+
+	c := client.New()
+
+	config := client.NewConfigWithClientID(url, "test")
+	config.Dialer = dialer(func(url string) (transport.Conn, error) {
+		conn, err := transport.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewConn(conn, writer), nil
+	})
+
+	cf, err := c.Connect(config)
+	require.NoError(t, err)
+	require.NoError(t, cf.Wait(10*time.Second))
+
+	sf, err := c.Subscribe("test", 1)
+	require.NoError(t, err)
+	require.NoError(t, sf.Wait(10*time.Second))
+
+	pf, err := c.Publish("foo", []byte("foo"), 1, false)
+	require.NoError(t, err)
+	require.NoError(t, pf.Wait(10*time.Second))
+
+	err = c.Disconnect()
+	assert.NoError(t, err)
+
+	entries, err := ReadAll(&buf)
+	require.NoError(t, err)
+
+	return entries
+}
+
+func TestReplay(t *testing.T) {
+	entries := recordSession(t)
+	assert.Len(t, entries, 7)
+
+	url, stop := runBroker()
+	conn, err := transport.Dial(url)
+	require.NoError(t, err)
+
+	mismatches, err := Replay(conn, entries, ReplayConfig{})
+	assert.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	suback := packet.NewSuback()
+	suback.ID = 1
+	suback.ReturnCodes = []packet.QOS{0}
+
+	for i, entry := range entries {
+		if entry.Packet.Type() == packet.SUBACK {
+			entries[i].Packet = suback
+		}
+	}
+
+	stop()
+
+	url, stop = runBroker()
+	defer stop()
+
+	conn, err = transport.Dial(url)
+	require.NoError(t, err)
+
+	mismatches, err = Replay(conn, entries, ReplayConfig{
+		Timing:  true,
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	require.Len(t, mismatches, 2)
+	assert.Equal(t, suback, mismatches[0].Expected)
+	assert.Nil(t, mismatches[0].Actual)
+	assert.Nil(t, mismatches[1].Expected)
+	assert.Equal(t, packet.SUBACK, mismatches[1].Actual.Type())
+}
+
+func TestFlow(t *testing.T) {
+	entries := recordSession(t)
+
+	url, stop := runBroker()
+	defer stop()
+
+	conn, err := transport.Dial(url)
+	require.NoError(t, err)
+
+	err = Flow(entries).End().Test(conn)
+	assert.NoError(t, err)
+}
@@ -12,7 +12,7 @@ import (
 )
 
 func abstractServerTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(t, protocol))
 	require.NoError(t, err)
 
 	wait := make(chan struct{})
@@ -61,7 +61,7 @@ func abstractServerLaunchErrorTest(t *testing.T, protocol string) {
 }
 
 func abstractServerAcceptAfterCloseTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(t, protocol))
 	require.NoError(t, err)
 
 	err = server.Close()
@@ -73,7 +73,7 @@ func abstractServerAcceptAfterCloseTest(t *testing.T, protocol string) {
 }
 
 func abstractServerCloseAfterCloseTest(t *testing.T, protocol string) {
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(t, protocol))
 	require.NoError(t, err)
 
 	err = server.Close()
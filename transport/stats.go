@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+)
+
+// Stats contains the traffic counters of a connection. Bytes are counted using
+// the encoded length of the sent and received packets.
+type Stats struct {
+	// The total number of sent and received bytes.
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// The number of sent and received packets per packet type.
+	PacketsSent     map[packet.Type]uint64
+	PacketsReceived map[packet.Type]uint64
+
+	// The time the last packet has been sent or received. The values are zero
+	// if no packet has been sent or received yet.
+	LastSent     time.Time
+	LastReceived time.Time
+}
+
+// TotalPacketsSent returns the number of sent packets of all types.
+func (s Stats) TotalPacketsSent() uint64 {
+	return sum(s.PacketsSent)
+}
+
+// TotalPacketsReceived returns the number of received packets of all types.
+func (s Stats) TotalPacketsReceived() uint64 {
+	return sum(s.PacketsReceived)
+}
+
+func sum(counters map[packet.Type]uint64) uint64 {
+	var total uint64
+	for _, n := range counters {
+		total += n
+	}
+
+	return total
+}
+
+// the atomic counters of one direction
+type counters struct {
+	bytes   uint64
+	last    int64
+	packets [packet.DISCONNECT + 1]uint64
+}
+
+func (c *counters) count(pkt packet.Generic, n int) {
+	atomic.AddUint64(&c.bytes, uint64(n))
+	atomic.AddUint64(&c.packets[pkt.Type()], 1)
+	atomic.StoreInt64(&c.last, time.Now().UnixNano())
+}
+
+func (c *counters) load() (uint64, map[packet.Type]uint64, time.Time) {
+	// get packets
+	packets := make(map[packet.Type]uint64)
+	for _, t := range packet.Types() {
+		if n := atomic.LoadUint64(&c.packets[t]); n > 0 {
+			packets[t] = n
+		}
+	}
+
+	// get last
+	var last time.Time
+	if nano := atomic.LoadInt64(&c.last); nano > 0 {
+		last = time.Unix(0, nano)
+	}
+
+	return atomic.LoadUint64(&c.bytes), packets, last
+}
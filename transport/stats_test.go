@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/256dpi/gomqtt/packet"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func abstractConnStatsTest(t *testing.T, protocol string) {
+	start := time.Now()
+
+	conn2, done := connectionPair(t, protocol, func(conn1 Conn) {
+		stats := conn1.Stats()
+		assert.Zero(t, stats.BytesReceived)
+		assert.Zero(t, stats.TotalPacketsReceived())
+		assert.True(t, stats.LastReceived.IsZero())
+
+		pkt, err := conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.CONNECT, pkt.Type())
+
+		pkt, err = conn1.Receive()
+		assert.NoError(t, err)
+		assert.Equal(t, packet.PINGREQ, pkt.Type())
+
+		err = conn1.Send(packet.NewConnack(), false)
+		assert.NoError(t, err)
+
+		stats = conn1.Stats()
+		assert.Equal(t, uint64(packet.NewConnect().Len()+2), stats.BytesReceived)
+		assert.Equal(t, map[packet.Type]uint64{
+			packet.CONNECT: 1,
+			packet.PINGREQ: 1,
+		}, stats.PacketsReceived)
+		assert.Equal(t, uint64(2), stats.TotalPacketsReceived())
+		assert.Equal(t, uint64(4), stats.BytesSent)
+		assert.Equal(t, uint64(1), stats.TotalPacketsSent())
+		assert.False(t, stats.LastReceived.Before(start))
+		assert.False(t, stats.LastSent.Before(stats.LastReceived))
+
+		err = conn1.Close()
+		assert.NoError(t, err)
+	})
+
+	err := conn2.Send(packet.NewConnect(), false)
+	assert.NoError(t, err)
+
+	err = conn2.Send(packet.NewPingreq(), true)
+	assert.NoError(t, err)
+
+	pkt, err := conn2.Receive()
+	assert.NoError(t, err)
+	assert.Equal(t, packet.CONNACK, pkt.Type())
+
+	stats := conn2.Stats()
+	assert.Equal(t, map[packet.Type]uint64{
+		packet.CONNECT: 1,
+		packet.PINGREQ: 1,
+	}, stats.PacketsSent)
+	assert.Equal(t, map[packet.Type]uint64{
+		packet.CONNACK: 1,
+	}, stats.PacketsReceived)
+	assert.Equal(t, uint64(packet.NewConnect().Len()+2), stats.BytesSent)
+	assert.Equal(t, uint64(4), stats.BytesReceived)
+
+	safeReceive(done)
+}
+
+func TestNetConnStats(t *testing.T) {
+	abstractConnStatsTest(t, "tcp")
+}
+
+func TestWebSocketConnStats(t *testing.T) {
+	abstractConnStatsTest(t, "ws")
+}
+
+func TestMemoryConnStats(t *testing.T) {
+	abstractConnStatsTest(t, "mem")
+}
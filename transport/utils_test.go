@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"testing"
 	"time"
 )
 
@@ -37,10 +38,10 @@ func init() {
 }
 
 // returns a client-ish and server-ish pair of connections
-func connectionPair(protocol string, handler func(Conn)) (Conn, chan struct{}) {
+func connectionPair(t testing.TB, protocol string, handler func(Conn)) (Conn, chan struct{}) {
 	done := make(chan struct{})
 
-	server, err := testLauncher.Launch(protocol + "://localhost:0")
+	server, err := testLauncher.Launch(launchURL(t, protocol))
 	if err != nil {
 		panic(err)
 	}
@@ -69,6 +70,20 @@ func connectionPair(protocol string, handler func(Conn)) (Conn, chan struct{}) {
 	return conn, done
 }
 
+// returns an url to launch a test server for the protocol
+func launchURL(t testing.TB, protocol string) string {
+	if protocol == "unix" {
+		return "unix://" + socketFile(t)
+	}
+
+	return protocol + "://localhost:0"
+}
+
+// returns the path of a new socket file in a temporary directory
+func socketFile(t testing.TB) string {
+	return filepath.Join(t.TempDir(), "test.sock")
+}
+
 func getPort(s Server) string {
 	_, port, _ := net.SplitHostPort(s.Addr().String())
 	return port
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/http"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -99,7 +100,8 @@ func (s *wsStream) SetReadDeadline(t time.Time) error {
 type WebSocketConn struct {
 	*BaseConn
 
-	conn *websocket.Conn
+	conn   *websocket.Conn
+	header http.Header
 }
 
 // NewWebSocketConn returns a new WebSocketConn.
@@ -120,7 +122,25 @@ func (c *WebSocketConn) RemoteAddr() net.Addr {
 	return c.conn.RemoteAddr()
 }
 
+// Metadata returns additional information about the connection.
+func (c *WebSocketConn) Metadata() Metadata {
+	// get metadata
+	metadata := netConnMetadata(c.conn.UnderlyingConn())
+
+	// set header
+	metadata.RequestHeader = c.header
+
+	return metadata
+}
+
 // UnderlyingConn returns the underlying websocket.Conn.
 func (c *WebSocketConn) UnderlyingConn() *websocket.Conn {
 	return c.conn
 }
+
+// ProxyHeader returns the PROXY protocol header of the connection. It returns
+// nil if the connection has not been accepted from a ProxyListener or the
+// source is not trusted.
+func (c *WebSocketConn) ProxyHeader() *ProxyHeader {
+	return proxyHeader(c.conn.UnderlyingConn())
+}
@@ -42,6 +42,22 @@ func TestWebSocketConnReadLimit(t *testing.T) {
 	abstractConnReadLimitTest(t, "ws")
 }
 
+func TestWebSocketConnStream(t *testing.T) {
+	abstractConnStreamTest(t, "ws")
+}
+
+func TestWebSocketConnStreamTimeout(t *testing.T) {
+	abstractConnStreamTimeoutTest(t, "ws")
+}
+
+func TestWebSocketConnPooling(t *testing.T) {
+	abstractConnPoolingTest(t, "ws")
+}
+
+func TestWebSocketConnVectored(t *testing.T) {
+	abstractConnVectoredTest(t, "ws")
+}
+
 func TestWebSocketConnReadTimeout(t *testing.T) {
 	abstractConnReadTimeoutTest(t, "ws")
 }
@@ -75,7 +91,7 @@ func TestWebSocketConnBigAsyncSendAfterClose(t *testing.T) {
 }
 
 func TestWebSocketBadFrameError(t *testing.T) {
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "ws", func(conn1 Conn) {
 		buf := []byte{0x07, 0x00, 0x00, 0x00, 0x00} // < bad frame
 
 		_, err := conn1.(*WebSocketConn).UnderlyingConn().UnderlyingConn().Write(buf)
@@ -98,7 +114,7 @@ func TestWebSocketChunkedMessage(t *testing.T) {
 	pkt.Message.Topic = "hello"
 	pkt.Message.Payload = []byte("world")
 
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "ws", func(conn1 Conn) {
 		buf := make([]byte, pkt.Len())
 		_, err := pkt.Encode(buf)
 		assert.NoError(t, err)
@@ -129,7 +145,7 @@ func TestWebSocketCoalescedMessage(t *testing.T) {
 	pkt.Message.Topic = "hello"
 	pkt.Message.Payload = []byte("world")
 
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "ws", func(conn1 Conn) {
 		buf := make([]byte, pkt.Len()*2)
 
 		_, err := pkt.Encode(buf)
@@ -165,7 +181,7 @@ func TestWebSocketNotBinaryMessage(t *testing.T) {
 	pkt.Message.Topic = "hello"
 	pkt.Message.Payload = []byte("world")
 
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(t, "ws", func(conn1 Conn) {
 		err := conn1.(*WebSocketConn).UnderlyingConn().WriteMessage(websocket.TextMessage, []byte("hello"))
 		assert.NoError(t, err)
 	})
@@ -181,7 +197,7 @@ func BenchmarkWebSocketConn(b *testing.B) {
 	pkt := packet.NewPublish()
 	pkt.Message.Topic = "foo/bar/baz"
 
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(b, "ws", func(conn1 Conn) {
 		for i := 0; i < b.N; i++ {
 			err := conn1.Send(pkt, false)
 			if err != nil {
@@ -206,7 +222,7 @@ func BenchmarkWebSocketConnAsync(b *testing.B) {
 	pkt := packet.NewPublish()
 	pkt.Message.Topic = "foo/bar/baz"
 
-	conn2, done := connectionPair("ws", func(conn1 Conn) {
+	conn2, done := connectionPair(b, "ws", func(conn1 Conn) {
 		for i := 0; i < b.N; i++ {
 			err := conn1.Send(pkt, true)
 			if err != nil {
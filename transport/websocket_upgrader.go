@@ -53,6 +53,9 @@ func (u *WebSocketUpgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*We
 	// create connection
 	webSocketConn := NewWebSocketConn(conn)
 
+	// keep request header
+	webSocketConn.header = r.Header
+
 	return webSocketConn, nil
 }
 